@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultAuditLogPath  = "/var/log/osctl/audit.log"
+	defaultAuditLogLimit = 50
+)
+
+// auditMu serializes appends to the audit log
+var auditMu sync.Mutex
+
+// AuditEvent is one entry of the audit log, stored as a JSON line
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	User   string    `json:"user,omitempty"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	Result string    `json:"result"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// getAuditLogPath returns the audit log location (OSCTL_AUDIT_LOG)
+func getAuditLogPath() string {
+	if path := os.Getenv("OSCTL_AUDIT_LOG"); path != "" {
+		return path
+	}
+	return defaultAuditLogPath
+}
+
+// writeAuditEvent appends an event to the audit log. Failures are logged, not returned,
+// so auditing never blocks the action itself.
+func writeAuditEvent(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode audit event: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	path := getAuditLogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// readAuditEvents returns the most recent events since a time, optionally filtered by source
+func readAuditEvents(since time.Time, source string, limit int) ([]AuditEvent, error) {
+	f, err := os.Open(getAuditLogPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEvent
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if e.Time.Before(since) || (source != "" && e.Source != source) {
+			continue
+		}
+		events = append(events, e)
+		if limit > 0 && len(events) > limit {
+			events = events[1:]
+		}
+	}
+	return events, scanner.Err()
+}
+
+// getAuditLog renders recent audit events
+func getAuditLog(since, source, limit string, asJSON bool) string {
+	from := time.Now().Add(-24 * time.Hour)
+	if since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			return fmt.Sprintf("Invalid since value: %v", err)
+		}
+		from = t
+	}
+	n := defaultAuditLogLimit
+	if limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil || v < 0 {
+			return fmt.Sprintf("Invalid limit %q (0 shows all events)", limit)
+		}
+		n = v
+	}
+
+	events, err := readAuditEvents(from, source, n)
+	if err != nil {
+		return fmt.Sprintf("Failed to read audit log. Error: %v", err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode audit log. Error: %v", err)
+		}
+		return string(data)
+	}
+	if len(events) == 0 {
+		return "No audit events found"
+	}
+
+	var output strings.Builder
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSOURCE\tUSER\tACTION\tTARGET\tRESULT\tDETAIL")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Source, e.User, e.Action, e.Target, e.Result, e.Detail)
+	}
+	w.Flush()
+	return output.String()
+}
+
+// runAuditLogCommand parses "osctl auditlog" arguments
+func runAuditLogCommand(args []string) string {
+	usage := "Usage: osctl auditlog [--since 24h] [--source watchdog] [--limit 50] [--json]"
+	fs := flag.NewFlagSet("auditlog", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	since := fs.String("since", "", "duration or RFC3339 time")
+	source := fs.String("source", "", "only events from this source")
+	limit := fs.String("limit", "", "maximum number of events (0 shows all)")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getAuditLog(*since, *source, *limit, *asJSON)
+}
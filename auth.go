@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"net/http"
 	"os"
@@ -19,6 +20,28 @@ func getAuthCredentials() (string, string) {
 	return username, password
 }
 
+// getAdminCredentials returns the optional admin credentials (OSCTL_ADMIN_USERNAME,
+// OSCTL_ADMIN_PASSWORD). Without them no API request has admin scope.
+func getAdminCredentials() (string, string, bool) {
+	username := os.Getenv("OSCTL_ADMIN_USERNAME")
+	password := os.Getenv("OSCTL_ADMIN_PASSWORD")
+	return username, password, username != "" && password != ""
+}
+
+// authIdentity is the authenticated API user stored in the request context
+type authIdentity struct {
+	User  string
+	Admin bool
+}
+
+type authContextKey struct{}
+
+// requestIdentity returns the authenticated user of an API request
+func requestIdentity(r *http.Request) authIdentity {
+	id, _ := r.Context().Value(authContextKey{}).(authIdentity)
+	return id
+}
+
 func basicAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		auth := r.Header.Get("Authorization")
@@ -42,13 +65,22 @@ func basicAuth(next http.Handler) http.Handler {
 		}
 
 		username, password := getAuthCredentials()
+		adminUsername, adminPassword, adminEnabled := getAdminCredentials()
 		pair := strings.SplitN(string(payload), ":", 2)
-		if len(pair) != 2 || pair[0] != username || pair[1] != password {
+		var id authIdentity
+		switch {
+		case len(pair) != 2:
+		case adminEnabled && pair[0] == adminUsername && pair[1] == adminPassword:
+			id = authIdentity{User: pair[0], Admin: true}
+		case pair[0] == username && pair[1] == password:
+			id = authIdentity{User: pair[0]}
+		}
+		if id.User == "" {
 			w.Header().Set("WWW-Authenticate", `Basic realm="osctl"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authContextKey{}, id)))
 	})
 }
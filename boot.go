@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBootBlameLimit = 10
+	maxCriticalChainDepth = 64
+	maxBootFailureLines   = 20
+)
+
+var (
+	bootTimestampProperties = []string{
+		"FirmwareTimestampMonotonic", "LoaderTimestampMonotonic", "InitRDTimestampMonotonic",
+		"UserspaceTimestampMonotonic", "FinishTimestampMonotonic",
+	}
+	unitActivationProperties = []string{"Id", "InactiveExitTimestampMonotonic", "ActiveEnterTimestampMonotonic", "After"}
+)
+
+// BootTimes is the boot time breakdown, in seconds
+type BootTimes struct {
+	Firmware  float64 `json:"firmware_seconds,omitempty"`
+	Loader    float64 `json:"loader_seconds,omitempty"`
+	Kernel    float64 `json:"kernel_seconds"`
+	InitRD    float64 `json:"initrd_seconds,omitempty"`
+	Userspace float64 `json:"userspace_seconds"`
+	Total     float64 `json:"total_seconds"`
+}
+
+// UnitActivation is how long a unit took to start during boot
+type UnitActivation struct {
+	Unit    string  `json:"unit"`
+	Seconds float64 `json:"seconds"`
+}
+
+// CriticalChainLink is one unit on the critical chain; At is relative to userspace start
+type CriticalChainLink struct {
+	Unit      string  `json:"unit"`
+	AtSeconds float64 `json:"at_seconds"`
+	Took      float64 `json:"took_seconds,omitempty"`
+}
+
+// BootReport is the boot performance analysis
+type BootReport struct {
+	Times         BootTimes           `json:"times"`
+	DefaultTarget string              `json:"default_target"`
+	Blame         []UnitActivation    `json:"slowest_units"`
+	CriticalChain []CriticalChainLink `json:"critical_chain"`
+	FailedUnits   []string            `json:"failed_units"`
+	FailureLog    []string            `json:"failure_log,omitempty"`
+}
+
+// unitActivation holds the monotonic activation timestamps of a unit in microseconds
+type unitActivation struct {
+	activating uint64
+	activated  uint64
+	after      []string
+}
+
+func usecSeconds(usec uint64) float64 {
+	return float64(usec) / 1e6
+}
+
+// computeBootTimes derives the boot phases from the manager timestamps, the
+// same way systemd-analyze time does
+func computeBootTimes(props map[string]string) (BootTimes, uint64, error) {
+	firmware := parseUnitUint(props["FirmwareTimestampMonotonic"])
+	loader := parseUnitUint(props["LoaderTimestampMonotonic"])
+	initrd := parseUnitUint(props["InitRDTimestampMonotonic"])
+	userspace := parseUnitUint(props["UserspaceTimestampMonotonic"])
+	finish := parseUnitUint(props["FinishTimestampMonotonic"])
+	if finish == 0 {
+		return BootTimes{}, 0, fmt.Errorf("bootup is not yet finished")
+	}
+
+	var t BootTimes
+	// Firmware and loader timestamps count backwards from kernel start
+	if firmware > loader {
+		t.Firmware = usecSeconds(firmware - loader)
+	}
+	t.Loader = usecSeconds(loader)
+	if initrd > 0 {
+		t.Kernel = usecSeconds(initrd)
+		t.InitRD = usecSeconds(userspace - initrd)
+	} else {
+		t.Kernel = usecSeconds(userspace)
+	}
+	t.Userspace = usecSeconds(finish - userspace)
+	t.Total = t.Firmware + t.Loader + t.Kernel + t.InitRD + t.Userspace
+	return t, userspace, nil
+}
+
+// collectUnitActivations reads activation timestamps of all loaded units
+// with one batched property lookup
+func collectUnitActivations(m unitManager) (map[string]unitActivation, error) {
+	entries, err := m.ListUnits(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	all, err := m.UnitPropertiesBatch(names, "Unit", unitActivationProperties)
+	if err != nil {
+		return nil, err
+	}
+	activations := make(map[string]unitActivation, len(all))
+	for name, props := range all {
+		activations[name] = unitActivation{
+			activating: parseUnitUint(props["InactiveExitTimestampMonotonic"]),
+			activated:  parseUnitUint(props["ActiveEnterTimestampMonotonic"]),
+			after:      strings.Fields(props["After"]),
+		}
+	}
+	return activations, nil
+}
+
+// bootBlame returns the units that took longest to activate
+func bootBlame(activations map[string]unitActivation, limit int) []UnitActivation {
+	var blame []UnitActivation
+	for name, a := range activations {
+		if a.activating == 0 || a.activated <= a.activating {
+			continue
+		}
+		blame = append(blame, UnitActivation{Unit: name, Seconds: usecSeconds(a.activated - a.activating)})
+	}
+	sort.Slice(blame, func(i, j int) bool {
+		if blame[i].Seconds != blame[j].Seconds {
+			return blame[i].Seconds > blame[j].Seconds
+		}
+		return blame[i].Unit < blame[j].Unit
+	})
+	if limit > 0 && len(blame) > limit {
+		blame = blame[:limit]
+	}
+	return blame
+}
+
+// criticalChain follows, from the default target, the After= dependency that
+// became active last, like systemd-analyze critical-chain
+func criticalChain(activations map[string]unitActivation, target string, userspace, finish uint64) []CriticalChainLink {
+	var chain []CriticalChainLink
+	unit := target
+	seen := make(map[string]bool)
+	for depth := 0; depth < maxCriticalChainDepth && unit != "" && !seen[unit]; depth++ {
+		seen[unit] = true
+		a, ok := activations[unit]
+		if !ok || a.activated == 0 {
+			break
+		}
+		link := CriticalChainLink{Unit: unit}
+		if a.activated > userspace {
+			link.AtSeconds = usecSeconds(a.activated - userspace)
+		}
+		if a.activating > 0 && a.activated > a.activating {
+			link.Took = usecSeconds(a.activated - a.activating)
+		}
+		chain = append(chain, link)
+
+		next := ""
+		var latest uint64
+		for _, dep := range a.after {
+			d, ok := activations[dep]
+			if !ok || d.activated == 0 || d.activated > a.activated || (finish > 0 && d.activated > finish) {
+				continue
+			}
+			if d.activated > latest {
+				latest, next = d.activated, dep
+			}
+		}
+		unit = next
+	}
+	return chain
+}
+
+// bootFailureLog returns the service manager's failure messages from the current boot
+func bootFailureLog() []string {
+	out, err := exec.Command("journalctl", "-b", "0", "_PID=1", "-p", "warning", "-o", "short-iso", "--no-pager", "-q").CombinedOutput()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, "Failed to start") || strings.Contains(line, "Failed with result") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxBootFailureLines {
+		lines = lines[len(lines)-maxBootFailureLines:]
+	}
+	return lines
+}
+
+// collectBootReport analyzes the current boot
+func collectBootReport(m unitManager, limit int) (BootReport, error) {
+	var report BootReport
+	props, err := m.ManagerProperties(bootTimestampProperties)
+	if err != nil {
+		return report, err
+	}
+	report.Times, _, err = computeBootTimes(props)
+	if err != nil {
+		return report, err
+	}
+	userspace := parseUnitUint(props["UserspaceTimestampMonotonic"])
+	finish := parseUnitUint(props["FinishTimestampMonotonic"])
+
+	activations, err := collectUnitActivations(m)
+	if err != nil {
+		return report, err
+	}
+	report.Blame = bootBlame(activations, limit)
+
+	report.DefaultTarget = "default.target"
+	if p, err := m.UnitProperties("default.target", "Unit", []string{"Id"}); err == nil && p["Id"] != "" {
+		report.DefaultTarget = p["Id"]
+	}
+	report.CriticalChain = criticalChain(activations, report.DefaultTarget, userspace, finish)
+
+	if failed, err := m.ListUnits([]string{"failed"}, nil); err == nil {
+		for _, u := range failed {
+			report.FailedUnits = append(report.FailedUnits, u.Name)
+		}
+	}
+	report.FailureLog = bootFailureLog()
+	return report, nil
+}
+
+// formatSeconds renders a duration in seconds the way systemd-analyze does
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// getBootAnalysis renders the boot analysis
+func getBootAnalysis(limit int, asJSON bool) string {
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+
+	report, err := collectBootReport(m, limit)
+	if err != nil {
+		return fmt.Sprintf("Failed to analyze boot. Error: %v", err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode boot analysis. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	t := report.Times
+	var phases []string
+	for _, p := range []struct {
+		name    string
+		seconds float64
+	}{{"firmware", t.Firmware}, {"loader", t.Loader}, {"kernel", t.Kernel}, {"initrd", t.InitRD}, {"userspace", t.Userspace}} {
+		if p.seconds > 0 || p.name == "kernel" || p.name == "userspace" {
+			phases = append(phases, fmt.Sprintf("%s (%s)", formatSeconds(p.seconds), p.name))
+		}
+	}
+	output.WriteString(fmt.Sprintf("Startup finished in %s = %s\n", strings.Join(phases, " + "), formatSeconds(t.Total)))
+	output.WriteString(fmt.Sprintf("%s reached after %s in userspace\n", report.DefaultTarget, formatSeconds(t.Userspace)))
+
+	if len(report.Blame) > 0 {
+		output.WriteString("\nSlowest units:\n")
+		for _, b := range report.Blame {
+			output.WriteString(fmt.Sprintf("  %10s  %s\n", formatSeconds(b.Seconds), b.Unit))
+		}
+	}
+
+	if len(report.CriticalChain) > 0 {
+		output.WriteString("\nCritical chain:\n")
+		for i, link := range report.CriticalChain {
+			prefix := "  "
+			if i > 0 {
+				prefix += strings.Repeat("  ", i-1) + "└─"
+			}
+			output.WriteString(fmt.Sprintf("%s%s @%s", prefix, link.Unit, formatSeconds(link.AtSeconds)))
+			if link.Took > 0 {
+				output.WriteString(fmt.Sprintf(" +%s", formatSeconds(link.Took)))
+			}
+			output.WriteString("\n")
+		}
+	}
+
+	output.WriteString("\nFailed units:\n")
+	if len(report.FailedUnits) == 0 {
+		output.WriteString("  none\n")
+	}
+	for _, u := range report.FailedUnits {
+		output.WriteString(fmt.Sprintf("  %s\n", u))
+	}
+	if len(report.FailureLog) > 0 {
+		output.WriteString("\nFailures logged during this boot:\n")
+		for _, line := range report.FailureLog {
+			output.WriteString("  " + line + "\n")
+		}
+	}
+	return output.String()
+}
+
+// parseBootLimit validates the number of slowest units to show (0 shows all)
+func parseBootLimit(value string) (int, error) {
+	if value == "" {
+		return defaultBootBlameLimit, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid limit %q (0 shows all units)", value)
+	}
+	return n, nil
+}
+
+// runBootCommand parses "osctl boot" arguments
+func runBootCommand(args []string) string {
+	usage := "Usage: osctl boot [--limit 10] [--json]"
+	fs := flag.NewFlagSet("boot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	limit := fs.String("limit", "", "number of slowest units to show (0 shows all)")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	n, err := parseBootLimit(*limit)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getBootAnalysis(n, *asJSON)
+}
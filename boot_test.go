@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestComputeBootTimes(t *testing.T) {
+	// Firmware and loader count backwards from kernel start, everything else forwards
+	times, userspace, err := computeBootTimes(map[string]string{
+		"FirmwareTimestampMonotonic":  "8000000",
+		"LoaderTimestampMonotonic":    "2000000",
+		"InitRDTimestampMonotonic":    "1500000",
+		"UserspaceTimestampMonotonic": "3000000",
+		"FinishTimestampMonotonic":    "7400000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BootTimes{Firmware: 6, Loader: 2, Kernel: 1.5, InitRD: 1.5, Userspace: 4.4, Total: 15.4}
+	if !closeTo(times.Firmware, want.Firmware) || !closeTo(times.Loader, want.Loader) || !closeTo(times.Kernel, want.Kernel) ||
+		!closeTo(times.InitRD, want.InitRD) || !closeTo(times.Userspace, want.Userspace) || !closeTo(times.Total, want.Total) {
+		t.Errorf("got %+v, want %+v", times, want)
+	}
+	if userspace != 3000000 {
+		t.Errorf("userspace start is %d", userspace)
+	}
+
+	// Without an initrd the kernel phase runs until userspace starts
+	times, _, err = computeBootTimes(map[string]string{"UserspaceTimestampMonotonic": "2500000", "FinishTimestampMonotonic": "4000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times.Firmware != 0 || times.InitRD != 0 || !closeTo(times.Kernel, 2.5) || !closeTo(times.Total, 4) {
+		t.Errorf("without initrd got %+v", times)
+	}
+
+	if _, _, err := computeBootTimes(map[string]string{"UserspaceTimestampMonotonic": "2500000", "FinishTimestampMonotonic": "0"}); err == nil {
+		t.Error("an unfinished boot was accepted")
+	}
+}
+
+func bootTestActivations() map[string]unitActivation {
+	return map[string]unitActivation{
+		"multi-user.target": {activating: 7400000, activated: 7400000, after: []string{"nginx.service", "network.target", "late.service"}},
+		"nginx.service":     {activating: 5000000, activated: 7300000, after: []string{"network.target"}},
+		"network.target":    {activating: 3900000, activated: 4000000},
+		"late.service":      {activating: 7000000, activated: 9000000},
+		"inactive.service":  {},
+	}
+}
+
+func TestBootBlame(t *testing.T) {
+	blame := bootBlame(bootTestActivations(), 2)
+	if len(blame) != 2 || blame[0].Unit != "nginx.service" || !closeTo(blame[0].Seconds, 2.3) || blame[1].Unit != "late.service" {
+		t.Errorf("got %+v", blame)
+	}
+}
+
+func TestCriticalChain(t *testing.T) {
+	// late.service became active after the target and is not on the chain
+	chain := criticalChain(bootTestActivations(), "multi-user.target", 3000000, 7400000)
+	want := []string{"multi-user.target", "nginx.service", "network.target"}
+	if len(chain) != len(want) {
+		t.Fatalf("got %+v", chain)
+	}
+	for i, link := range chain {
+		if link.Unit != want[i] {
+			t.Errorf("link %d is %s, want %s", i, link.Unit, want[i])
+		}
+	}
+	if !closeTo(chain[1].AtSeconds, 4.3) || !closeTo(chain[1].Took, 2.3) {
+		t.Errorf("nginx.service link = %+v", chain[1])
+	}
+
+	// Dependency cycles end the chain
+	cycle := map[string]unitActivation{
+		"a.target": {activated: 2, after: []string{"b.target"}},
+		"b.target": {activated: 1, after: []string{"a.target"}},
+	}
+	if chain := criticalChain(cycle, "a.target", 0, 0); len(chain) != 2 {
+		t.Errorf("cycle gave %+v", chain)
+	}
+}
+
+func TestCollectUnitActivationsBatchesLookups(t *testing.T) {
+	m := &fakeUnitManager{
+		units: []UnitListEntry{{Name: "nginx.service"}, {Name: "network.target"}, {Name: "gone.service"}},
+		props: map[string]map[string]string{
+			"nginx.service/Unit":  {"InactiveExitTimestampMonotonic": "5000000", "ActiveEnterTimestampMonotonic": "7300000", "After": "network.target basic.target"},
+			"network.target/Unit": {"ActiveEnterTimestampMonotonic": "4000000"},
+		},
+	}
+	activations, err := collectUnitActivations(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.batches != 1 {
+		t.Errorf("got %d property lookups, want 1", m.batches)
+	}
+	if len(activations) != 2 {
+		t.Errorf("got %d units, want 2", len(activations))
+	}
+	if a := activations["nginx.service"]; a.activating != 5000000 || a.activated != 7300000 || len(a.after) != 2 {
+		t.Errorf("nginx.service = %+v", a)
+	}
+}
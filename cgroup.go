@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupV2Root returns the mount point of the unified (v2) cgroup hierarchy,
+// supporting both pure v2 and hybrid layouts. OSCTL_CGROUP_ROOT overrides the
+// location, e.g. when the host's cgroupfs is mounted elsewhere in a container.
+func cgroupV2Root() (string, error) {
+	if root := os.Getenv("OSCTL_CGROUP_ROOT"); root != "" {
+		return root, nil
+	}
+	for _, root := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+		if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+			return root, nil
+		}
+	}
+	return "", errors.New("cgroup v2 hierarchy not found")
+}
+
+// cgroupDir returns the filesystem path of a control group such as "/system.slice/nginx.service"
+func cgroupDir(controlGroup string) (string, error) {
+	if controlGroup == "" {
+		return "", errors.New("unit has no control group")
+	}
+	root, err := cgroupV2Root()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, filepath.Clean("/"+controlGroup)), nil
+}
+
+// readCgroupUint reads a single-value cgroup file such as memory.current.
+// "max" is reported as 0.
+func readCgroupUint(dir, file string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readCgroupKeyed reads a flat keyed cgroup file such as cpu.stat
+func readCgroupKeyed(dir, file string) (map[string]uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values, nil
+}
+
+// cgroupPIDs returns all process IDs in a control group and its children
+func cgroupPIDs(dir string) ([]int32, error) {
+	var pids []int32
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "cgroup.procs" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, field := range strings.Fields(string(data)) {
+			if pid, err := strconv.ParseInt(field, 10, 32); err == nil {
+				pids = append(pids, int32(pid))
+			}
+		}
+		return nil
+	})
+	return pids, err
+}
+
+// PressureLine is one line of a PSI file: the share of time stalled over 10s,
+// 60s and 300s windows, and the total stall time in microseconds
+type PressureLine struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total_usec"`
+}
+
+// Pressure is the content of a PSI file such as /proc/pressure/memory or a
+// cgroup's memory.pressure. Full is absent for CPU on older kernels.
+type Pressure struct {
+	Some PressureLine  `json:"some"`
+	Full *PressureLine `json:"full,omitempty"`
+}
+
+// parsePressure parses the "some ..." and "full ..." lines of a PSI file
+func parsePressure(data string) (Pressure, error) {
+	var p Pressure
+	found := false
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var pl PressureLine
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				pl.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				pl.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				pl.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				pl.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			p.Some = pl
+			found = true
+		case "full":
+			p.Full = &pl
+		}
+	}
+	if !found {
+		return p, errors.New("no pressure data")
+	}
+	return p, nil
+}
+
+// readPressureFile reads and parses a PSI file
+func readPressureFile(path string) (Pressure, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Pressure{}, err
+	}
+	return parsePressure(string(data))
+}
+
+// readCgroupIOStat sums the read and written bytes over all devices in io.stat
+func readCgroupIOStat(dir string) (readBytes, writeBytes uint64, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, field := range strings.Fields(line) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				readBytes += n
+			case "wbytes":
+				writeBytes += n
+			}
+		}
+	}
+	return readBytes, writeBytes, nil
+}
+
+// listServiceCgroups returns the control groups of all services, keyed by
+// unit name, with paths relative to the cgroup root (e.g. "/system.slice/nginx.service").
+// Only slices are descended into, so services nested in containers
+// (docker-<id>.scope/system.slice/cron.service) cannot shadow the host's, and
+// a user manager (user@1000.service) is reported as a whole, including the
+// user's services.
+func listServiceCgroups() (map[string]string, error) {
+	root, err := cgroupV2Root()
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]string)
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Groups can vanish while walking
+			return nil
+		}
+		if !d.IsDir() || path == root || strings.HasSuffix(d.Name(), ".slice") {
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".service") {
+			rel, _ := filepath.Rel(root, path)
+			groups[d.Name()] = "/" + rel
+		}
+		return filepath.SkipDir
+	})
+	return groups, err
+}
+
+// processControlGroup returns the control group of a process from
+// /proc/<pid>/cgroup, preferring the unified hierarchy over name=systemd.
+func processControlGroup(pid int32) (string, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	var group string
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" && parts[2] != "/" {
+			return parts[2], nil
+		}
+		if parts[1] == "name=systemd" {
+			group = parts[2]
+		}
+	}
+	if group == "" {
+		return "", errors.New("no systemd control group")
+	}
+	return group, nil
+}
+
+// processUnit returns the systemd unit (service or scope) a process belongs to,
+// or an empty string when it cannot be determined.
+func processUnit(pid int32) string {
+	group, err := processControlGroup(pid)
+	if err != nil {
+		return ""
+	}
+	elems := strings.Split(group, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		if strings.HasSuffix(elems[i], ".service") || strings.HasSuffix(elems[i], ".scope") {
+			return elems[i]
+		}
+	}
+	return ""
+}
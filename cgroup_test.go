@@ -0,0 +1,40 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListServiceCgroupsOnlyWalksSlices(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"init.scope",
+		"system.slice/cron.service",
+		"system.slice/nginx.service/worker",
+		"system.slice/docker-abc.scope/system.slice/cron.service",
+		"system.slice/docker-abc.scope/system.slice/postgres.service",
+		"machine.slice/libpod-def.scope/container/system.slice/sshd.service",
+		"docker/abc/system.slice/redis.service",
+		"user.slice/user-1000.slice/user@1000.service/app.slice/foo.service",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("OSCTL_CGROUP_ROOT", root)
+
+	groups, err := listServiceCgroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"cron.service":      "/system.slice/cron.service",
+		"nginx.service":     "/system.slice/nginx.service",
+		"user@1000.service": "/user.slice/user-1000.slice/user@1000.service",
+	}
+	if !maps.Equal(groups, want) {
+		t.Errorf("got %v, want %v", groups, want)
+	}
+}
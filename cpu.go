@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+const (
+	defaultCPUWindow = time.Second
+	maxCPUWindow     = time.Minute
+)
+
+// CPUModePercent is the share of time a CPU spent in each mode during the window
+type CPUModePercent struct {
+	CPU     string  `json:"cpu"`
+	Usage   float64 `json:"usage"`
+	User    float64 `json:"user"`
+	Nice    float64 `json:"nice"`
+	System  float64 `json:"system"`
+	Iowait  float64 `json:"iowait"`
+	Irq     float64 `json:"irq"`
+	Softirq float64 `json:"softirq"`
+	Steal   float64 `json:"steal"`
+	Guest   float64 `json:"guest"`
+	Idle    float64 `json:"idle"`
+}
+
+// CPUReport is the result of sampling CPU times over a window
+type CPUReport struct {
+	Window  string           `json:"window"`
+	Cores   int              `json:"cores"`
+	Total   CPUModePercent   `json:"total"`
+	PerCore []CPUModePercent `json:"per_core"`
+}
+
+// cpuModePercents converts the difference between two CPU time samples into percentages
+func cpuModePercents(prev, cur cpu.TimesStat) CPUModePercent {
+	// Guest time is already accounted for in User and Nice on Linux
+	total := func(t cpu.TimesStat) float64 {
+		return t.User + t.Nice + t.System + t.Idle + t.Iowait + t.Irq + t.Softirq + t.Steal
+	}
+	p := CPUModePercent{CPU: cur.CPU}
+	dTotal := total(cur) - total(prev)
+	if dTotal <= 0 {
+		return p
+	}
+	pct := func(a, b float64) float64 {
+		return math.Max(0, math.Min(100, 100*(b-a)/dTotal))
+	}
+	p.User = pct(prev.User, cur.User)
+	p.Nice = pct(prev.Nice, cur.Nice)
+	p.System = pct(prev.System, cur.System)
+	p.Iowait = pct(prev.Iowait, cur.Iowait)
+	p.Irq = pct(prev.Irq, cur.Irq)
+	p.Softirq = pct(prev.Softirq, cur.Softirq)
+	p.Steal = pct(prev.Steal, cur.Steal)
+	p.Guest = pct(prev.Guest+prev.GuestNice, cur.Guest+cur.GuestNice)
+	p.Idle = pct(prev.Idle, cur.Idle)
+	p.Usage = math.Max(0, 100-p.Idle-p.Iowait)
+	return p
+}
+
+// cpuBusyPercent returns the share of non-idle CPU time between two samples
+func cpuBusyPercent(prev, cur cpu.TimesStat) float64 {
+	return cpuModePercents(prev, cur).Usage
+}
+
+// getCPUWindow parses a sampling window, falling back to OSCTL_CPU_WINDOW and then 1s
+func getCPUWindow(value string) (time.Duration, error) {
+	if value == "" {
+		value = os.Getenv("OSCTL_CPU_WINDOW")
+	}
+	if value == "" {
+		return defaultCPUWindow, nil
+	}
+	d, err := parseDurationValue(value)
+	if err != nil || d <= 0 || d > maxCPUWindow {
+		return 0, fmt.Errorf("invalid CPU sampling window %q (must be between 0 and %s)", value, maxCPUWindow)
+	}
+	return d, nil
+}
+
+// sampleCPU measures CPU time distribution, overall and per core, over window
+func sampleCPU(window time.Duration) (CPUReport, error) {
+	prevTotal, err := cpu.Times(false)
+	if err != nil {
+		return CPUReport{}, err
+	}
+	prevCores, err := cpu.Times(true)
+	if err != nil {
+		return CPUReport{}, err
+	}
+	time.Sleep(window)
+	curTotal, err := cpu.Times(false)
+	if err != nil {
+		return CPUReport{}, err
+	}
+	curCores, err := cpu.Times(true)
+	if err != nil {
+		return CPUReport{}, err
+	}
+	if len(prevTotal) == 0 || len(curTotal) == 0 {
+		return CPUReport{}, fmt.Errorf("no CPU time counters available")
+	}
+
+	report := CPUReport{
+		Window: window.String(),
+		Cores:  len(curCores),
+		Total:  cpuModePercents(prevTotal[0], curTotal[0]),
+	}
+	prevByName := make(map[string]cpu.TimesStat, len(prevCores))
+	for _, t := range prevCores {
+		prevByName[t.CPU] = t
+	}
+	for _, t := range curCores {
+		if p, ok := prevByName[t.CPU]; ok {
+			report.PerCore = append(report.PerCore, cpuModePercents(p, t))
+		}
+	}
+	return report, nil
+}
+
+// updateCPUMetrics exports a CPU report to Prometheus
+func updateCPUMetrics(report CPUReport) {
+	cpuUsage.Set(report.Total.Usage)
+	for _, p := range append([]CPUModePercent{report.Total}, report.PerCore...) {
+		modes := map[string]float64{
+			"user": p.User, "nice": p.Nice, "system": p.System, "iowait": p.Iowait,
+			"irq": p.Irq, "softirq": p.Softirq, "steal": p.Steal, "guest": p.Guest, "idle": p.Idle,
+		}
+		for mode, value := range modes {
+			cpuModeUsage.WithLabelValues(p.CPU, mode).Set(value)
+		}
+	}
+}
+
+// getCpuUsage returns the CPU usage breakdown sampled over window
+func getCpuUsage(window string, asJSON bool) string {
+	d, err := getCPUWindow(window)
+	if err != nil {
+		return err.Error()
+	}
+	report, err := sampleCPU(d)
+	if err != nil {
+		return fmt.Sprintf("Error getting CPU usage: %v", err)
+	}
+	updateCPUMetrics(report)
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Error encoding CPU usage: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("CPU Usage: %.2f%% (window %s, %d cores)\n\n", report.Total.Usage, report.Window, report.Cores))
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', tabwriter.AlignRight)
+	io.WriteString(w, "CPU\t%usage\t%usr\t%nice\t%sys\t%iowait\t%irq\t%soft\t%steal\t%guest\t%idle\t\n")
+	for _, p := range append([]CPUModePercent{report.Total}, report.PerCore...) {
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			p.CPU, p.Usage, p.User, p.Nice, p.System, p.Iowait, p.Irq, p.Softirq, p.Steal, p.Guest, p.Idle)
+	}
+	w.Flush()
+	return output.String()
+}
+
+// runCPUCommand handles `osctl cpu [--window 1s] [--json]`
+func runCPUCommand(args []string) string {
+	fs := flag.NewFlagSet("cpu", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	window := fs.String("window", "", "sampling window (default: OSCTL_CPU_WINDOW or 1s)")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\nUsage: osctl cpu [--window 1s] [--json]", err)
+	}
+	return getCpuUsage(*window, *asJSON)
+}
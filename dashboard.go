@@ -0,0 +1,550 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/process"
+	"golang.org/x/term"
+)
+
+const (
+	defaultDashboardRefresh = time.Second
+	dashboardHealthInterval = 15 * time.Second
+	dashboardMaxUnits       = 5
+)
+
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// dashboardProcessGuard never overrides the protected-process deny list
+var dashboardProcessGuard = ProcessGuard{Source: "dashboard"}
+
+// dashboardSnapshot is the data shown by one dashboard frame
+type dashboardSnapshot struct {
+	Time      time.Time
+	CPU       float64
+	Memory    float64
+	Load      *load.AvgStat
+	Network   []NetworkIORate
+	Disk      []DiskIORate
+	Processes []ProcessStat
+	Failed    []string
+	FailedErr error
+}
+
+// dashboardAction is a pending action awaiting confirmation
+type dashboardAction struct {
+	Prompt string
+	Run    func() string
+}
+
+type dashboard struct {
+	width       int
+	height      int
+	snapshot    dashboardSnapshot
+	cpuHist     []float64
+	memHist     []float64
+	loadHist    []float64
+	health      HealthStatus
+	sortKey     string
+	focus       int // 0: processes, 1: failed units
+	procSel     int
+	selPID      int32
+	unitSel     int
+	status      string
+	pending     *dashboardAction
+	hostname    string
+	cores       int
+	initialized bool
+}
+
+// collectDashboardSnapshots produces a snapshot every refresh interval until stop is closed
+func collectDashboardSnapshots(refresh time.Duration, out chan<- dashboardSnapshot, stop <-chan struct{}) {
+	prev := takeSystemSample()
+	for {
+		// Sampling processes over the refresh interval doubles as the tick
+		procs, _, _ := collectProcessStats(refresh)
+		cur := takeSystemSample()
+		snap := dashboardSnapshot{
+			Time:      cur.Time,
+			CPU:       cpuBusyPercent(prev.CPU, cur.CPU),
+			Memory:    cur.Memory,
+			Network:   computeNetworkRates(prev, cur),
+			Disk:      computeDiskRates(prev, cur),
+			Processes: procs,
+		}
+		snap.Load, _ = load.Avg()
+		snap.Failed, snap.FailedErr = listFailedUnits()
+		prev = cur
+
+		select {
+		case out <- snap:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// readDashboardKeys translates terminal input into key names
+func readDashboardKeys(out chan<- string) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(out)
+			return
+		}
+		in := string(buf[:n])
+		switch in {
+		case "\x1b[A":
+			out <- "up"
+		case "\x1b[B":
+			out <- "down"
+		case "\x1b[5~":
+			out <- "pgup"
+		case "\x1b[6~":
+			out <- "pgdown"
+		case "\t":
+			out <- "tab"
+		case "\x03":
+			out <- "q"
+		default:
+			for _, r := range in {
+				out <- string(r)
+			}
+		}
+	}
+}
+
+func sparkline(values []float64, max float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		idx := 0
+		if max > 0 {
+			idx = int(v / max * float64(len(sparkChars)-1))
+		}
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(sparkChars) {
+			idx = len(sparkChars) - 1
+		}
+		b.WriteRune(sparkChars[idx])
+	}
+	return b.String()
+}
+
+func appendHistory(hist []float64, v float64, limit int) []float64 {
+	hist = append(hist, v)
+	if limit > 0 && len(hist) > limit {
+		hist = hist[len(hist)-limit:]
+	}
+	return hist
+}
+
+// fit pads or truncates s to exactly width runes
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+func (d *dashboard) update(snap dashboardSnapshot) {
+	d.snapshot = snap
+	d.initialized = true
+	sortProcessStats(d.snapshot.Processes, d.sortKey)
+	// Narrow or not yet sized terminals still keep the history bounded
+	histLen := max(d.width-20, 1)
+	d.cpuHist = appendHistory(d.cpuHist, snap.CPU, histLen)
+	d.memHist = appendHistory(d.memHist, snap.Memory, histLen)
+	if snap.Load != nil {
+		d.loadHist = appendHistory(d.loadHist, snap.Load.Load1, histLen)
+	}
+	// Keep the selection on the same process as rows move between refreshes
+	for i, p := range d.snapshot.Processes {
+		if p.PID == d.selPID {
+			d.procSel = i
+			break
+		}
+	}
+	d.clampSelection()
+}
+
+func (d *dashboard) clampSelection() {
+	if n := len(d.snapshot.Processes); d.procSel >= n {
+		d.procSel = n - 1
+	}
+	if d.procSel < 0 {
+		d.procSel = 0
+	}
+	if n := len(d.snapshot.Failed); d.unitSel >= n {
+		d.unitSel = n - 1
+	}
+	if d.unitSel < 0 {
+		d.unitSel = 0
+	}
+	if len(d.snapshot.Failed) == 0 {
+		d.focus = 0
+	}
+	if d.procSel < len(d.snapshot.Processes) {
+		d.selPID = d.snapshot.Processes[d.procSel].PID
+	}
+}
+
+func (d *dashboard) selectedProcess() (ProcessStat, bool) {
+	if d.focus != 0 || d.procSel >= len(d.snapshot.Processes) {
+		return ProcessStat{}, false
+	}
+	return d.snapshot.Processes[d.procSel], true
+}
+
+func (d *dashboard) selectedUnit() (string, bool) {
+	if d.focus != 1 || d.unitSel >= len(d.snapshot.Failed) {
+		return "", false
+	}
+	return d.snapshot.Failed[d.unitSel], true
+}
+
+// renice asks to change the nice value of the selected process by delta
+func (d *dashboard) renice(delta int32) {
+	p, ok := d.selectedProcess()
+	if !ok {
+		return
+	}
+	proc, err := process.NewProcess(p.PID)
+	if err != nil {
+		d.status = fmt.Sprintf("Process %d not found", p.PID)
+		return
+	}
+	nice, err := proc.Nice()
+	if err != nil {
+		d.status = fmt.Sprintf("Failed to read nice value of %d: %v", p.PID, err)
+		return
+	}
+	// gopsutil reports the raw getpriority(2) value, which is 20 - nice
+	target := 20 - nice + delta
+	pid, prio := strconv.Itoa(int(p.PID)), strconv.Itoa(int(target))
+	d.pending = &dashboardAction{
+		Prompt: fmt.Sprintf("Renice %s (%s) to %s?", pid, p.Name, prio),
+		Run:    func() string { return strings.TrimSpace(setProcessPriority(pid, prio, dashboardProcessGuard)) },
+	}
+}
+
+// moveSelection moves the selection of the focused pane
+func (d *dashboard) moveSelection(delta int) {
+	if d.focus == 0 {
+		d.procSel += delta
+	} else {
+		d.unitSel += delta
+	}
+}
+
+// handleKey applies a key press and reports whether the dashboard should exit
+func (d *dashboard) handleKey(key string) bool {
+	if d.pending != nil {
+		if key == "y" || key == "Y" {
+			d.status = d.pending.Run()
+		} else {
+			d.status = "Cancelled"
+		}
+		d.pending = nil
+		return false
+	}
+
+	switch key {
+	case "q":
+		return true
+	case "tab":
+		if len(d.snapshot.Failed) > 0 {
+			d.focus = 1 - d.focus
+		}
+	case "up", "k":
+		d.moveSelection(-1)
+	case "down", "j":
+		d.moveSelection(1)
+	case "pgup":
+		d.moveSelection(-10)
+	case "pgdown":
+		d.moveSelection(10)
+	case "s":
+		keys := []string{"cpu", "mem", "rss", "io", "threads", "fds"}
+		for i, k := range keys {
+			if k == d.sortKey {
+				d.sortKey = keys[(i+1)%len(keys)]
+				break
+			}
+		}
+		sortProcessStats(d.snapshot.Processes, d.sortKey)
+	case "x", "X":
+		if p, ok := d.selectedProcess(); ok {
+			pid := strconv.Itoa(int(p.PID))
+			if key == "x" {
+				d.pending = &dashboardAction{
+					Prompt: fmt.Sprintf("Send SIGTERM to %s (%s)?", pid, p.Name),
+					Run:    func() string { return killProcess(pid, dashboardProcessGuard) },
+				}
+			} else {
+				d.pending = &dashboardAction{
+					Prompt: fmt.Sprintf("Send SIGKILL to %s (%s)?", pid, p.Name),
+					Run:    func() string { return killProcessForce(pid, dashboardProcessGuard) },
+				}
+			}
+		}
+	case "+":
+		d.renice(1)
+	case "-":
+		d.renice(-1)
+	case "r":
+		if unit, ok := d.selectedUnit(); ok {
+			d.pending = &dashboardAction{
+				Prompt: fmt.Sprintf("Restart %s?", unit),
+				Run:    func() string { return manageService("restart", unit) },
+			}
+		}
+	}
+	d.clampSelection()
+	return false
+}
+
+func (d *dashboard) render() string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fit(fmt.Sprintf(format, args...), d.width))
+	}
+	reverse := func(s string) string { return "\x1b[7m" + fit(s, d.width) + "\x1b[0m" }
+	snap := d.snapshot
+
+	uptime, _ := host.Uptime()
+	header := fmt.Sprintf("osctl dashboard - %s  up %s  health: %s  %s",
+		d.hostname, time.Duration(uptime)*time.Second, d.health, time.Now().Format("15:04:05"))
+	lines = append(lines, reverse(header))
+
+	if m := readMaintenanceStatus(); m.Enabled {
+		msg := "MAINTENANCE MODE"
+		if !m.EnabledAt.IsZero() {
+			msg += " since " + m.EnabledAt.Format("2006-01-02 15:04")
+		}
+		if m.Message != "" {
+			msg += ": " + m.Message
+		}
+		lines = append(lines, "\x1b[41;97m"+fit(msg, d.width)+"\x1b[0m")
+	}
+
+	if !d.initialized {
+		add("Collecting data...")
+		return strings.Join(lines, "\r\n")
+	}
+
+	loadMax := float64(d.cores)
+	for _, v := range d.loadHist {
+		if v > loadMax {
+			loadMax = v
+		}
+	}
+	add("CPU  %6.2f%%  %s", snap.CPU, sparkline(d.cpuHist, 100))
+	add("MEM  %6.2f%%  %s", snap.Memory, sparkline(d.memHist, 100))
+	if snap.Load != nil {
+		add("LOAD %7.2f  %s  (5m %.2f, 15m %.2f, %d cores)", snap.Load.Load1, sparkline(d.loadHist, loadMax), snap.Load.Load5, snap.Load.Load15, d.cores)
+	}
+
+	net := append([]NetworkIORate(nil), snap.Network...)
+	sort.Slice(net, func(i, j int) bool {
+		return net[i].RxBytesPerSec+net[i].TxBytesPerSec > net[j].RxBytesPerSec+net[j].TxBytesPerSec
+	})
+	var parts []string
+	for _, n := range net {
+		if n.Interface == "lo" || len(parts) == 3 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s rx %s/s tx %s/s", n.Interface, formatBytes(uint64(n.RxBytesPerSec)), formatBytes(uint64(n.TxBytesPerSec))))
+	}
+	add("NET  %s", strings.Join(parts, " | "))
+
+	disks := append([]DiskIORate(nil), snap.Disk...)
+	sort.Slice(disks, func(i, j int) bool { return disks[i].UtilPercent > disks[j].UtilPercent })
+	parts = nil
+	for _, dk := range disks {
+		if len(parts) == 3 {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s r %s/s w %s/s util %.0f%%", dk.Device, formatBytes(uint64(dk.ReadBytesPerSec)), formatBytes(uint64(dk.WriteBytesPerSec)), dk.UtilPercent))
+	}
+	add("DISK %s", strings.Join(parts, " | "))
+	add("")
+
+	unitRows := len(snap.Failed)
+	if unitRows > dashboardMaxUnits {
+		unitRows = dashboardMaxUnits
+	}
+	// Header, columns, units title, unit rows, blank, status and help lines
+	procRows := d.height - len(lines) - 2 - 1 - unitRows - 3
+	if snap.FailedErr != nil || unitRows == 0 {
+		procRows--
+	}
+	if procRows < 1 {
+		procRows = 1
+	}
+
+	title := fmt.Sprintf("PROCESSES (%d, sort: %s)", len(snap.Processes), d.sortKey)
+	if d.focus == 0 {
+		lines = append(lines, reverse(title))
+	} else {
+		add("%s", title)
+	}
+	add("  %7s %-10s %-20s %6s %6s %10s %4s %10s %10s", "PID", "USER", "NAME", "CPU%", "MEM%", "RSS", "THR", "READ/s", "WRITE/s")
+	offset := 0
+	if d.procSel >= procRows {
+		offset = d.procSel - procRows + 1
+	}
+	for i := offset; i < len(snap.Processes) && i < offset+procRows; i++ {
+		p := snap.Processes[i]
+		row := fmt.Sprintf("  %7d %-10s %-20s %6.1f %6.1f %10s %4d %10s %10s", p.PID, fit(p.User, 10), fit(p.Name, 20),
+			p.CPUPercent, p.MemPercent, formatBytes(p.RSS), p.Threads,
+			formatBytes(uint64(p.ReadBytesPerSec)), formatBytes(uint64(p.WriteBytesPerSec)))
+		if d.focus == 0 && i == d.procSel {
+			lines = append(lines, reverse(">"+row[1:]))
+		} else {
+			add("%s", row)
+		}
+	}
+	for i := len(snap.Processes) - offset; i < procRows; i++ {
+		add("")
+	}
+
+	title = fmt.Sprintf("FAILED UNITS (%d)", len(snap.Failed))
+	if d.focus == 1 {
+		lines = append(lines, reverse(title))
+	} else {
+		add("%s", title)
+	}
+	switch {
+	case snap.FailedErr != nil:
+		add("  unavailable: %v", snap.FailedErr)
+	case len(snap.Failed) == 0:
+		add("  none")
+	default:
+		start := 0
+		if d.unitSel >= unitRows {
+			start = d.unitSel - unitRows + 1
+		}
+		for i := start; i < len(snap.Failed) && i < start+unitRows; i++ {
+			row := "  " + snap.Failed[i]
+			if d.focus == 1 && i == d.unitSel {
+				lines = append(lines, reverse(">"+row[1:]))
+			} else {
+				add("%s", row)
+			}
+		}
+	}
+
+	add("")
+	if d.pending != nil {
+		lines = append(lines, "\x1b[1m"+fit(d.pending.Prompt+" [y/N]", d.width)+"\x1b[0m")
+	} else {
+		add("%s", d.status)
+	}
+	add("↑/↓ select  tab switch pane  s sort  x kill  X force kill  +/- renice  r restart unit  q quit")
+	return strings.Join(lines, "\r\n")
+}
+
+// runDashboard runs the full-screen live dashboard until the user quits
+func runDashboard(refresh time.Duration) string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return "The dashboard requires an interactive terminal"
+	}
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Sprintf("Failed to initialize terminal: %v", err)
+	}
+
+	// Alternate screen, hidden cursor; restored on exit
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(fd, oldState)
+	}()
+
+	d := &dashboard{sortKey: "cpu", health: "checking"}
+	d.hostname, _ = os.Hostname()
+	d.cores, _ = cpu.Counts(true)
+	d.width, d.height, _ = term.GetSize(int(os.Stdout.Fd()))
+
+	snapshots := make(chan dashboardSnapshot)
+	keys := make(chan string, 16)
+	health := make(chan HealthStatus, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go collectDashboardSnapshots(refresh, snapshots, stop)
+	go readDashboardKeys(keys)
+	go func() {
+		for {
+			select {
+			case health <- collectHealth().Status:
+			case <-stop:
+				return
+			}
+			select {
+			case <-time.After(dashboardHealthInterval):
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+
+	draw := func() {
+		fmt.Print("\x1b[H\x1b[2J" + d.render())
+	}
+	draw()
+	for {
+		select {
+		case snap := <-snapshots:
+			d.update(snap)
+		case key, ok := <-keys:
+			if !ok || d.handleKey(key) {
+				return ""
+			}
+		case status := <-health:
+			d.health = status
+		case <-resize:
+			d.width, d.height, _ = term.GetSize(int(os.Stdout.Fd()))
+		}
+		draw()
+	}
+}
+
+// runDashboardCommand handles `osctl dashboard [--refresh 1s]`
+func runDashboardCommand(args []string) string {
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	refresh := fs.String("refresh", "", "refresh interval")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\nUsage: osctl dashboard [--refresh 1s]", err)
+	}
+	interval := defaultDashboardRefresh
+	if *refresh != "" {
+		d, err := parseDurationValue(*refresh)
+		if err != nil || d < 200*time.Millisecond || d > maxRateInterval {
+			return fmt.Sprintf("Invalid refresh interval %q (must be between 200ms and %s)", *refresh, maxRateInterval)
+		}
+		interval = d
+	}
+	return runDashboard(interval)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/load"
+)
+
+func TestDashboardHistoryBounded(t *testing.T) {
+	for _, width := range []int{0, 10, 20, 30} {
+		d := &dashboard{width: width}
+		for range 100 {
+			d.update(dashboardSnapshot{CPU: 1, Memory: 2, Load: &load.AvgStat{Load1: 3}})
+		}
+		want := max(width-20, 1)
+		if len(d.cpuHist) != want || len(d.memHist) != want || len(d.loadHist) != want {
+			t.Errorf("width %d kept %d/%d/%d samples, want %d", width, len(d.cpuHist), len(d.memHist), len(d.loadHist), want)
+		}
+	}
+}
@@ -1,67 +1,246 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"math"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/shirou/gopsutil/disk"
-	"github.com/shirou/gopsutil/net"
 	"github.com/shirou/gopsutil/process"
 )
 
-// getNetworkIO returns network I/O statistics with rates
-func getNetworkIO() string {
-	stats, err := net.IOCounters(true)
+const (
+	defaultRateInterval = time.Second
+	maxRateInterval     = time.Minute
+)
+
+// NetworkIORate holds per-interface throughput computed between two samples
+type NetworkIORate struct {
+	Interface       string  `json:"interface"`
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+	ErrorsPerSec    float64 `json:"errors_per_sec"`
+	DropsPerSec     float64 `json:"drops_per_sec"`
+	RxBytesTotal    uint64  `json:"rx_bytes_total"`
+	TxBytesTotal    uint64  `json:"tx_bytes_total"`
+}
+
+// DiskIORate holds iostat-style per-device statistics computed between two samples
+type DiskIORate struct {
+	Device           string  `json:"device"`
+	ReadIOPS         float64 `json:"read_iops"`
+	WriteIOPS        float64 `json:"write_iops"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	ReadAwaitMs      float64 `json:"read_await_ms"`
+	WriteAwaitMs     float64 `json:"write_await_ms"`
+	AwaitMs          float64 `json:"await_ms"`
+	QueueDepth       float64 `json:"queue_depth"`
+	UtilPercent      float64 `json:"util_percent"`
+}
+
+// IORateReport is the result of a networkio or diskio query
+type IORateReport struct {
+	Interval  string          `json:"interval"`
+	Source    string          `json:"source"`
+	Network   []NetworkIORate `json:"network,omitempty"`
+	Disk      []DiskIORate    `json:"disk,omitempty"`
+	SampledAt time.Time       `json:"sampled_at"`
+}
+
+// ioSamplePair returns two samples to derive rates from. Without an explicit
+// interval the background collector's samples are used when available.
+func ioSamplePair(interval string) (systemSample, systemSample, string, error) {
+	if interval == "" {
+		if prev, cur, ok := collectorSamplePair(); ok {
+			return prev, cur, "collector", nil
+		}
+	}
+
+	d := defaultRateInterval
+	if interval != "" {
+		var err error
+		d, err = parseDurationValue(interval)
+		if err != nil || d <= 0 || d > maxRateInterval {
+			return systemSample{}, systemSample{}, "", fmt.Errorf("invalid interval %q (must be between 0 and %s)", interval, maxRateInterval)
+		}
+	}
+
+	prev := takeSystemSample()
+	time.Sleep(d)
+	return prev, takeSystemSample(), "sampled", nil
+}
+
+// msPerOp returns the average time per operation between two counters
+func msPerOp(prevTime, curTime, prevOps, curOps uint64) float64 {
+	if curOps <= prevOps || curTime < prevTime {
+		return 0
+	}
+	return float64(curTime-prevTime) / float64(curOps-prevOps)
+}
+
+func computeNetworkRates(prev, cur systemSample) []NetworkIORate {
+	elapsed := cur.Time.Sub(prev.Time)
+	var rates []NetworkIORate
+	for name, stat := range cur.Network {
+		p, ok := prev.Network[name]
+		if !ok {
+			continue
+		}
+		rates = append(rates, NetworkIORate{
+			Interface:       name,
+			RxBytesPerSec:   counterRate(p.BytesRecv, stat.BytesRecv, elapsed),
+			TxBytesPerSec:   counterRate(p.BytesSent, stat.BytesSent, elapsed),
+			RxPacketsPerSec: counterRate(p.PacketsRecv, stat.PacketsRecv, elapsed),
+			TxPacketsPerSec: counterRate(p.PacketsSent, stat.PacketsSent, elapsed),
+			ErrorsPerSec:    counterRate(p.Errin+p.Errout, stat.Errin+stat.Errout, elapsed),
+			DropsPerSec:     counterRate(p.Dropin+p.Dropout, stat.Dropin+stat.Dropout, elapsed),
+			RxBytesTotal:    stat.BytesRecv,
+			TxBytesTotal:    stat.BytesSent,
+		})
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Interface < rates[j].Interface })
+	return rates
+}
+
+func computeDiskRates(prev, cur systemSample) []DiskIORate {
+	elapsed := cur.Time.Sub(prev.Time)
+	elapsedMs := float64(elapsed.Milliseconds())
+	var rates []DiskIORate
+	for name, stat := range cur.Disk {
+		p, ok := prev.Disk[name]
+		if !ok {
+			continue
+		}
+		rate := DiskIORate{
+			Device:           name,
+			ReadIOPS:         counterRate(p.ReadCount, stat.ReadCount, elapsed),
+			WriteIOPS:        counterRate(p.WriteCount, stat.WriteCount, elapsed),
+			ReadBytesPerSec:  counterRate(p.ReadBytes, stat.ReadBytes, elapsed),
+			WriteBytesPerSec: counterRate(p.WriteBytes, stat.WriteBytes, elapsed),
+			ReadAwaitMs:      msPerOp(p.ReadTime, stat.ReadTime, p.ReadCount, stat.ReadCount),
+			WriteAwaitMs:     msPerOp(p.WriteTime, stat.WriteTime, p.WriteCount, stat.WriteCount),
+			AwaitMs:          msPerOp(p.ReadTime+p.WriteTime, stat.ReadTime+stat.WriteTime, p.ReadCount+p.WriteCount, stat.ReadCount+stat.WriteCount),
+		}
+		if elapsedMs > 0 {
+			if stat.WeightedIO >= p.WeightedIO {
+				rate.QueueDepth = float64(stat.WeightedIO-p.WeightedIO) / elapsedMs
+			}
+			if stat.IoTime >= p.IoTime {
+				rate.UtilPercent = math.Min(100, 100*float64(stat.IoTime-p.IoTime)/elapsedMs)
+			}
+		}
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Device < rates[j].Device })
+	return rates
+}
+
+func encodeIORateReport(report IORateReport) string {
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
-		return fmt.Sprintf("Error getting network I/O: %v", err)
+		return fmt.Sprintf("Error encoding I/O statistics: %v", err)
 	}
+	return string(data)
+}
 
-	var output strings.Builder
-	output.WriteString("Network I/O Statistics:\n\n")
+// getNetworkIO returns per-interface network throughput rates
+func getNetworkIO(interval string, asJSON bool) string {
+	prev, cur, source, err := ioSamplePair(interval)
+	if err != nil {
+		return fmt.Sprintf("Error getting network I/O: %v", err)
+	}
+	if cur.Network == nil {
+		return "Error getting network I/O: no interface counters available"
+	}
 
-	for _, stat := range stats {
+	rates := computeNetworkRates(prev, cur)
+	for _, r := range rates {
 		// Update Prometheus metrics
-		networkIOBytes.WithLabelValues(stat.Name, "sent").Set(float64(stat.BytesSent))
-		networkIOBytes.WithLabelValues(stat.Name, "recv").Set(float64(stat.BytesRecv))
+		networkIOBytes.WithLabelValues(r.Interface, "sent").Set(float64(r.TxBytesTotal))
+		networkIOBytes.WithLabelValues(r.Interface, "recv").Set(float64(r.RxBytesTotal))
+		networkIORate.WithLabelValues(r.Interface, "sent").Set(r.TxBytesPerSec)
+		networkIORate.WithLabelValues(r.Interface, "recv").Set(r.RxBytesPerSec)
+	}
 
-		output.WriteString(fmt.Sprintf("Interface: %s\n", stat.Name))
-		output.WriteString(fmt.Sprintf("  Bytes Sent: %s\n", formatBytes(stat.BytesSent)))
-		output.WriteString(fmt.Sprintf("  Bytes Received: %s\n", formatBytes(stat.BytesRecv)))
-		output.WriteString(fmt.Sprintf("  Packets Sent: %d\n", stat.PacketsSent))
-		output.WriteString(fmt.Sprintf("  Packets Received: %d\n", stat.PacketsRecv))
-		output.WriteString(fmt.Sprintf("  Errors In: %d, Out: %d\n", stat.Errin, stat.Errout))
-		output.WriteString(fmt.Sprintf("  Drops In: %d, Out: %d\n\n", stat.Dropin, stat.Dropout))
+	elapsed := cur.Time.Sub(prev.Time).Round(time.Millisecond)
+	if asJSON {
+		return encodeIORateReport(IORateReport{Interval: elapsed.String(), Source: source, Network: rates, SampledAt: cur.Time})
 	}
 
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Network I/O Rates (over %s):\n\n", elapsed))
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "INTERFACE\tRX/s\tTX/s\tRX pkt/s\tTX pkt/s\terr/s\tdrop/s\tRX total\tTX total\t")
+	for _, r := range rates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t%.1f\t%.1f\t%.1f\t%s\t%s\t\n", r.Interface,
+			formatBytes(uint64(r.RxBytesPerSec)), formatBytes(uint64(r.TxBytesPerSec)),
+			r.RxPacketsPerSec, r.TxPacketsPerSec, r.ErrorsPerSec, r.DropsPerSec,
+			formatBytes(r.RxBytesTotal), formatBytes(r.TxBytesTotal))
+	}
+	w.Flush()
 	return output.String()
 }
 
-// getDiskIO returns disk I/O statistics
-func getDiskIO() string {
-	ioCounters, err := disk.IOCounters()
+// getDiskIO returns iostat-style per-device disk statistics
+func getDiskIO(interval string, asJSON bool) string {
+	prev, cur, source, err := ioSamplePair(interval)
 	if err != nil {
 		return fmt.Sprintf("Error getting disk I/O: %v", err)
 	}
+	if cur.Disk == nil {
+		return "Error getting disk I/O: no device counters available"
+	}
 
-	var output strings.Builder
-	output.WriteString("Disk I/O Statistics:\n\n")
-
-	for device, stat := range ioCounters {
+	rates := computeDiskRates(prev, cur)
+	for device, stat := range cur.Disk {
 		// Update Prometheus metrics
 		diskIOBytes.WithLabelValues(device, "read").Set(float64(stat.ReadBytes))
 		diskIOBytes.WithLabelValues(device, "write").Set(float64(stat.WriteBytes))
+	}
+	for _, r := range rates {
+		diskIORate.WithLabelValues(r.Device, "read").Set(r.ReadBytesPerSec)
+		diskIORate.WithLabelValues(r.Device, "write").Set(r.WriteBytesPerSec)
+		diskUtilization.WithLabelValues(r.Device).Set(r.UtilPercent)
+	}
 
-		output.WriteString(fmt.Sprintf("Device: %s\n", device))
-		output.WriteString(fmt.Sprintf("  Read: %s (%d ops)\n", formatBytes(stat.ReadBytes), stat.ReadCount))
-		output.WriteString(fmt.Sprintf("  Write: %s (%d ops)\n", formatBytes(stat.WriteBytes), stat.WriteCount))
-		output.WriteString(fmt.Sprintf("  Read Time: %d ms\n", stat.ReadTime))
-		output.WriteString(fmt.Sprintf("  Write Time: %d ms\n", stat.WriteTime))
-		output.WriteString(fmt.Sprintf("  IO Time: %d ms\n\n", stat.IoTime))
+	elapsed := cur.Time.Sub(prev.Time).Round(time.Millisecond)
+	if asJSON {
+		return encodeIORateReport(IORateReport{Interval: elapsed.String(), Source: source, Disk: rates, SampledAt: cur.Time})
 	}
 
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Disk I/O Statistics (over %s):\n\n", elapsed))
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DEVICE\tr/s\tw/s\trB/s\twB/s\tr_await\tw_await\taqu-sz\t%util\t")
+	for _, r := range rates {
+		fmt.Fprintf(w, "%s\t%.1f\t%.1f\t%s\t%s\t%.2f\t%.2f\t%.2f\t%.1f\t\n", r.Device,
+			r.ReadIOPS, r.WriteIOPS, formatBytes(uint64(r.ReadBytesPerSec)), formatBytes(uint64(r.WriteBytesPerSec)),
+			r.ReadAwaitMs, r.WriteAwaitMs, r.QueueDepth, r.UtilPercent)
+	}
+	w.Flush()
 	return output.String()
 }
 
+// parseRateArgs parses the --interval and --json flags shared by networkio and diskio
+func parseRateArgs(name string, args []string) (string, bool, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	interval := fs.String("interval", "", "sampling interval (default: background collector or 1s)")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return "", false, fmt.Errorf("%v\nUsage: osctl %s [--interval 1s] [--json]", err, name)
+	}
+	return *interval, *asJSON, nil
+}
+
 // getProcessCountByState returns count of processes by state
 func getProcessCountByState() string {
 	procs, err := process.Processes()
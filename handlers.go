@@ -19,6 +19,11 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	case "service":
 		action := r.URL.Query().Get("action")
 		service := r.URL.Query().Get("service")
+		if action == "resources" {
+			q := r.URL.Query()
+			result = getServiceResources(service, q.Get("interval"), q.Get("sort"), q.Get("limit"), q.Get("format") == "json")
+			break
+		}
 		if action == "" || service == "" {
 			http.Error(w, "Missing action or service parameter", http.StatusBadRequest)
 			return
@@ -28,9 +33,49 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Service name too long", http.StatusBadRequest)
 			return
 		}
+		if action == "show" {
+			lines, err := parseServiceLogLines(r.URL.Query().Get("lines"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = getServiceDetails(service, lines, r.URL.Query().Get("format") == "json")
+			break
+		}
+		if action == "override" {
+			op := r.URL.Query().Get("op")
+			if op == "" {
+				op = "show"
+			}
+			result = manageServiceOverride(service, op, r.URL.Query()["property"])
+			break
+		}
+		if action == "restart" {
+			q := r.URL.Query()
+			// Arbitrary commands are CLI-only; the API may only use the built-in configuration tests
+			if q.Get("config_test") != "" && q.Get("config_test") != "auto" {
+				http.Error(w, "config_test only accepts 'auto' over the API", http.StatusBadRequest)
+				return
+			}
+			opts, err := newRestartOptions(q.Get("verify") == "true", q.Get("reload_first") == "true", q.Get("config_test"), q.Get("probe"), "", q.Get("timeout"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if opts.safe() {
+				result = restartServiceSafely(service, opts)
+				break
+			}
+		}
 		result = manageService(action, service)
 	case "top":
-		result = getTopProcesses()
+		q := r.URL.Query()
+		opts, err := newTopOptions(q.Get("interval"), q.Get("sort"), q.Get("limit"), q.Get("user"), q.Get("name"), q.Get("format") == "json")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = getTopProcesses(opts)
 	case "errors":
 		result = getLastJournalErrors()
 	case "users":
@@ -54,9 +99,9 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	case "images":
 		result = listDockerImages()
 	case "cpu":
-		result = getCpuUsage()
+		result = getCpuUsage(r.URL.Query().Get("window"), r.URL.Query().Get("format") == "json")
 	case "load":
-		result = getLoadAverage()
+		result = getLoadAverage(r.URL.Query().Get("format") == "json")
 	case "network":
 		result = getNetworkStats()
 	case "connections":
@@ -68,7 +113,13 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	case "who":
 		result = getLoggedinUsers()
 	case "services":
-		result = getServiceStatuses()
+		q := r.URL.Query()
+		opts, err := newServiceListOptions(q.Get("state"), q.Get("type"), q.Get("pattern"), q.Get("sort"), q.Get("enabled") == "true", q.Get("format") == "json")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = getServiceStatuses(opts)
 	case "health":
 		result = getHealthCheck()
 	case "process":
@@ -82,35 +133,96 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Missing pid parameter", http.StatusBadRequest)
 				return
 			}
-			result = killProcess(pid)
+			result = killProcess(pid, apiProcessGuard(r))
 		case "killforce":
 			if pid == "" {
 				http.Error(w, "Missing pid parameter", http.StatusBadRequest)
 				return
 			}
-			result = killProcessForce(pid)
+			result = killProcessForce(pid, apiProcessGuard(r))
+		case "signal":
+			q := r.URL.Query()
+			target := q.Get("target")
+			if target == "" {
+				target = pid
+			}
+			if target == "" {
+				http.Error(w, "Missing target (pid, name or pattern) parameter", http.StatusBadRequest)
+				return
+			}
+			opts, err := newSignalOptions(q.Get("signal"), q.Get("user"), q.Get("timeout"), q.Get("tree") == "true", q.Get("graceful") == "true")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = processSignal(target, opts, apiProcessGuard(r), q.Get("yes") == "true", q.Get("format") == "json")
 		case "nice":
 			if pid == "" || priority == "" {
 				http.Error(w, "Missing pid or priority parameter", http.StatusBadRequest)
 				return
 			}
-			result = setProcessPriority(pid, priority)
+			result = setProcessPriority(pid, priority, apiProcessGuard(r))
+		case "ionice", "affinity", "limit":
+			if pid == "" {
+				http.Error(w, "Missing pid parameter", http.StatusBadRequest)
+				return
+			}
+			q := r.URL.Query()
+			asJSON := q.Get("format") == "json"
+			// Realtime I/O and resource limits can starve or unbound the host
+			if (action == "ionice" && q.Get("class") == "realtime") || (action == "limit" && len(q["limit"]) > 0) {
+				if !requestIdentity(r).Admin {
+					http.Error(w, "This change requires admin scope", http.StatusForbidden)
+					return
+				}
+			}
+			switch action {
+			case "ionice":
+				result = setProcessIOPriority(pid, q.Get("class"), q.Get("level"), apiProcessGuard(r), asJSON)
+			case "affinity":
+				result = setProcessAffinity(pid, q.Get("cpus"), apiProcessGuard(r), asJSON)
+			default:
+				result = setProcessLimits(pid, q["limit"], apiProcessGuard(r), asJSON)
+			}
 		case "info":
 			if pid == "" {
 				http.Error(w, "Missing pid parameter", http.StatusBadRequest)
 				return
 			}
-			result = getProcessInfo(pid)
+			q := r.URL.Query()
+			showEnv := q.Get("show_env") == "true"
+			if showEnv && !requestIdentity(r).Admin {
+				http.Error(w, "show_env requires admin scope", http.StatusForbidden)
+				return
+			}
+			result = getProcessInfo(pid, showEnv, q.Get("format") == "json")
+		case "watch":
+			streamProcessEvents(w, r)
+			return
+		case "problems":
+			q := r.URL.Query()
+			opts, err := newProcessProblemOptions(q.Get("duration"), q.Get("cpu"), q.Get("rss_mb"), q.Get("fds"), q.Get("format") == "json")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = getProcessProblems(opts)
 		case "tree":
-			result = getProcessTree()
+			q := r.URL.Query()
+			opts, err := newProcessTreeOptions(pid, q.Get("user"), q.Get("unit"), q.Get("interval"), q.Get("format") == "json")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = getProcessTree(opts)
 		default:
-			http.Error(w, "Invalid process action. Valid: kill, killforce, nice, info, tree", http.StatusBadRequest)
+			http.Error(w, "Invalid process action. Valid: kill, killforce, signal, nice, ionice, affinity, limit, info, tree, problems, watch", http.StatusBadRequest)
 			return
 		}
 	case "networkio":
-		result = getNetworkIO()
+		result = getNetworkIO(r.URL.Query().Get("interval"), r.URL.Query().Get("format") == "json")
 	case "diskio":
-		result = getDiskIO()
+		result = getDiskIO(r.URL.Query().Get("interval"), r.URL.Query().Get("format") == "json")
 	case "procs":
 		result = getProcessCountByState()
 	case "audit":
@@ -159,13 +271,94 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid cron action. Valid: list, add, remove, next", http.StatusBadRequest)
 			return
 		}
+	case "watchdog":
+		result = getWatchdogStatus()
+	case "auditlog":
+		q := r.URL.Query()
+		result = getAuditLog(q.Get("since"), q.Get("source"), q.Get("limit"), q.Get("format") == "json")
+	case "boot":
+		limit, err := parseBootLimit(r.URL.Query().Get("limit"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = getBootAnalysis(limit, r.URL.Query().Get("format") == "json")
+	case "memory":
+		q := r.URL.Query()
+		opts, err := newMemoryOptions(q.Get("interval"), q.Get("slabs"), q.Get("oom"), q.Get("format") == "json")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = getMemoryReport(opts)
+	case "pressure":
+		result = getPressure(r.URL.Query().Get("format") == "json")
+	case "timer":
+		q := r.URL.Query()
+		action := q.Get("action")
+		name := q.Get("name")
+		switch action {
+		case "", "list":
+			result = getTimers(q.Get("format") == "json")
+		case "create", "delete", "run-now":
+			if name == "" {
+				http.Error(w, "Missing name parameter", http.StatusBadRequest)
+				return
+			}
+			// Timers run their command as root
+			if !requestIdentity(r).Admin {
+				http.Error(w, "Timer "+action+" requires admin scope", http.StatusForbidden)
+				return
+			}
+			switch action {
+			case "create":
+				result = createTimer(name, q.Get("calendar"), q.Get("command"), q.Get("description"), q.Get("persistent") == "true")
+			case "delete":
+				result = deleteTimer(name)
+			default:
+				result = runTimerNow(name)
+			}
+		case "validate":
+			calendar := q.Get("calendar")
+			if calendar == "" {
+				http.Error(w, "Missing calendar parameter", http.StatusBadRequest)
+				return
+			}
+			count, err := parseTimerPreviewCount(q.Get("count"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = validateCalendar(calendar, count, q.Get("format") == "json")
+		default:
+			http.Error(w, "Invalid timer action. Valid: list, create, delete, run-now, validate", http.StatusBadRequest)
+			return
+		}
 	case "maintenance":
 		action := r.URL.Query().Get("action")
 		if action == "" {
 			http.Error(w, "Missing action parameter. Valid: status, enable, disable, check-services, restart-failed, sync-time, clear-cache", http.StatusBadRequest)
 			return
 		}
+		if action == "restart-failed" {
+			q := r.URL.Query()
+			opts, err := newRestartFailedOptions(q.Get("include"), q.Get("exclude"), q.Get("max_attempts"), q.Get("backoff"),
+				q.Get("reset_failed") == "true", q.Get("dry_run") == "true", q.Get("format") == "json")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = getRestartFailed(opts)
+			break
+		}
 		result = getMaintenanceActions(action)
+	case "history", "v1/history":
+		metric := r.URL.Query().Get("metric")
+		if metric == "" {
+			http.Error(w, "Missing metric parameter. Valid: "+historyMetricNames(), http.StatusBadRequest)
+			return
+		}
+		result = getHistory(metric, r.URL.Query().Get("since"), r.URL.Query().Get("step"), r.URL.Query().Get("format") == "json")
 	default:
 		http.Error(w, "Unknown command", http.StatusNotFound)
 		return
@@ -180,10 +373,17 @@ func printHelp() {
 
 Commands:
   ram          Show RAM usage
+  memory       Memory deep-dive: buffers/cache, swap rates, pressure, slab, hugepages, NUMA, OOM kills
+               (--interval 1s, --slabs 10, --oom 5, --json)
   disk         Show disk usage
   service      Manage system services
-               Usage: osctl service [start|stop|restart|status|enable|disable] [service_name]
-  top          Show top processes by CPU usage
+               Usage: osctl service [start|stop|restart|reload|status|enable|disable|mask|unmask] [service_name]
+                      osctl service show <service_name> [--lines 20] [--json]
+                      osctl service restart <service_name> [--verify] [--probe tcp://host:port|http://url] [--probe-cmd CMD]
+                                            [--reload-first] [--config-test auto|CMD] [--timeout 30s]
+                      osctl service override <service_name> [show|set Key=Value...|unset Key...]
+                      osctl service resources [service_name] [--sort memory|cpu|io|pids|pressure] [--limit 20] [--interval 1s] [--json]
+  top          Show top processes (--interval, --sort cpu|mem|rss|io|threads|fds, --limit, --user, --name, --json)
   errors       Show last 10 errors from the journal
   users        Show last 20 logged in users
   uptime       Show system uptime
@@ -195,22 +395,32 @@ Commands:
   update       Update OS packages
   containers   List all Docker containers
   images       List all Docker images
-  cpu          Show CPU usage
-  load         Show system load averages
+  cpu          Show CPU usage per mode and per core (--window 1s, --json)
+  load         Show load averages, per CPU, runnable/total tasks and trend (--json)
   network      Show network statistics
   connections  List all active network connections
   filesystems  List all mounted filesystems
   dmesg        Show kernel messages
   who          List all currently logged in users
-  services     Show status of all running services
-  health       Show health check status
-  process      Process management (kill, nice, info, tree)
-  networkio    Show network I/O statistics
-  diskio       Show disk I/O statistics
+  services     List units (default: running services)
+               Usage: osctl services [--state running|active|failed|inactive|all] [--type service|timer|socket|mount|...|all] [--pattern GLOB] [--enabled] [--sort name|memory|restarts|state] [--json]
+  health       Show health check status (usage thresholds; load and pressure checks are off unless OSCTL_HEALTH_LOAD or OSCTL_HEALTH_PRESSURE is set)
+  pressure     Show pressure stall information for CPU, memory and I/O (--json)
+  process      Process management (kill, killforce, signal, nice, ionice, affinity, limit, info, tree, problems, watch; protected processes need --override)
+  networkio    Show network I/O rates per interface (--interval 1s, --json)
+  diskio       Show disk I/O rates, latency and utilization per device (--interval 1s, --json)
   procs        Show process count by state
   audit        Security audit (ports, files, permissions, users, ssh, summary)
   cron         Cron job management (list, add, remove, next)
+  boot         Boot time breakdown, slowest units, critical chain and failed units (--limit 10, --json)
+  watchdog     Show the service watchdog policy and its recent actions (configured via OSCTL_WATCHDOG_*)
+  auditlog     Show the audit log (--since 24h, --source watchdog, --limit 50, --json)
+  timer        Systemd timer management (list, create, delete, run-now, validate)
+               Usage: osctl timer create <name> --on-calendar SPEC --command CMD [--description TEXT] [--persistent]
+                      osctl timer validate <calendar spec> [--count 5] [--json]
   maintenance  Maintenance mode and system operations (status, enable, disable, check-services, restart-failed, sync-time, clear-cache)
+  dashboard    Interactive live dashboard (--refresh 1s)
+  history      Show recorded metric history (history <metric> --since 1h)
   api          Run as an API server (default port: 12000)
   --help       Show this help message`)
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/mem"
 )
@@ -31,96 +30,132 @@ type HealthResponse struct {
 	Uptime    string                 `json:"uptime"`
 }
 
-func getHealthCheck() string {
+// sampleHealthCPU samples CPU usage with the same window as the cpu command
+func sampleHealthCPU() (CPUReport, error) {
+	window, err := getCPUWindow("")
+	if err != nil {
+		return CPUReport{}, err
+	}
+	return sampleCPU(window)
+}
+
+// collectHealth runs all health checks
+func collectHealth() HealthResponse {
 	checks := make(map[string]HealthCheck)
 	overallStatus := StatusHealthy
 
 	// Check Memory
-	v, err := mem.VirtualMemory()
-	if err != nil {
-		checks["memory"] = HealthCheck{
-			Status:  StatusUnhealthy,
-			Message: fmt.Sprintf("Failed to get memory info: %v", err),
-		}
-		overallStatus = StatusUnhealthy
-	} else {
-		memStatus := StatusHealthy
-		if v.UsedPercent > 90 {
-			memStatus = StatusUnhealthy
-			overallStatus = StatusDegraded
-		} else if v.UsedPercent > 80 {
-			memStatus = StatusDegraded
-			if overallStatus == StatusHealthy {
+	if usageCheckEnabled("memory") {
+		v, err := mem.VirtualMemory()
+		if err != nil {
+			checks["memory"] = HealthCheck{
+				Status:  StatusUnhealthy,
+				Message: fmt.Sprintf("Failed to get memory info: %v", err),
+			}
+			overallStatus = StatusUnhealthy
+		} else {
+			memStatus := StatusHealthy
+			if v.UsedPercent > 90 {
+				memStatus = StatusUnhealthy
 				overallStatus = StatusDegraded
+			} else if v.UsedPercent > 80 {
+				memStatus = StatusDegraded
+				if overallStatus == StatusHealthy {
+					overallStatus = StatusDegraded
+				}
+			}
+			checks["memory"] = HealthCheck{
+				Status:  memStatus,
+				Value:   fmt.Sprintf("%.2f%%", v.UsedPercent),
+				Message: fmt.Sprintf("Used: %d MB / Total: %d MB", v.Used/1024/1024, v.Total/1024/1024),
 			}
-		}
-		checks["memory"] = HealthCheck{
-			Status:  memStatus,
-			Value:   fmt.Sprintf("%.2f%%", v.UsedPercent),
-			Message: fmt.Sprintf("Used: %d MB / Total: %d MB", v.Used/1024/1024, v.Total/1024/1024),
 		}
 	}
 
 	// Check Disk Space
-	d, err := disk.Usage("/")
-	if err != nil {
-		checks["disk"] = HealthCheck{
-			Status:  StatusUnhealthy,
-			Message: fmt.Sprintf("Failed to get disk info: %v", err),
-		}
-		overallStatus = StatusUnhealthy
-	} else {
-		diskStatus := StatusHealthy
-		if d.UsedPercent > 95 {
-			diskStatus = StatusUnhealthy
-			overallStatus = StatusDegraded
-		} else if d.UsedPercent > 85 {
-			diskStatus = StatusDegraded
-			if overallStatus == StatusHealthy {
+	if usageCheckEnabled("disk") {
+		d, err := disk.Usage("/")
+		if err != nil {
+			checks["disk"] = HealthCheck{
+				Status:  StatusUnhealthy,
+				Message: fmt.Sprintf("Failed to get disk info: %v", err),
+			}
+			overallStatus = StatusUnhealthy
+		} else {
+			diskStatus := StatusHealthy
+			if d.UsedPercent > 95 {
+				diskStatus = StatusUnhealthy
 				overallStatus = StatusDegraded
+			} else if d.UsedPercent > 85 {
+				diskStatus = StatusDegraded
+				if overallStatus == StatusHealthy {
+					overallStatus = StatusDegraded
+				}
+			}
+			checks["disk"] = HealthCheck{
+				Status:  diskStatus,
+				Value:   fmt.Sprintf("%.2f%%", d.UsedPercent),
+				Message: fmt.Sprintf("Used: %d GB / Total: %d GB", d.Used/1024/1024/1024, d.Total/1024/1024/1024),
 			}
-		}
-		checks["disk"] = HealthCheck{
-			Status:  diskStatus,
-			Value:   fmt.Sprintf("%.2f%%", d.UsedPercent),
-			Message: fmt.Sprintf("Used: %d GB / Total: %d GB", d.Used/1024/1024/1024, d.Total/1024/1024/1024),
 		}
 	}
 
 	// Check CPU
-	cpuPercent, err := cpu.Percent(time.Second, false)
-	if err != nil {
-		checks["cpu"] = HealthCheck{
-			Status:  StatusUnhealthy,
-			Message: fmt.Sprintf("Failed to get CPU info: %v", err),
-		}
-		overallStatus = StatusUnhealthy
-	} else {
-		cpuStatus := StatusHealthy
-		if cpuPercent[0] > 95 {
-			cpuStatus = StatusDegraded
-			if overallStatus == StatusHealthy {
-				overallStatus = StatusDegraded
+	if usageCheckEnabled("cpu") {
+		cpuReport, err := sampleHealthCPU()
+		if err != nil {
+			checks["cpu"] = HealthCheck{
+				Status:  StatusUnhealthy,
+				Message: fmt.Sprintf("Failed to get CPU info: %v", err),
+			}
+			overallStatus = StatusUnhealthy
+		} else {
+			updateCPUMetrics(cpuReport)
+			cpuStatus := StatusHealthy
+			if cpuReport.Total.Usage > 95 {
+				cpuStatus = StatusDegraded
+				if overallStatus == StatusHealthy {
+					overallStatus = StatusDegraded
+				}
+			}
+			checks["cpu"] = HealthCheck{
+				Status: cpuStatus,
+				Value:  fmt.Sprintf("%.2f%%", cpuReport.Total.Usage),
+				Message: fmt.Sprintf("CPU usage over %s (iowait %.2f%%, steal %.2f%%)",
+					cpuReport.Window, cpuReport.Total.Iowait, cpuReport.Total.Steal),
 			}
 		}
-		checks["cpu"] = HealthCheck{
-			Status:  cpuStatus,
-			Value:   fmt.Sprintf("%.2f%%", cpuPercent[0]),
-			Message: "CPU usage",
+	}
+
+	// Check load per CPU against OSCTL_HEALTH_LOAD
+	if check, ok := checkLoad(); ok {
+		checks["load"] = check
+		if check.Status != StatusHealthy && overallStatus == StatusHealthy {
+			overallStatus = StatusDegraded
+		}
+	}
+
+	// Check pressure stall information against OSCTL_HEALTH_PRESSURE
+	for name, check := range checkPressure() {
+		checks[name] = check
+		if check.Status != StatusHealthy && overallStatus == StatusHealthy {
+			overallStatus = StatusDegraded
 		}
 	}
 
 	// Get uptime
 	uptimeStr := getUptime()
 
-	response := HealthResponse{
+	return HealthResponse{
 		Status:    overallStatus,
 		Timestamp: time.Now(),
 		Checks:    checks,
 		Uptime:    uptimeStr,
 	}
+}
 
-	jsonData, err := json.MarshalIndent(response, "", "  ")
+func getHealthCheck() string {
+	jsonData, err := json.MarshalIndent(collectHealth(), "", "  ")
 	if err != nil {
 		return fmt.Sprintf("Error encoding health check: %v", err)
 	}
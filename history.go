@@ -0,0 +1,620 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+)
+
+const (
+	defaultHistoryPath      = "/var/lib/osctl/history.db"
+	defaultHistoryInterval  = 10 * time.Second
+	defaultHistoryRetention = 24 * time.Hour
+
+	// historyMaxPoints is the number of buckets a query is downsampled to
+	// when no explicit step is given.
+	historyMaxPoints = 60
+
+	historyMagic       = "OSCTLH01"
+	historyHeaderSize  = 40
+	historyMetricCount = 7
+	historyRecordSize  = 8 + 8*historyMetricCount
+)
+
+// historyMetric describes one recorded series. The order of historyMetrics is
+// the column order of a record on disk and must not change.
+type historyMetric struct {
+	Name        string
+	Description string
+	Unit        string
+}
+
+var historyMetrics = [historyMetricCount]historyMetric{
+	{"cpu", "CPU usage", "%"},
+	{"memory", "Memory usage", "%"},
+	{"load1", "1 minute load average", ""},
+	{"net_rx", "Network receive rate", "B/s"},
+	{"net_tx", "Network transmit rate", "B/s"},
+	{"disk_read", "Disk read rate", "B/s"},
+	{"disk_write", "Disk write rate", "B/s"},
+}
+
+// HistoryConfig controls where and how often samples are recorded
+type HistoryConfig struct {
+	Path      string
+	Interval  time.Duration
+	Retention time.Duration
+}
+
+// historyRecord is a single sample of all recorded series
+type historyRecord struct {
+	Time   time.Time
+	Values [historyMetricCount]float64
+}
+
+// HistoryPoint is one downsampled bucket of a series
+type HistoryPoint struct {
+	Time  time.Time `json:"time"`
+	Avg   float64   `json:"avg"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int       `json:"samples"`
+}
+
+// HistoryResponse is the result of a history query
+type HistoryResponse struct {
+	Metric string         `json:"metric"`
+	Unit   string         `json:"unit,omitempty"`
+	Since  time.Time      `json:"since"`
+	Step   string         `json:"step"`
+	Points []HistoryPoint `json:"points"`
+}
+
+// getHistoryConfig reads the history configuration from the environment.
+// Setting OSCTL_HISTORY_INTERVAL to 0 disables collection.
+func getHistoryConfig() HistoryConfig {
+	cfg := HistoryConfig{
+		Path:      os.Getenv("OSCTL_HISTORY_PATH"),
+		Interval:  defaultHistoryInterval,
+		Retention: defaultHistoryRetention,
+	}
+	if cfg.Path == "" {
+		cfg.Path = defaultHistoryPath
+	}
+	if v := os.Getenv("OSCTL_HISTORY_INTERVAL"); v != "" {
+		if d, err := parseDurationValue(v); err == nil {
+			cfg.Interval = d
+		} else {
+			log.Printf("Ignoring invalid OSCTL_HISTORY_INTERVAL %q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("OSCTL_HISTORY_RETENTION"); v != "" {
+		if d, err := parseDurationValue(v); err == nil && d > 0 {
+			cfg.Retention = d
+		} else {
+			log.Printf("Ignoring invalid OSCTL_HISTORY_RETENTION %q", v)
+		}
+	}
+	return cfg
+}
+
+// parseDurationValue parses a Go duration, additionally accepting a "d" suffix for days
+func parseDurationValue(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseSince accepts a relative duration ("1h", "2d") or an RFC3339 timestamp
+func parseSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := parseDurationValue(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since value %q (use e.g. 30m, 6h, 2d or an RFC3339 time)", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// historyStore is a fixed-size ring of samples kept in a single file, so the
+// file never grows beyond header + capacity*record bytes.
+type historyStore struct {
+	mu       sync.Mutex
+	file     *os.File
+	interval time.Duration
+	capacity uint64
+	next     uint64
+	count    uint64
+}
+
+type historyHeader struct {
+	Interval time.Duration
+	Capacity uint64
+	Next     uint64
+	Count    uint64
+}
+
+func readHistoryHeader(r io.ReaderAt) (historyHeader, error) {
+	var h historyHeader
+	buf := make([]byte, historyHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return h, err
+	}
+	if string(buf[:8]) != historyMagic {
+		return h, errors.New("not an osctl history file")
+	}
+	h.Interval = time.Duration(binary.LittleEndian.Uint64(buf[8:16]))
+	h.Capacity = binary.LittleEndian.Uint64(buf[16:24])
+	h.Next = binary.LittleEndian.Uint64(buf[24:32])
+	h.Count = binary.LittleEndian.Uint64(buf[32:40])
+	if h.Capacity == 0 || h.Next >= h.Capacity || h.Count > h.Capacity {
+		return h, errors.New("corrupt history header")
+	}
+	return h, nil
+}
+
+// readHistoryRecords returns all records in chronological order
+func readHistoryRecords(r io.ReaderAt, h historyHeader) ([]historyRecord, error) {
+	records := make([]historyRecord, 0, h.Count)
+	buf := make([]byte, historyRecordSize)
+	start := (h.Next + h.Capacity - h.Count) % h.Capacity
+	for i := uint64(0); i < h.Count; i++ {
+		slot := (start + i) % h.Capacity
+		if _, err := r.ReadAt(buf, int64(historyHeaderSize+slot*historyRecordSize)); err != nil {
+			return nil, err
+		}
+		var rec historyRecord
+		rec.Time = time.Unix(int64(binary.LittleEndian.Uint64(buf[0:8])), 0)
+		for j := range rec.Values {
+			off := 8 + j*8
+			rec.Values[j] = math.Float64frombits(binary.LittleEndian.Uint64(buf[off : off+8]))
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// openHistoryStore opens or creates the history file. If the configured
+// retention or interval changed, the newest samples are carried over.
+func openHistoryStore(cfg HistoryConfig) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := uint64(cfg.Retention / cfg.Interval)
+	if capacity == 0 {
+		capacity = 1
+	}
+	s := &historyStore{file: f, interval: cfg.Interval, capacity: capacity}
+
+	var existing []historyRecord
+	if h, err := readHistoryHeader(f); err == nil {
+		if h.Capacity == capacity && h.Interval == cfg.Interval {
+			s.next, s.count = h.Next, h.Count
+			return s, nil
+		}
+		existing, _ = readHistoryRecords(f, h)
+	}
+
+	if err := f.Truncate(int64(historyHeaderSize + capacity*historyRecordSize)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if uint64(len(existing)) > capacity {
+		existing = existing[uint64(len(existing))-capacity:]
+	}
+	for _, rec := range existing {
+		if err := s.writeRecord(rec); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := s.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *historyStore) writeHeader() error {
+	buf := make([]byte, historyHeaderSize)
+	copy(buf, historyMagic)
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(s.interval))
+	binary.LittleEndian.PutUint64(buf[16:24], s.capacity)
+	binary.LittleEndian.PutUint64(buf[24:32], s.next)
+	binary.LittleEndian.PutUint64(buf[32:40], s.count)
+	_, err := s.file.WriteAt(buf, 0)
+	return err
+}
+
+func (s *historyStore) writeRecord(rec historyRecord) error {
+	buf := make([]byte, historyRecordSize)
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(rec.Time.Unix()))
+	for j, v := range rec.Values {
+		off := 8 + j*8
+		binary.LittleEndian.PutUint64(buf[off:off+8], math.Float64bits(v))
+	}
+	if _, err := s.file.WriteAt(buf, int64(historyHeaderSize+s.next*historyRecordSize)); err != nil {
+		return err
+	}
+	s.next = (s.next + 1) % s.capacity
+	if s.count < s.capacity {
+		s.count++
+	}
+	return nil
+}
+
+// Append stores a sample, overwriting the oldest one once the ring is full
+func (s *historyStore) Append(rec historyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeRecord(rec); err != nil {
+		return err
+	}
+	return s.writeHeader()
+}
+
+// systemSample holds raw counters from which rates and percentages are derived
+type systemSample struct {
+	Time    time.Time
+	CPU     cpu.TimesStat
+	Memory  float64
+	Load1   float64
+	Network map[string]net.IOCountersStat
+	Disk    map[string]disk.IOCountersStat
+}
+
+// takeSystemSample reads the current counters. Individual collector errors
+// leave the corresponding fields zero rather than failing the whole sample.
+func takeSystemSample() systemSample {
+	s := systemSample{Time: time.Now()}
+	if times, err := cpu.Times(false); err == nil && len(times) > 0 {
+		s.CPU = times[0]
+	}
+	if v, err := mem.VirtualMemory(); err == nil {
+		s.Memory = v.UsedPercent
+	}
+	if avg, err := load.Avg(); err == nil {
+		s.Load1 = avg.Load1
+	}
+	if stats, err := net.IOCounters(true); err == nil {
+		s.Network = make(map[string]net.IOCountersStat, len(stats))
+		for _, stat := range stats {
+			s.Network[stat.Name] = stat
+		}
+	}
+	if stats, err := disk.IOCounters(); err == nil {
+		s.Disk = stats
+	}
+	return s
+}
+
+// counterRate returns the per-second rate of a monotonic counter, treating
+// counter resets as zero
+func counterRate(prev, cur uint64, elapsed time.Duration) float64 {
+	if cur < prev || elapsed <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / elapsed.Seconds()
+}
+
+// sysfsRoot is where sysfs is mounted
+var sysfsRoot = "/sys"
+
+// isVirtualDevice reports whether a sysfs device link resolves below
+// /sys/devices/virtual (loopback, bridges, veth, loop, device-mapper, md, zram)
+func isVirtualDevice(link string) bool {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(target, "/devices/virtual/")
+}
+
+// isPhysicalInterface reports whether traffic of an interface should count
+// towards the host total. Virtual interfaces carry traffic that is also
+// counted on a physical one.
+func isPhysicalInterface(name string) bool {
+	if name == "lo" {
+		return false
+	}
+	return !isVirtualDevice(filepath.Join(sysfsRoot, "class", "net", name))
+}
+
+// isWholeDisk reports whether a device from /proc/diskstats is a physical
+// disk. Partitions are not listed in /sys/block, and stacked devices (dm-*,
+// md*, loop*) are virtual, so each byte is counted once.
+func isWholeDisk(name string) bool {
+	link := filepath.Join(sysfsRoot, "block", strings.ReplaceAll(name, "/", "!"))
+	if _, err := os.Lstat(link); err != nil {
+		return false
+	}
+	return !isVirtualDevice(link)
+}
+
+func buildHistoryRecord(prev, cur systemSample) historyRecord {
+	elapsed := cur.Time.Sub(prev.Time)
+	rec := historyRecord{Time: cur.Time}
+	rec.Values[0] = cpuBusyPercent(prev.CPU, cur.CPU)
+	rec.Values[1] = cur.Memory
+	rec.Values[2] = cur.Load1
+	for name, stat := range cur.Network {
+		if !isPhysicalInterface(name) {
+			continue
+		}
+		if p, ok := prev.Network[name]; ok {
+			rec.Values[3] += counterRate(p.BytesRecv, stat.BytesRecv, elapsed)
+			rec.Values[4] += counterRate(p.BytesSent, stat.BytesSent, elapsed)
+		}
+	}
+	for name, stat := range cur.Disk {
+		if !isWholeDisk(name) {
+			continue
+		}
+		if p, ok := prev.Disk[name]; ok {
+			rec.Values[5] += counterRate(p.ReadBytes, stat.ReadBytes, elapsed)
+			rec.Values[6] += counterRate(p.WriteBytes, stat.WriteBytes, elapsed)
+		}
+	}
+	return rec
+}
+
+var (
+	collectorMu       sync.Mutex
+	collectorInterval time.Duration
+	collectorSamples  [2]systemSample
+)
+
+// recordCollectorSample keeps the two most recent background samples so
+// rate queries can be answered without sampling again
+func recordCollectorSample(s systemSample) {
+	collectorMu.Lock()
+	defer collectorMu.Unlock()
+	collectorSamples[0], collectorSamples[1] = collectorSamples[1], s
+}
+
+// collectorSamplePair returns the previous and latest background samples if
+// the collector is running and the latest sample is still fresh
+func collectorSamplePair() (systemSample, systemSample, bool) {
+	collectorMu.Lock()
+	defer collectorMu.Unlock()
+	prev, cur := collectorSamples[0], collectorSamples[1]
+	if collectorInterval <= 0 || prev.Time.IsZero() || time.Since(cur.Time) > 2*collectorInterval {
+		return systemSample{}, systemSample{}, false
+	}
+	return prev, cur, true
+}
+
+// startHistoryCollector begins recording samples in the background. Failure to
+// open the store is logged and never stops the API server.
+func startHistoryCollector() {
+	cfg := getHistoryConfig()
+	if cfg.Interval <= 0 {
+		log.Printf("History collection disabled")
+		return
+	}
+	store, err := openHistoryStore(cfg)
+	if err != nil {
+		log.Printf("History collection disabled: %v", err)
+		return
+	}
+	log.Printf("Recording history to %s every %s (retention %s)", cfg.Path, cfg.Interval, cfg.Retention)
+	go runHistoryCollector(store, cfg.Interval)
+}
+
+func runHistoryCollector(store *historyStore, interval time.Duration) {
+	collectorMu.Lock()
+	collectorInterval = interval
+	collectorMu.Unlock()
+
+	prev := takeSystemSample()
+	recordCollectorSample(prev)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		cur := takeSystemSample()
+		recordCollectorSample(cur)
+		rec := buildHistoryRecord(prev, cur)
+		cpuUsage.Set(rec.Values[0])
+		if err := store.Append(rec); err != nil {
+			log.Printf("Failed to record history sample: %v", err)
+		}
+		prev = cur
+	}
+}
+
+func findHistoryMetric(name string) (int, bool) {
+	for i, m := range historyMetrics {
+		if m.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func historyMetricNames() string {
+	names := make([]string, 0, len(historyMetrics))
+	for _, m := range historyMetrics {
+		names = append(names, m.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// downsampleHistory averages the selected series into buckets of step width,
+// aligned to multiples of step
+func downsampleHistory(records []historyRecord, index int, since time.Time, step time.Duration) []HistoryPoint {
+	points := []HistoryPoint{}
+	var cur *HistoryPoint
+	var sum float64
+	for _, rec := range records {
+		if rec.Time.Before(since) {
+			continue
+		}
+		bucket := rec.Time.Truncate(step)
+		v := rec.Values[index]
+		if cur == nil || !cur.Time.Equal(bucket) {
+			if cur != nil {
+				cur.Avg = sum / float64(cur.Count)
+				points = append(points, *cur)
+			}
+			cur = &HistoryPoint{Time: bucket, Min: v, Max: v}
+			sum = 0
+		}
+		sum += v
+		cur.Count++
+		cur.Min = math.Min(cur.Min, v)
+		cur.Max = math.Max(cur.Max, v)
+	}
+	if cur != nil {
+		cur.Avg = sum / float64(cur.Count)
+		points = append(points, *cur)
+	}
+	return points
+}
+
+// queryHistory reads the recorded samples for metric since the given time
+func queryHistory(metric string, since time.Time, step time.Duration) (HistoryResponse, error) {
+	index, ok := findHistoryMetric(metric)
+	if !ok {
+		return HistoryResponse{}, fmt.Errorf("unknown metric %q. Valid metrics: %s", metric, historyMetricNames())
+	}
+
+	cfg := getHistoryConfig()
+	f, err := os.Open(cfg.Path)
+	if err != nil {
+		return HistoryResponse{}, fmt.Errorf("no history recorded yet (is 'osctl api' running?): %v", err)
+	}
+	defer f.Close()
+
+	h, err := readHistoryHeader(f)
+	if err != nil {
+		return HistoryResponse{}, err
+	}
+	records, err := readHistoryRecords(f, h)
+	if err != nil {
+		return HistoryResponse{}, err
+	}
+
+	if step <= 0 {
+		step = time.Since(since) / historyMaxPoints
+		step = step.Round(time.Second)
+	}
+	if step < h.Interval {
+		step = h.Interval
+	}
+
+	return HistoryResponse{
+		Metric: metric,
+		Unit:   historyMetrics[index].Unit,
+		Since:  since,
+		Step:   step.String(),
+		Points: downsampleHistory(records, index, since, step),
+	}, nil
+}
+
+func formatHistoryValue(v float64, unit string) string {
+	switch unit {
+	case "B/s":
+		return formatBytes(uint64(v)) + "/s"
+	case "%":
+		return fmt.Sprintf("%.2f%%", v)
+	default:
+		return fmt.Sprintf("%.2f", v)
+	}
+}
+
+// getHistory returns the downsampled history of a metric as text or JSON
+func getHistory(metric, since, step string, asJSON bool) string {
+	if since == "" {
+		since = "1h"
+	}
+	sinceTime, err := parseSince(since)
+	if err != nil {
+		return err.Error()
+	}
+	var stepDur time.Duration
+	if step != "" {
+		stepDur, err = parseDurationValue(step)
+		if err != nil || stepDur <= 0 {
+			return fmt.Sprintf("Invalid step %q", step)
+		}
+	}
+
+	resp, err := queryHistory(metric, sinceTime, stepDur)
+	if err != nil {
+		return fmt.Sprintf("Error reading history: %v", err)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Error encoding history: %v", err)
+		}
+		return string(data)
+	}
+
+	index, _ := findHistoryMetric(metric)
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("%s history since %s (step %s):\n\n",
+		historyMetrics[index].Description, resp.Since.Format("2006-01-02 15:04:05"), resp.Step))
+	if len(resp.Points) == 0 {
+		output.WriteString("No samples recorded in this period\n")
+		return output.String()
+	}
+
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tAVG\tMIN\tMAX")
+	for _, p := range resp.Points {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Time.Format("2006-01-02 15:04:05"),
+			formatHistoryValue(p.Avg, resp.Unit), formatHistoryValue(p.Min, resp.Unit), formatHistoryValue(p.Max, resp.Unit))
+	}
+	w.Flush()
+	return output.String()
+}
+
+// runHistoryCommand handles `osctl history <metric> [--since 1h] [--step 1m] [--json]`
+func runHistoryCommand(args []string) string {
+	usage := fmt.Sprintf("Usage: osctl history <metric> [--since 1h] [--step 1m] [--json]\nMetrics: %s", historyMetricNames())
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	since := fs.String("since", "1h", "how far back to query")
+	step := fs.String("step", "", "bucket width for downsampling")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getHistory(args[0], *since, *step, *asJSON)
+}
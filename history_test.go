@@ -0,0 +1,218 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/net"
+)
+
+func historyTestRecord(sec int64) historyRecord {
+	rec := historyRecord{Time: time.Unix(sec, 0)}
+	for i := range rec.Values {
+		rec.Values[i] = float64(sec*10 + int64(i))
+	}
+	return rec
+}
+
+func readHistoryFile(t *testing.T, path string) []historyRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	h, err := readHistoryHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := readHistoryRecords(f, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func historySeconds(records []historyRecord) []int64 {
+	secs := make([]int64, len(records))
+	for i, rec := range records {
+		secs[i] = rec.Time.Unix()
+	}
+	return secs
+}
+
+func TestHistoryRingWrapsAround(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	cfg := HistoryConfig{Path: path, Interval: 10 * time.Second, Retention: 30 * time.Second}
+	s, err := openHistoryStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sec := int64(1); sec <= 5; sec++ {
+		if err := s.Append(historyTestRecord(sec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.file.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(historyHeaderSize + 3*historyRecordSize); info.Size() != want {
+		t.Errorf("file size is %d, want %d", info.Size(), want)
+	}
+	records := readHistoryFile(t, path)
+	if got := historySeconds(records); len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("records are from %v, want the newest three in order", got)
+	}
+	if records[0] != historyTestRecord(3) {
+		t.Errorf("record read back as %+v", records[0])
+	}
+
+	// Reopening with the same configuration keeps the ring position
+	s, err = openHistoryStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(historyTestRecord(6)); err != nil {
+		t.Fatal(err)
+	}
+	s.file.Close()
+	if got := historySeconds(readHistoryFile(t, path)); len(got) != 3 || got[0] != 4 || got[2] != 6 {
+		t.Errorf("after reopening records are from %v", got)
+	}
+}
+
+func TestHistoryStoreKeepsNewestOnResize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := openHistoryStore(HistoryConfig{Path: path, Interval: time.Second, Retention: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sec := int64(1); sec <= 4; sec++ {
+		if err := s.Append(historyTestRecord(sec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.file.Close()
+
+	s, err = openHistoryStore(HistoryConfig{Path: path, Interval: time.Second, Retention: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.file.Close()
+	if got := historySeconds(readHistoryFile(t, path)); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("after shrinking records are from %v, want 3 and 4", got)
+	}
+}
+
+func TestReadHistoryHeaderRejectsForeignFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	if err := os.WriteFile(path, make([]byte, historyHeaderSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := readHistoryHeader(f); err == nil {
+		t.Error("a file without the magic was accepted")
+	}
+}
+
+func TestDownsampleHistory(t *testing.T) {
+	var records []historyRecord
+	for sec := int64(0); sec < 6; sec++ {
+		records = append(records, historyRecord{Time: time.Unix(1000+sec*10, 0), Values: [historyMetricCount]float64{float64(sec)}})
+	}
+	points := downsampleHistory(records, 0, time.Unix(1010, 0), 30*time.Second)
+	if len(points) != 3 {
+		t.Fatalf("got %d points, want 3: %+v", len(points), points)
+	}
+	// Buckets are aligned to multiples of the step: [990,1020), [1020,1050), [1050,1080)
+	if p := points[0]; p.Count != 1 || p.Avg != 1 {
+		t.Errorf("first bucket = %+v", p)
+	}
+	if p := points[1]; p.Count != 3 || p.Avg != 3 || p.Min != 2 || p.Max != 4 {
+		t.Errorf("second bucket = %+v", p)
+	}
+	if p := points[2]; !p.Time.Equal(time.Unix(1050, 0)) || p.Count != 1 {
+		t.Errorf("third bucket = %+v", p)
+	}
+}
+
+// fakeSysfs creates device links the way sysfs does: physical devices below
+// /sys/devices/pci*, stacked and software devices below /sys/devices/virtual
+func fakeSysfs(t *testing.T, links map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	for link, target := range links {
+		if err := os.MkdirAll(filepath.Join(root, target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, link)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := sysfsRoot
+	sysfsRoot = root
+	t.Cleanup(func() { sysfsRoot = saved })
+}
+
+func TestBuildHistoryRecordCountsPhysicalDevicesOnce(t *testing.T) {
+	fakeSysfs(t, map[string]string{
+		"block/sda":        "devices/pci0000:00/block/sda",
+		"block/dm-0":       "devices/virtual/block/dm-0",
+		"block/loop0":      "devices/virtual/block/loop0",
+		"block/cciss!c0d0": "devices/pci0000:00/block/cciss!c0d0",
+		"class/net/eth0":   "devices/pci0000:00/net/eth0",
+		"class/net/br0":    "devices/virtual/net/br0",
+		"class/net/veth1":  "devices/virtual/net/veth1",
+	})
+
+	at := time.Unix(1000, 0)
+	nets := func(n uint64) map[string]net.IOCountersStat {
+		m := make(map[string]net.IOCountersStat)
+		for _, name := range []string{"lo", "eth0", "br0", "veth1"} {
+			m[name] = net.IOCountersStat{Name: name, BytesRecv: n, BytesSent: 2 * n}
+		}
+		return m
+	}
+	disks := func(n uint64) map[string]disk.IOCountersStat {
+		m := make(map[string]disk.IOCountersStat)
+		for _, name := range []string{"sda", "sda1", "dm-0", "loop0", "cciss/c0d0"} {
+			m[name] = disk.IOCountersStat{Name: name, ReadBytes: n, WriteBytes: 3 * n}
+		}
+		return m
+	}
+	prev := systemSample{Time: at, Network: nets(0), Disk: disks(0)}
+	cur := systemSample{Time: at.Add(10 * time.Second), Network: nets(1000), Disk: disks(1000)}
+
+	rec := buildHistoryRecord(prev, cur)
+	if rec.Values[3] != 100 || rec.Values[4] != 200 {
+		t.Errorf("network rates are %v/%v B/s, want 100/200 from eth0 only", rec.Values[3], rec.Values[4])
+	}
+	if rec.Values[5] != 200 || rec.Values[6] != 600 {
+		t.Errorf("disk rates are %v/%v B/s, want 200/600 from sda and cciss/c0d0 only", rec.Values[5], rec.Values[6])
+	}
+}
+
+func TestCounterRate(t *testing.T) {
+	if got := counterRate(100, 600, 5*time.Second); got != 100 {
+		t.Errorf("got %v, want 100", got)
+	}
+	if got := counterRate(600, 100, 5*time.Second); got != 0 {
+		t.Errorf("a counter reset gave %v, want 0", got)
+	}
+	if got := counterRate(0, 100, 0); got != 0 {
+		t.Errorf("zero elapsed gave %v, want 0", got)
+	}
+}
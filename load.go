@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// LoadReport is the load average from /proc/loadavg, raw and divided by the number of CPUs
+type LoadReport struct {
+	Load1     float64 `json:"load1"`
+	Load5     float64 `json:"load5"`
+	Load15    float64 `json:"load15"`
+	CPUs      int     `json:"cpus"`
+	PerCPU1   float64 `json:"load1_per_cpu"`
+	PerCPU5   float64 `json:"load5_per_cpu"`
+	PerCPU15  float64 `json:"load15_per_cpu"`
+	Running   int     `json:"running_tasks"`
+	Total     int     `json:"total_tasks"`
+	Trend     string  `json:"trend"`
+	Saturated bool    `json:"saturated"`
+}
+
+// parseLoadAvg parses "0.13 0.14 0.10 2/78 17098". The running count
+// includes the task reading the file.
+func parseLoadAvg(data string) (LoadReport, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 4 {
+		return LoadReport{}, fmt.Errorf("unexpected /proc/loadavg format %q", strings.TrimSpace(data))
+	}
+	var r LoadReport
+	var err error
+	for i, dst := range []*float64{&r.Load1, &r.Load5, &r.Load15} {
+		if *dst, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return LoadReport{}, fmt.Errorf("invalid load average %q", fields[i])
+		}
+	}
+	running, total, ok := strings.Cut(fields[3], "/")
+	if !ok {
+		return LoadReport{}, fmt.Errorf("invalid task counts %q", fields[3])
+	}
+	if r.Running, err = strconv.Atoi(running); err != nil {
+		return LoadReport{}, fmt.Errorf("invalid task counts %q", fields[3])
+	}
+	if r.Total, err = strconv.Atoi(total); err != nil {
+		return LoadReport{}, fmt.Errorf("invalid task counts %q", fields[3])
+	}
+	return r, nil
+}
+
+// loadTrend compares the 1 minute with the 15 minute average. Differences
+// below 10% (or 0.05 on an idle system) count as steady.
+func loadTrend(load1, load15 float64) string {
+	margin := load15 * 0.1
+	if margin < 0.05 {
+		margin = 0.05
+	}
+	switch {
+	case load1 > load15+margin:
+		return "rising"
+	case load1 < load15-margin:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
+
+// collectLoad reads the load average and normalizes it by the online CPUs
+func collectLoad() (LoadReport, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return LoadReport{}, err
+	}
+	r, err := parseLoadAvg(string(data))
+	if err != nil {
+		return LoadReport{}, err
+	}
+	r.CPUs, err = cpu.Counts(true)
+	if err != nil || r.CPUs < 1 {
+		r.CPUs = onlineCPUCount()
+	}
+	n := float64(r.CPUs)
+	r.PerCPU1, r.PerCPU5, r.PerCPU15 = r.Load1/n, r.Load5/n, r.Load15/n
+	r.Trend = loadTrend(r.Load1, r.Load15)
+	r.Saturated = r.PerCPU1 > 1
+	return r, nil
+}
+
+// getLoadAverage shows the load averages per CPU with run-queue context and trend
+func getLoadAverage(asJSON bool) string {
+	r, err := collectLoad()
+	if err != nil {
+		return fmt.Sprintf("Error getting load average: %v", err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode load average. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Load Average: 1 min: %.2f, 5 min: %.2f, 15 min: %.2f\n", r.Load1, r.Load5, r.Load15))
+	output.WriteString(fmt.Sprintf("Per CPU (%d CPUs): 1 min: %.2f, 5 min: %.2f, 15 min: %.2f\n", r.CPUs, r.PerCPU1, r.PerCPU5, r.PerCPU15))
+	output.WriteString(fmt.Sprintf("Tasks: %d runnable of %d\n", r.Running, r.Total))
+	output.WriteString(fmt.Sprintf("Trend: %s", r.Trend))
+	if r.Saturated {
+		output.WriteString(" (more runnable tasks than CPUs)")
+	}
+	return output.String()
+}
+
+// runLoadCommand parses "osctl load" arguments
+func runLoadCommand(args []string) string {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\nUsage: osctl load [--json]", err)
+	}
+	return getLoadAverage(*asJSON)
+}
+
+// getLoadThresholds returns the per-CPU 5 minute load thresholds from
+// OSCTL_HEALTH_LOAD ("degraded[:unhealthy]"). The check is opt-in: it is
+// disabled when the variable is unset, "none" or invalid.
+func getLoadThresholds() (degraded, unhealthy float64, enabled bool) {
+	value := os.Getenv("OSCTL_HEALTH_LOAD")
+	if value == "" || value == "none" {
+		return 0, 0, false
+	}
+	d, u, err := parseLoadThresholds(value)
+	if err != nil {
+		log.Printf("Ignoring invalid OSCTL_HEALTH_LOAD %q: %v", value, err)
+		return 0, 0, false
+	}
+	return d, u, true
+}
+
+// parseLoadThresholds parses "degraded[:unhealthy]"; without an unhealthy
+// threshold the check only ever degrades
+func parseLoadThresholds(value string) (float64, float64, error) {
+	degraded, unhealthy, hasUnhealthy := strings.Cut(value, ":")
+	d, err := strconv.ParseFloat(degraded, 64)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("invalid threshold %q", degraded)
+	}
+	if !hasUnhealthy {
+		return d, 0, nil
+	}
+	u, err := strconv.ParseFloat(unhealthy, 64)
+	if err != nil || u < d {
+		return 0, 0, fmt.Errorf("invalid threshold %q (must not be below %s)", unhealthy, degraded)
+	}
+	return d, u, nil
+}
+
+// checkLoad evaluates the 5 minute load per CPU against OSCTL_HEALTH_LOAD
+func checkLoad() (HealthCheck, bool) {
+	degraded, unhealthy, enabled := getLoadThresholds()
+	if !enabled {
+		return HealthCheck{}, false
+	}
+	r, err := collectLoad()
+	if err != nil {
+		return HealthCheck{Status: StatusUnhealthy, Message: fmt.Sprintf("Failed to get load average: %v", err)}, true
+	}
+	status := StatusHealthy
+	if unhealthy > 0 && r.PerCPU5 > unhealthy {
+		status = StatusUnhealthy
+	} else if r.PerCPU5 > degraded {
+		status = StatusDegraded
+	}
+	return HealthCheck{
+		Status: status,
+		Value:  fmt.Sprintf("%.2f", r.PerCPU5),
+		Message: fmt.Sprintf("5 min load %.2f on %d CPUs, %d/%d tasks runnable, %s",
+			r.Load5, r.CPUs, r.Running, r.Total, r.Trend),
+	}, true
+}
+
+// loadCollector exports the load average, raw and per CPU, and task counts at scrape time
+type loadCollector struct {
+	load   *prometheus.Desc
+	perCPU *prometheus.Desc
+	tasks  *prometheus.Desc
+}
+
+func newLoadCollector() *loadCollector {
+	return &loadCollector{
+		load:   prometheus.NewDesc("osctl_load_average", "System load average over a window (1m, 5m, 15m)", []string{"window"}, nil),
+		perCPU: prometheus.NewDesc("osctl_load_per_cpu", "System load average divided by the number of online CPUs", []string{"window"}, nil),
+		tasks:  prometheus.NewDesc("osctl_tasks", "Runnable and total scheduling entities from /proc/loadavg", []string{"state"}, nil),
+	}
+}
+
+func (c *loadCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.load
+	ch <- c.perCPU
+	ch <- c.tasks
+}
+
+func (c *loadCollector) Collect(ch chan<- prometheus.Metric) {
+	r, err := collectLoad()
+	if err != nil {
+		return
+	}
+	for window, v := range map[string][2]float64{"1m": {r.Load1, r.PerCPU1}, "5m": {r.Load5, r.PerCPU5}, "15m": {r.Load15, r.PerCPU15}} {
+		ch <- prometheus.MustNewConstMetric(c.load, prometheus.GaugeValue, v[0], window)
+		ch <- prometheus.MustNewConstMetric(c.perCPU, prometheus.GaugeValue, v[1], window)
+	}
+	ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(r.Running), "running")
+	ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(r.Total), "total")
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestParseLoadAvg(t *testing.T) {
+	r, err := parseLoadAvg("0.13 1.50 2.25 3/78 17098\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Load1 != 0.13 || r.Load5 != 1.5 || r.Load15 != 2.25 || r.Running != 3 || r.Total != 78 {
+		t.Errorf("got %+v", r)
+	}
+	for _, data := range []string{"", "0.1 0.2 0.3", "x 0.2 0.3 1/2 3", "0.1 0.2 0.3 12 3", "0.1 0.2 0.3 a/2 3", "0.1 0.2 0.3 1/b 3"} {
+		if _, err := parseLoadAvg(data); err == nil {
+			t.Errorf("parseLoadAvg(%q) succeeded", data)
+		}
+	}
+}
+
+func TestLoadTrend(t *testing.T) {
+	for _, c := range []struct {
+		load1, load15 float64
+		want          string
+	}{
+		{2, 1, "rising"},
+		{1, 2, "falling"},
+		{1.05, 1, "steady"},
+		{0.04, 0, "steady"},
+		{0.06, 0, "rising"},
+	} {
+		if got := loadTrend(c.load1, c.load15); got != c.want {
+			t.Errorf("loadTrend(%v, %v) = %s, want %s", c.load1, c.load15, got, c.want)
+		}
+	}
+}
+
+func TestParseLoadThresholds(t *testing.T) {
+	if d, u, err := parseLoadThresholds("1.5:3"); err != nil || d != 1.5 || u != 3 {
+		t.Errorf("got %v, %v, %v", d, u, err)
+	}
+	if d, u, err := parseLoadThresholds("2"); err != nil || d != 2 || u != 0 {
+		t.Errorf("degraded only gave %v, %v, %v", d, u, err)
+	}
+	for _, value := range []string{"", "0", "-1", "x", "2:1", "1:y"} {
+		if _, _, err := parseLoadThresholds(value); err == nil {
+			t.Errorf("parseLoadThresholds(%q) succeeded", value)
+		}
+	}
+}
+
+func TestGetLoadThresholds(t *testing.T) {
+	for _, value := range []string{"", "none", "bogus"} {
+		t.Setenv("OSCTL_HEALTH_LOAD", value)
+		if _, _, enabled := getLoadThresholds(); enabled {
+			t.Errorf("OSCTL_HEALTH_LOAD=%q left the load check enabled", value)
+		}
+	}
+	t.Setenv("OSCTL_HEALTH_LOAD", "1.5:3")
+	if d, u, enabled := getLoadThresholds(); !enabled || d != 1.5 || u != 3 {
+		t.Errorf("got %v, %v, %v; want 1.5, 3, true", d, u, enabled)
+	}
+	t.Setenv("OSCTL_HEALTH_LOAD", "")
+	if _, ok := checkLoad(); ok {
+		t.Error("checkLoad reported a check without configuration")
+	}
+}
@@ -17,15 +17,31 @@ func main() {
 	case "disk":
 		fmt.Println(getDiskUsage())
 	case "service":
+		if len(os.Args) >= 3 && os.Args[2] == "show" {
+			fmt.Println(runServiceShow(os.Args[3:]))
+			return
+		}
+		if len(os.Args) >= 3 && os.Args[2] == "resources" {
+			fmt.Println(runServiceResources(os.Args[3:]))
+			return
+		}
+		if len(os.Args) >= 3 && os.Args[2] == "override" {
+			fmt.Println(runServiceOverride(os.Args[3:]))
+			return
+		}
+		if len(os.Args) >= 4 && os.Args[2] == "restart" {
+			fmt.Println(runServiceRestart(os.Args[3:]))
+			return
+		}
 		if len(os.Args) < 4 {
-			fmt.Println("Usage: osctl service [start|stop|restart|status] [service_name]")
+			fmt.Println("Usage: osctl service [start|stop|restart|reload|status|enable|disable|mask|unmask|show|override|resources] [service_name]")
 			return
 		}
 		action := os.Args[2]
 		service := os.Args[3]
 		fmt.Println(manageService(action, service))
 	case "top":
-		fmt.Println(getTopProcesses())
+		fmt.Println(runTopCommand(os.Args[2:]))
 	case "errors":
 		fmt.Println(getLastJournalErrors())
 	case "users":
@@ -49,9 +65,9 @@ func main() {
 	case "images":
 		fmt.Println(listDockerImages())
 	case "cpu":
-		fmt.Println(getCpuUsage())
+		fmt.Println(runCPUCommand(os.Args[2:]))
 	case "load":
-		fmt.Println(getLoadAverage())
+		fmt.Println(runLoadCommand(os.Args[2:]))
 	case "network":
 		fmt.Println(getNetworkStats())
 	case "connections":
@@ -63,54 +79,83 @@ func main() {
 	case "who":
 		fmt.Println(getLoggedinUsers())
 	case "services":
-		fmt.Println(getServiceStatuses())
+		fmt.Println(runServicesCommand(os.Args[2:]))
 	case "health":
 		fmt.Println(getHealthCheck())
 	case "process":
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: osctl process [kill|killforce|nice|info|tree] [options]")
+			fmt.Println("Usage: osctl process [kill|killforce|signal|nice|ionice|affinity|limit|info|tree|problems|watch] [options]")
 			fmt.Println("  kill <pid>           - Terminate process")
 			fmt.Println("  killforce <pid>      - Force kill process")
+			fmt.Println("                         (protected processes need --override as root, see OSCTL_PROTECTED_*)")
+			fmt.Println("  signal <pid|name|pattern> [--signal TERM] [--tree] [--user NAME] [--graceful] [--timeout 10s] [--yes] [--override]")
+			fmt.Println("                       - Signal matching processes after confirmation")
 			fmt.Println("  nice <pid> <priority> - Set process priority (-20 to 19)")
-			fmt.Println("  info <pid>           - Show process information")
-			fmt.Println("  tree                 - Show process tree")
+			fmt.Println("  ionice <pid> [--class none|realtime|best-effort|idle] [--level 0-7]")
+			fmt.Println("                       - Show or set the I/O scheduling class")
+			fmt.Println("  affinity <pid> [cpu-list] - Show or set the CPU affinity, e.g. 0-3,6")
+			fmt.Println("  limit <pid> [name=soft[:hard] ...] - Show or set resource limits, e.g. nofile=65535")
+			fmt.Println("  info <pid> [--show-env] [--json]")
+			fmt.Println("                       - Show process details: parents, FDs, sockets, limits, cgroup, namespaces, environment")
+			fmt.Println("  problems [--duration 5s] [--cpu 90] [--rss-mb 4096] [--fds 80] [--json]")
+			fmt.Println("                       - List zombies, D-state, runaway processes and deleted binaries")
+			fmt.Println("  watch [--user NAME] [--name PATTERN] [--interval 250ms] [--duration 1m] [--json]")
+			fmt.Println("                       - Stream process start and exit events")
+			fmt.Println("  tree [--pid PID] [--user NAME] [--unit UNIT] [--interval 1s] [--json]")
+			fmt.Println("                       - Show process tree with per-process and cumulative CPU, RSS and threads")
 			return
 		}
 		action := os.Args[2]
 		switch action {
 		case "kill":
 			if len(os.Args) < 4 {
-				fmt.Println("Usage: osctl process kill <pid>")
+				fmt.Println("Usage: osctl process kill <pid> [--override]")
 				return
 			}
-			fmt.Println(killProcess(os.Args[3]))
+			fmt.Println(killProcess(os.Args[3], cliProcessGuard(hasOverrideFlag(os.Args[4:]))))
 		case "killforce":
 			if len(os.Args) < 4 {
-				fmt.Println("Usage: osctl process killforce <pid>")
+				fmt.Println("Usage: osctl process killforce <pid> [--override]")
 				return
 			}
-			fmt.Println(killProcessForce(os.Args[3]))
+			fmt.Println(killProcessForce(os.Args[3], cliProcessGuard(hasOverrideFlag(os.Args[4:]))))
+		case "signal":
+			fmt.Println(runProcessSignal(os.Args[3:]))
+		case "ionice", "affinity", "limit":
+			fmt.Println(runProcessTuning(action, os.Args[3:]))
+		case "problems":
+			fmt.Println(runProcessProblems(os.Args[3:]))
+		case "watch":
+			if out := runProcessWatch(os.Args[3:]); out != "" {
+				fmt.Println(out)
+			}
 		case "nice":
 			if len(os.Args) < 5 {
-				fmt.Println("Usage: osctl process nice <pid> <priority>")
+				fmt.Println("Usage: osctl process nice <pid> <priority> [--override]")
 				return
 			}
-			fmt.Println(setProcessPriority(os.Args[3], os.Args[4]))
+			fmt.Println(setProcessPriority(os.Args[3], os.Args[4], cliProcessGuard(hasOverrideFlag(os.Args[5:]))))
 		case "info":
-			if len(os.Args) < 4 {
-				fmt.Println("Usage: osctl process info <pid>")
-				return
-			}
-			fmt.Println(getProcessInfo(os.Args[3]))
+			fmt.Println(runProcessInfo(os.Args[3:]))
 		case "tree":
-			fmt.Println(getProcessTree())
+			fmt.Println(runProcessTree(os.Args[3:]))
 		default:
 			fmt.Println("Unknown process action")
 		}
 	case "networkio":
-		fmt.Println(getNetworkIO())
+		interval, asJSON, err := parseRateArgs("networkio", os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(getNetworkIO(interval, asJSON))
 	case "diskio":
-		fmt.Println(getDiskIO())
+		interval, asJSON, err := parseRateArgs("diskio", os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(getDiskIO(interval, asJSON))
 	case "procs":
 		fmt.Println(getProcessCountByState())
 	case "audit":
@@ -173,13 +218,35 @@ func main() {
 			fmt.Println("  enable            - Enable maintenance mode")
 			fmt.Println("  disable           - Disable maintenance mode")
 			fmt.Println("  check-services    - Check critical services status")
-			fmt.Println("  restart-failed    - Restart all failed services")
+			fmt.Println("  restart-failed    - Restart failed services (--include, --exclude, --max-attempts, --backoff, --reset-failed, --dry-run, --json)")
 			fmt.Println("  sync-time         - Synchronize system time")
 			fmt.Println("  clear-cache       - Clear system caches")
 			return
 		}
 		action := os.Args[2]
+		if action == "restart-failed" {
+			fmt.Println(runRestartFailedCommand(os.Args[3:]))
+			return
+		}
 		fmt.Println(getMaintenanceActions(action))
+	case "dashboard":
+		if out := runDashboardCommand(os.Args[2:]); out != "" {
+			fmt.Println(out)
+		}
+	case "history":
+		fmt.Println(runHistoryCommand(os.Args[2:]))
+	case "timer":
+		fmt.Println(runTimerCommand(os.Args[2:]))
+	case "boot":
+		fmt.Println(runBootCommand(os.Args[2:]))
+	case "memory":
+		fmt.Println(runMemoryCommand(os.Args[2:]))
+	case "pressure":
+		fmt.Println(runPressureCommand(os.Args[2:]))
+	case "watchdog":
+		fmt.Println(getWatchdogSummary())
+	case "auditlog":
+		fmt.Println(runAuditLogCommand(os.Args[2:]))
 	case "api":
 		runAPI()
 	default:
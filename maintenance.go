@@ -63,8 +63,8 @@ func disableMaintenanceMode() string {
 	return "Maintenance mode disabled successfully"
 }
 
-// getMaintenanceStatus returns the current maintenance mode status
-func getMaintenanceStatus() string {
+// readMaintenanceStatus loads the maintenance flag file, if any
+func readMaintenanceStatus() MaintenanceStatus {
 	status := MaintenanceStatus{
 		Enabled: false,
 	}
@@ -73,8 +73,12 @@ func getMaintenanceStatus() string {
 	if err == nil {
 		json.Unmarshal(data, &status)
 	}
+	return status
+}
 
-	output, _ := json.MarshalIndent(status, "", "  ")
+// getMaintenanceStatus returns the current maintenance mode status
+func getMaintenanceStatus() string {
+	output, _ := json.MarshalIndent(readMaintenanceStatus(), "", "  ")
 	return string(output)
 }
 
@@ -93,48 +97,31 @@ func getMaintenanceActions(action string) string {
 	case "check-services":
 		// Check if critical services are running
 		services := []string{"sshd", "systemd-journald", "systemd-logind"}
+		m, err := newUnitManager()
+		if err != nil {
+			return fmt.Sprintf("Failed to connect to systemd: %v", err)
+		}
+		defer m.Close()
+
 		var results []string
 		results = append(results, "Critical Services Status:\n")
 
 		for _, svc := range services {
-			cmd := exec.Command("systemctl", "is-active", svc)
-			output, _ := cmd.Output()
-			state := strings.TrimSpace(string(output))
+			info, err := getUnitInfo(m, normalizeUnitName(svc))
+			if err != nil {
+				results = append(results, fmt.Sprintf("  %s: unknown (%v)", svc, err))
+				continue
+			}
+			state := info.ActiveState
+			if info.LoadState == "not-found" {
+				state = "not installed"
+			}
 			results = append(results, fmt.Sprintf("  %s: %s", svc, state))
 		}
 		return strings.Join(results, "\n")
 
 	case "restart-failed":
-		// Restart all failed systemd services
-		cmd := exec.Command("systemctl", "list-units", "--failed", "--plain", "--no-legend")
-		output, err := cmd.Output()
-		if err != nil {
-			return fmt.Sprintf("Failed to list failed services: %v", err)
-		}
-
-		failed := strings.TrimSpace(string(output))
-		if failed == "" {
-			return "No failed services found"
-		}
-
-		lines := strings.Split(failed, "\n")
-		var results []string
-		results = append(results, fmt.Sprintf("Restarting %d failed services:\n", len(lines)))
-
-		for _, line := range lines {
-			fields := strings.Fields(line)
-			if len(fields) > 0 {
-				serviceName := fields[0]
-				cmd := exec.Command("systemctl", "restart", serviceName)
-				err := cmd.Run()
-				if err != nil {
-					results = append(results, fmt.Sprintf("  %s: FAILED to restart", serviceName))
-				} else {
-					results = append(results, fmt.Sprintf("  %s: restarted", serviceName))
-				}
-			}
-		}
-		return strings.Join(results, "\n")
+		return runRestartFailedCommand(nil)
 
 	case "sync-time":
 		// Sync system time
@@ -143,8 +130,12 @@ func getMaintenanceActions(action string) string {
 			return fmt.Sprintf("Failed to enable NTP: %v", err)
 		}
 
-		cmd = exec.Command("systemctl", "restart", "systemd-timesyncd")
-		if err := cmd.Run(); err != nil {
+		m, err := newUnitManager()
+		if err != nil {
+			return fmt.Sprintf("Failed to connect to systemd: %v", err)
+		}
+		defer m.Close()
+		if _, err := m.RunUnitJob("restart", "systemd-timesyncd.service"); err != nil {
 			return fmt.Sprintf("Failed to restart time sync: %v", err)
 		}
 
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultMemoryInterval = time.Second
+	defaultSlabLimit      = 10
+	defaultOOMLimit       = 5
+)
+
+var (
+	oomKilledPattern  = regexp.MustCompile(`Killed process (\d+) \(([^)]*)\) total-vm:(\d+)kB, anon-rss:(\d+)kB`)
+	oomContextPattern = regexp.MustCompile(`oom-kill:constraint=(\w+).*task_memcg=([^,]*),.*pid=(\d+)`)
+)
+
+// MemoryOptions controls the sampling interval and list sizes of the memory report
+type MemoryOptions struct {
+	Interval time.Duration
+	Slabs    int
+	OOM      int
+	JSON     bool
+}
+
+// SwapStats is swap usage and the paging rates sampled over the interval
+type SwapStats struct {
+	Total             uint64  `json:"total_bytes"`
+	Used              uint64  `json:"used_bytes"`
+	Free              uint64  `json:"free_bytes"`
+	Cached            uint64  `json:"cached_bytes"`
+	UsedPercent       float64 `json:"used_percent"`
+	InBytesPerSec     float64 `json:"in_bytes_per_sec"`
+	OutBytesPerSec    float64 `json:"out_bytes_per_sec"`
+	MajorFaultsPerSec float64 `json:"major_faults_per_sec"`
+}
+
+// SlabCache is one kernel slab cache from /proc/slabinfo
+type SlabCache struct {
+	Name       string `json:"name"`
+	ActiveObjs uint64 `json:"active_objects"`
+	Objects    uint64 `json:"objects"`
+	ObjectSize uint64 `json:"object_size_bytes"`
+	Size       uint64 `json:"size_bytes"`
+}
+
+// HugePageStats is the explicit hugepage pool and the transparent hugepage mode
+type HugePageStats struct {
+	Total     uint64 `json:"total"`
+	Free      uint64 `json:"free"`
+	Reserved  uint64 `json:"reserved"`
+	Surplus   uint64 `json:"surplus"`
+	PageSize  uint64 `json:"page_size_bytes"`
+	AnonTHP   uint64 `json:"anon_thp_bytes"`
+	THP       string `json:"thp_enabled,omitempty"`
+	THPDefrag string `json:"thp_defrag,omitempty"`
+}
+
+// NUMANodeMemory is the memory of one NUMA node
+type NUMANodeMemory struct {
+	Node  int    `json:"node"`
+	Total uint64 `json:"total_bytes"`
+	Free  uint64 `json:"free_bytes"`
+	Used  uint64 `json:"used_bytes"`
+}
+
+// OOMEvent is a process killed by the OOM killer, parsed from the kernel log
+type OOMEvent struct {
+	Time       string `json:"time"`
+	PID        int    `json:"pid"`
+	Name       string `json:"name"`
+	TotalVM    uint64 `json:"total_vm_bytes"`
+	AnonRSS    uint64 `json:"anon_rss_bytes"`
+	Constraint string `json:"constraint,omitempty"`
+	Cgroup     string `json:"cgroup,omitempty"`
+}
+
+// MemoryReport is the result of "osctl memory"
+type MemoryReport struct {
+	Interval          string           `json:"interval"`
+	Total             uint64           `json:"total_bytes"`
+	Used              uint64           `json:"used_bytes"`
+	Free              uint64           `json:"free_bytes"`
+	Available         uint64           `json:"available_bytes"`
+	Buffers           uint64           `json:"buffers_bytes"`
+	Cached            uint64           `json:"cached_bytes"`
+	Shared            uint64           `json:"shared_bytes"`
+	Dirty             uint64           `json:"dirty_bytes"`
+	Writeback         uint64           `json:"writeback_bytes"`
+	SlabReclaimable   uint64           `json:"slab_reclaimable_bytes"`
+	SlabUnreclaimable uint64           `json:"slab_unreclaimable_bytes"`
+	PageTables        uint64           `json:"page_tables_bytes"`
+	CommitLimit       uint64           `json:"commit_limit_bytes"`
+	Committed         uint64           `json:"committed_bytes"`
+	Swap              SwapStats        `json:"swap"`
+	Pressure          *Pressure        `json:"pressure,omitempty"`
+	Slabs             []SlabCache      `json:"slabs"`
+	HugePages         HugePageStats    `json:"hugepages"`
+	NUMA              []NUMANodeMemory `json:"numa"`
+	OOMEvents         []OOMEvent       `json:"oom_events"`
+	Warnings          []string         `json:"warnings,omitempty"`
+}
+
+// newMemoryOptions validates memory report options shared by the CLI and API
+func newMemoryOptions(interval, slabs, oom string, asJSON bool) (MemoryOptions, error) {
+	opts := MemoryOptions{Interval: defaultMemoryInterval, Slabs: defaultSlabLimit, OOM: defaultOOMLimit, JSON: asJSON}
+	if interval != "" {
+		d, err := parseDurationValue(interval)
+		if err != nil || d <= 0 || d > maxRateInterval {
+			return opts, fmt.Errorf("invalid interval %q (must be between 0 and %s)", interval, maxRateInterval)
+		}
+		opts.Interval = d
+	}
+	if slabs != "" {
+		n, err := strconv.Atoi(slabs)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid slabs %q", slabs)
+		}
+		opts.Slabs = n
+	}
+	if oom != "" {
+		n, err := strconv.Atoi(oom)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid oom %q", oom)
+		}
+		opts.OOM = n
+	}
+	return opts, nil
+}
+
+// parseMeminfo parses /proc/meminfo style "Key: value [kB]" lines into bytes
+// (or plain counts). Per-node files prefix each line with "Node N", which is skipped.
+func parseMeminfo(data string) map[string]uint64 {
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "Node" {
+			fields = fields[2:]
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= 1024
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = v
+	}
+	return values
+}
+
+// readVMStat reads the counters of /proc/vmstat
+func readVMStat() (map[string]uint64, error) {
+	data, err := os.ReadFile("/proc/vmstat")
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, nil
+}
+
+// readSlabCaches returns the largest slab caches by memory used. Reading
+// /proc/slabinfo requires root.
+func readSlabCaches(limit int) ([]SlabCache, error) {
+	f, err := os.Open("/proc/slabinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pageSize := uint64(os.Getpagesize())
+	var caches []SlabCache
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "slabinfo") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name active_objs num_objs objsize objperslab pagesperslab : tunables ... : slabdata active_slabs num_slabs sharedavail
+		fields := strings.Fields(line)
+		if len(fields) < 15 {
+			continue
+		}
+		active, _ := strconv.ParseUint(fields[1], 10, 64)
+		objects, _ := strconv.ParseUint(fields[2], 10, 64)
+		objSize, _ := strconv.ParseUint(fields[3], 10, 64)
+		pagesPerSlab, _ := strconv.ParseUint(fields[5], 10, 64)
+		slabs, _ := strconv.ParseUint(fields[14], 10, 64)
+		caches = append(caches, SlabCache{Name: fields[0], ActiveObjs: active, Objects: objects, ObjectSize: objSize,
+			Size: slabs * pagesPerSlab * pageSize})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(caches, func(i, j int) bool { return caches[i].Size > caches[j].Size })
+	if limit > 0 && len(caches) > limit {
+		caches = caches[:limit]
+	}
+	return caches, nil
+}
+
+// readSelectedMode returns the bracketed value of a sysfs mode file such as
+// "always [madvise] never"
+func readSelectedMode(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	s := string(data)
+	start, end := strings.Index(s, "["), strings.Index(s, "]")
+	if start < 0 || end < start {
+		return strings.TrimSpace(s)
+	}
+	return s[start+1 : end]
+}
+
+// readNUMANodes reads the per-node memory of all NUMA nodes
+func readNUMANodes() ([]NUMANodeMemory, error) {
+	dirs, err := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	nodes := []NUMANodeMemory{}
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "meminfo"))
+		if err != nil {
+			continue
+		}
+		m := parseMeminfo(string(data))
+		nodes = append(nodes, NUMANodeMemory{Node: id, Total: m["MemTotal"], Free: m["MemFree"], Used: m["MemUsed"]})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	return nodes, nil
+}
+
+// readKernelLog returns the kernel messages of the current boot from the
+// journal, falling back to dmesg when the journal has none
+func readKernelLog() (string, error) {
+	out, err := exec.Command("journalctl", "-k", "-b", "0", "-o", "short-iso", "--no-pager", "-q").Output()
+	if err == nil && strings.TrimSpace(string(out)) != "" {
+		return string(out), nil
+	}
+	out, err = exec.Command("dmesg", "--time-format", "iso").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot read kernel log: %v", err)
+	}
+	return string(out), nil
+}
+
+// parseOOMEvents extracts OOM kills from kernel log lines. The constraint
+// and cgroup come from the "oom-kill:" line the kernel logs before the kill.
+func parseOOMEvents(log string, limit int) []OOMEvent {
+	events := []OOMEvent{}
+	type oomContext struct{ constraint, cgroup string }
+	contexts := make(map[string]oomContext)
+	for _, line := range strings.Split(log, "\n") {
+		if m := oomContextPattern.FindStringSubmatch(line); m != nil {
+			contexts[m[3]] = oomContext{constraint: m[1], cgroup: m[2]}
+			continue
+		}
+		m := oomKilledPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		e := OOMEvent{Name: m[2]}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			e.Time = fields[0]
+		}
+		e.PID, _ = strconv.Atoi(m[1])
+		totalVM, _ := strconv.ParseUint(m[3], 10, 64)
+		anonRSS, _ := strconv.ParseUint(m[4], 10, 64)
+		e.TotalVM, e.AnonRSS = totalVM*1024, anonRSS*1024
+		if c, ok := contexts[m[1]]; ok {
+			e.Constraint, e.Cgroup = c.constraint, c.cgroup
+			delete(contexts, m[1])
+		}
+		events = append(events, e)
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+// collectMemoryReport gathers the memory report, sampling swap activity over
+// opts.Interval. Sections that cannot be read are reported as warnings.
+func collectMemoryReport(opts MemoryOptions) (MemoryReport, error) {
+	before, err := readVMStat()
+	if err != nil {
+		return MemoryReport{}, err
+	}
+	start := time.Now()
+	time.Sleep(opts.Interval)
+	after, err := readVMStat()
+	if err != nil {
+		return MemoryReport{}, err
+	}
+	elapsed := time.Since(start)
+
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return MemoryReport{}, err
+	}
+	m := parseMeminfo(string(data))
+	report := MemoryReport{
+		Interval:          opts.Interval.String(),
+		Total:             m["MemTotal"],
+		Free:              m["MemFree"],
+		Available:         m["MemAvailable"],
+		Buffers:           m["Buffers"],
+		Cached:            m["Cached"],
+		Shared:            m["Shmem"],
+		Dirty:             m["Dirty"],
+		Writeback:         m["Writeback"],
+		SlabReclaimable:   m["SReclaimable"],
+		SlabUnreclaimable: m["SUnreclaim"],
+		PageTables:        m["PageTables"],
+		CommitLimit:       m["CommitLimit"],
+		Committed:         m["Committed_AS"],
+		HugePages: HugePageStats{
+			Total:     m["HugePages_Total"],
+			Free:      m["HugePages_Free"],
+			Reserved:  m["HugePages_Rsvd"],
+			Surplus:   m["HugePages_Surp"],
+			PageSize:  m["Hugepagesize"],
+			AnonTHP:   m["AnonHugePages"],
+			THP:       readSelectedMode("/sys/kernel/mm/transparent_hugepage/enabled"),
+			THPDefrag: readSelectedMode("/sys/kernel/mm/transparent_hugepage/defrag"),
+		},
+	}
+	if report.Available > 0 && report.Available < report.Total {
+		report.Used = report.Total - report.Available
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	swap := SwapStats{Total: m["SwapTotal"], Free: m["SwapFree"], Cached: m["SwapCached"]}
+	if swap.Total > swap.Free {
+		swap.Used = swap.Total - swap.Free
+	}
+	if swap.Total > 0 {
+		swap.UsedPercent = float64(swap.Used) / float64(swap.Total) * 100
+	}
+	swap.InBytesPerSec = counterRate(before["pswpin"]*pageSize, after["pswpin"]*pageSize, elapsed)
+	swap.OutBytesPerSec = counterRate(before["pswpout"]*pageSize, after["pswpout"]*pageSize, elapsed)
+	swap.MajorFaultsPerSec = counterRate(before["pgmajfault"], after["pgmajfault"], elapsed)
+	report.Swap = swap
+
+	if p, err := readPressureFile("/proc/pressure/memory"); err == nil {
+		report.Pressure = &p
+	} else {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("memory pressure unavailable: %v", err))
+	}
+
+	report.Slabs = []SlabCache{}
+	if opts.Slabs > 0 {
+		if slabs, err := readSlabCaches(opts.Slabs); err == nil {
+			report.Slabs = slabs
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("slab caches unavailable: %v", err))
+		}
+	}
+
+	report.NUMA = []NUMANodeMemory{}
+	if nodes, err := readNUMANodes(); err == nil {
+		report.NUMA = nodes
+	}
+
+	report.OOMEvents = []OOMEvent{}
+	if opts.OOM > 0 {
+		if log, err := readKernelLog(); err == nil {
+			report.OOMEvents = parseOOMEvents(log, opts.OOM)
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("OOM history unavailable: %v", err))
+		}
+	}
+	return report, nil
+}
+
+// getMemoryReport shows a detailed breakdown of memory, swap, pressure, slab,
+// hugepages, NUMA nodes and recent OOM kills
+func getMemoryReport(opts MemoryOptions) string {
+	report, err := collectMemoryReport(opts)
+	if err != nil {
+		return fmt.Sprintf("Failed to get memory report. Error: %v", err)
+	}
+	if opts.JSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode memory report. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	usedPercent := 0.0
+	if report.Total > 0 {
+		usedPercent = float64(report.Used) / float64(report.Total) * 100
+	}
+	output.WriteString(fmt.Sprintf("Memory: %s total, %s used (%.1f%%), %s available, %s free\n",
+		formatBytes(report.Total), formatBytes(report.Used), usedPercent, formatBytes(report.Available), formatBytes(report.Free)))
+	output.WriteString(fmt.Sprintf("  Buffers %s, cached %s, shared %s, dirty %s, writeback %s\n",
+		formatBytes(report.Buffers), formatBytes(report.Cached), formatBytes(report.Shared), formatBytes(report.Dirty), formatBytes(report.Writeback)))
+	output.WriteString(fmt.Sprintf("  Slab %s reclaimable, %s unreclaimable; page tables %s\n",
+		formatBytes(report.SlabReclaimable), formatBytes(report.SlabUnreclaimable), formatBytes(report.PageTables)))
+	output.WriteString(fmt.Sprintf("  Committed %s of %s commit limit\n", formatBytes(report.Committed), formatBytes(report.CommitLimit)))
+
+	s := report.Swap
+	if s.Total == 0 {
+		output.WriteString("\nSwap: none configured")
+	} else {
+		output.WriteString(fmt.Sprintf("\nSwap: %s total, %s used (%.1f%%), %s cached", formatBytes(s.Total), formatBytes(s.Used), s.UsedPercent, formatBytes(s.Cached)))
+	}
+	output.WriteString(fmt.Sprintf("\n  In %s/s, out %s/s, major faults %.1f/s (over %s)\n",
+		formatBytes(uint64(s.InBytesPerSec)), formatBytes(uint64(s.OutBytesPerSec)), s.MajorFaultsPerSec, report.Interval))
+
+	if p := report.Pressure; p != nil {
+		output.WriteString(fmt.Sprintf("\nPressure: some avg10=%.2f avg60=%.2f avg300=%.2f", p.Some.Avg10, p.Some.Avg60, p.Some.Avg300))
+		if p.Full != nil {
+			output.WriteString(fmt.Sprintf(", full avg10=%.2f avg60=%.2f avg300=%.2f", p.Full.Avg10, p.Full.Avg60, p.Full.Avg300))
+		}
+		output.WriteString("\n")
+	}
+
+	h := report.HugePages
+	output.WriteString(fmt.Sprintf("\nHugepages: %d total, %d free, %d reserved, %d surplus (page size %s)\n",
+		h.Total, h.Free, h.Reserved, h.Surplus, formatBytes(h.PageSize)))
+	if h.THP != "" {
+		output.WriteString(fmt.Sprintf("  Transparent hugepages: %s (defrag %s), %s anonymous\n", h.THP, h.THPDefrag, formatBytes(h.AnonTHP)))
+	}
+
+	if len(report.NUMA) > 0 {
+		output.WriteString("\nNUMA nodes:\n")
+		for _, n := range report.NUMA {
+			output.WriteString(fmt.Sprintf("  node%d: %s total, %s used, %s free\n", n.Node, formatBytes(n.Total), formatBytes(n.Used), formatBytes(n.Free)))
+		}
+	}
+
+	if len(report.Slabs) > 0 {
+		output.WriteString("\nTop slab caches:\n")
+		w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+		io.WriteString(w, "  NAME\tSIZE\tOBJECTS\tACTIVE\tOBJ SIZE\n")
+		for _, c := range report.Slabs {
+			fmt.Fprintf(w, "  %s\t%s\t%d\t%d\t%d B\n", c.Name, formatBytes(c.Size), c.Objects, c.ActiveObjs, c.ObjectSize)
+		}
+		w.Flush()
+	}
+
+	output.WriteString("\nRecent OOM kills:\n")
+	if len(report.OOMEvents) == 0 {
+		output.WriteString("  none\n")
+	}
+	for _, e := range report.OOMEvents {
+		output.WriteString(fmt.Sprintf("  %s %s (pid %d) anon-rss %s, total-vm %s", e.Time, e.Name, e.PID, formatBytes(e.AnonRSS), formatBytes(e.TotalVM)))
+		if e.Constraint != "" {
+			output.WriteString(fmt.Sprintf(" [%s %s]", e.Constraint, e.Cgroup))
+		}
+		output.WriteString("\n")
+	}
+
+	for _, warning := range report.Warnings {
+		output.WriteString(fmt.Sprintf("\nNote: %s", warning))
+	}
+	return strings.TrimRight(output.String(), "\n")
+}
+
+// runMemoryCommand parses "osctl memory" arguments
+func runMemoryCommand(args []string) string {
+	usage := "Usage: osctl memory [--interval 1s] [--slabs 10] [--oom 5] [--json]"
+	fs := flag.NewFlagSet("memory", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	interval := fs.String("interval", "", "swap activity sampling interval")
+	slabs := fs.String("slabs", "", "number of slab caches to show (0 skips them)")
+	oom := fs.String("oom", "", "number of recent OOM kills to show (0 skips them)")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newMemoryOptions(*interval, *slabs, *oom, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getMemoryReport(opts)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const oomTestLog = `2024-05-02T10:14:01+0000 host kernel: stress invoked oom-killer: gfp_mask=0x140cca(GFP_HIGHUSER_MOVABLE|__GFP_COMP), order=0, oom_score_adj=0
+2024-05-02T10:14:01+0000 host kernel: oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/system.slice/stress.service,task_memcg=/system.slice/stress.service,task=stress,pid=4242,uid=0
+2024-05-02T10:14:01+0000 host kernel: Memory cgroup out of memory: Killed process 4242 (stress) total-vm:1049600kB, anon-rss:524288kB, file-rss:256kB, shmem-rss:0kB, UID:0 pgtables:1100kB oom_score_adj:0
+2024-05-03T08:00:00+0000 host kernel: Out of memory: Killed process 777 (java) total-vm:4096kB, anon-rss:2048kB, file-rss:0kB, shmem-rss:0kB, UID:1000 pgtables:64kB oom_score_adj:0
+2024-05-03T08:00:01+0000 host kernel: eth0: link up
+`
+
+func TestParseOOMEvents(t *testing.T) {
+	events := parseOOMEvents(oomTestLog, 0)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(events), events)
+	}
+	want := OOMEvent{
+		Time: "2024-05-02T10:14:01+0000", PID: 4242, Name: "stress", TotalVM: 1049600 * 1024, AnonRSS: 524288 * 1024,
+		Constraint: "CONSTRAINT_MEMCG", Cgroup: "/system.slice/stress.service",
+	}
+	if events[0] != want {
+		t.Errorf("got %+v, want %+v", events[0], want)
+	}
+	// A kill without a preceding oom-kill line has no constraint
+	if e := events[1]; e.PID != 777 || e.Name != "java" || e.AnonRSS != 2048*1024 || e.Constraint != "" {
+		t.Errorf("second event = %+v", e)
+	}
+
+	if events := parseOOMEvents(oomTestLog, 1); len(events) != 1 || events[0].PID != 777 {
+		t.Errorf("limit 1 kept %+v, want the newest event", events)
+	}
+	if events := parseOOMEvents("", 5); events == nil || len(events) != 0 {
+		t.Errorf("an empty log gave %#v, want an empty list", events)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	values := parseMeminfo("MemTotal:       16318544 kB\nHugePages_Total:       4\nNode 0 MemFree:  1024 kB\nbogus line\n")
+	if values["MemTotal"] != 16318544*1024 || values["HugePages_Total"] != 4 || values["MemFree"] != 1024*1024 {
+		t.Errorf("got %v", values)
+	}
+	if len(values) != 3 {
+		t.Errorf("got %d values, want 3", len(values))
+	}
+}
+
+func TestNewMemoryOptions(t *testing.T) {
+	opts, err := newMemoryOptions("2s", "5", "0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Interval != 2*time.Second || opts.Slabs != 5 || opts.OOM != 0 || !opts.JSON {
+		t.Errorf("got %+v", opts)
+	}
+	for _, c := range [][3]string{{"0s", "", ""}, {"1y", "", ""}, {"", "-1", ""}, {"", "", "x"}} {
+		if _, err := newMemoryOptions(c[0], c[1], c[2], false); err == nil {
+			t.Errorf("newMemoryOptions(%q, %q, %q) succeeded", c[0], c[1], c[2])
+		}
+	}
+}
@@ -31,6 +31,13 @@ var (
 			Help: "CPU usage in percent",
 		},
 	)
+	cpuModeUsage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "osctl_cpu_mode_percent",
+			Help: "CPU time spent per mode in percent, overall (cpu=\"cpu-total\") and per core",
+		},
+		[]string{"cpu", "mode"},
+	)
 	// Extended metrics
 	networkIOBytes = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -46,6 +53,27 @@ var (
 		},
 		[]string{"device", "direction"},
 	)
+	networkIORate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "osctl_network_io_bytes_per_second",
+			Help: "Network throughput in bytes per second",
+		},
+		[]string{"interface", "direction"},
+	)
+	diskIORate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "osctl_disk_io_bytes_per_second",
+			Help: "Disk throughput in bytes per second",
+		},
+		[]string{"device", "direction"},
+	)
+	diskUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "osctl_disk_utilization_percent",
+			Help: "Percentage of time the device was busy",
+		},
+		[]string{"device"},
+	)
 	processCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "osctl_process_count",
@@ -53,15 +81,47 @@ var (
 		},
 		[]string{"state"},
 	)
+	// Service watchdog
+	watchdogUnitHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "osctl_watchdog_unit_healthy",
+			Help: "Whether a supervised unit is active (1) or not (0)",
+		},
+		[]string{"unit"},
+	)
+	watchdogRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "osctl_watchdog_restarts_total",
+			Help: "Restarts performed by the watchdog",
+		},
+		[]string{"unit"},
+	)
+	watchdogEscalations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "osctl_watchdog_escalations_total",
+			Help: "Escalations after a unit exhausted its restart budget",
+		},
+		[]string{"unit", "action"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(ramUsage)
 	prometheus.MustRegister(diskUsage)
 	prometheus.MustRegister(cpuUsage)
+	prometheus.MustRegister(cpuModeUsage)
 	prometheus.MustRegister(networkIOBytes)
 	prometheus.MustRegister(diskIOBytes)
+	prometheus.MustRegister(networkIORate)
+	prometheus.MustRegister(diskIORate)
+	prometheus.MustRegister(diskUtilization)
 	prometheus.MustRegister(processCount)
+	prometheus.MustRegister(watchdogUnitHealthy)
+	prometheus.MustRegister(watchdogRestarts)
+	prometheus.MustRegister(watchdogEscalations)
+	prometheus.MustRegister(newServiceResourceCollector())
+	prometheus.MustRegister(newPressureCollector())
+	prometheus.MustRegister(newLoadCollector())
 }
 
 func runAPI() {
@@ -76,6 +136,12 @@ func runAPI() {
 	// Public metrics endpoint
 	http.Handle("/metrics", promhttp.Handler())
 
+	// Record metric history in the background
+	startHistoryCollector()
+
+	// Supervise the units listed in OSCTL_WATCHDOG_UNITS
+	startWatchdog()
+
 	addr := fmt.Sprintf(":%s", port)
 	log.Printf("Server is listening on port %s...", port)
 	log.Printf("Metrics endpoint available at http://localhost:%s/metrics", port)
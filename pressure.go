@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pressureResources are the resources the kernel reports stall information for
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// SystemPressure is the PSI of one resource from /proc/pressure
+type SystemPressure struct {
+	Resource string `json:"resource"`
+	Pressure
+}
+
+// PressureRule is a health check threshold on one PSI average, for example
+// memory.full.avg60=5:20 (degraded above 5%, unhealthy above 20%)
+type PressureRule struct {
+	Resource  string
+	Kind      string
+	Window    string
+	Degraded  float64
+	Unhealthy float64
+}
+
+// readSystemPressure reads /proc/pressure/{cpu,memory,io}. It fails only if
+// none of them can be read, which means the kernel has PSI disabled.
+func readSystemPressure() ([]SystemPressure, error) {
+	var result []SystemPressure
+	var lastErr error
+	for _, resource := range pressureResources {
+		p, err := readPressureFile(filepath.Join("/proc/pressure", resource))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result = append(result, SystemPressure{Resource: resource, Pressure: p})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("pressure stall information unavailable (kernel needs CONFIG_PSI and psi=1): %v", lastErr)
+	}
+	return result, nil
+}
+
+// getPressure shows the PSI averages and stall totals of CPU, memory and I/O
+func getPressure(asJSON bool) string {
+	pressures, err := readSystemPressure()
+	if err != nil {
+		return fmt.Sprintf("Failed to get pressure. Error: %v", err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(pressures, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode pressure. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	output.WriteString("Share of time tasks stalled waiting for a resource (percent):\n\n")
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	io.WriteString(w, "RESOURCE\tKIND\tAVG10\tAVG60\tAVG300\tTOTAL STALL\n")
+	for _, p := range pressures {
+		lines := []struct {
+			kind string
+			line *PressureLine
+		}{{"some", &p.Some}, {"full", p.Full}}
+		for _, l := range lines {
+			if l.line == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%.2f\t%.2f\t%s\n", p.Resource, l.kind, l.line.Avg10, l.line.Avg60, l.line.Avg300,
+				formatSeconds(float64(l.line.Total)/1e6))
+		}
+	}
+	w.Flush()
+	return strings.TrimRight(output.String(), "\n")
+}
+
+// runPressureCommand parses "osctl pressure" arguments
+func runPressureCommand(args []string) string {
+	fs := flag.NewFlagSet("pressure", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\nUsage: osctl pressure [--json]", err)
+	}
+	return getPressure(*asJSON)
+}
+
+// parsePressureRule parses resource.kind.window=degraded[:unhealthy]
+func parsePressureRule(spec string) (PressureRule, error) {
+	key, thresholds, ok := strings.Cut(spec, "=")
+	parts := strings.Split(key, ".")
+	if !ok || len(parts) != 3 {
+		return PressureRule{}, fmt.Errorf("expected resource.kind.window=degraded[:unhealthy]")
+	}
+	rule := PressureRule{Resource: parts[0], Kind: parts[1], Window: parts[2]}
+	if !slices.Contains(pressureResources, rule.Resource) {
+		return rule, fmt.Errorf("unknown resource %q (cpu, memory, io)", rule.Resource)
+	}
+	if rule.Kind != "some" && rule.Kind != "full" {
+		return rule, fmt.Errorf("unknown kind %q (some, full)", rule.Kind)
+	}
+	if rule.Window != "avg10" && rule.Window != "avg60" && rule.Window != "avg300" {
+		return rule, fmt.Errorf("unknown window %q (avg10, avg60, avg300)", rule.Window)
+	}
+	degraded, unhealthy, hasUnhealthy := strings.Cut(thresholds, ":")
+	var err error
+	if rule.Degraded, err = strconv.ParseFloat(degraded, 64); err != nil || rule.Degraded < 0 {
+		return rule, fmt.Errorf("invalid threshold %q", degraded)
+	}
+	rule.Unhealthy = 100
+	if hasUnhealthy {
+		if rule.Unhealthy, err = strconv.ParseFloat(unhealthy, 64); err != nil || rule.Unhealthy < rule.Degraded {
+			return rule, fmt.Errorf("invalid threshold %q (must not be below %s)", unhealthy, degraded)
+		}
+	}
+	return rule, nil
+}
+
+// getPressureRules returns the PSI health rules from OSCTL_HEALTH_PRESSURE.
+// There are none unless the variable is set.
+func getPressureRules() []PressureRule {
+	value := os.Getenv("OSCTL_HEALTH_PRESSURE")
+	if value == "" || value == "none" {
+		return nil
+	}
+	var rules []PressureRule
+	for _, spec := range splitPatterns(value) {
+		rule, err := parsePressureRule(spec)
+		if err != nil {
+			log.Printf("Ignoring invalid OSCTL_HEALTH_PRESSURE rule %q: %v", spec, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// name is the health check key of a rule, e.g. pressure_memory_full_avg60
+func (r PressureRule) name() string {
+	return fmt.Sprintf("pressure_%s_%s_%s", r.Resource, r.Kind, r.Window)
+}
+
+// value returns the average the rule applies to, or false if the kernel does not report it
+func (r PressureRule) value(pressures []SystemPressure) (float64, bool) {
+	for _, p := range pressures {
+		if p.Resource != r.Resource {
+			continue
+		}
+		line := &p.Some
+		if r.Kind == "full" {
+			line = p.Full
+		}
+		if line == nil {
+			return 0, false
+		}
+		switch r.Window {
+		case "avg10":
+			return line.Avg10, true
+		case "avg60":
+			return line.Avg60, true
+		default:
+			return line.Avg300, true
+		}
+	}
+	return 0, false
+}
+
+// checkPressure evaluates the configured PSI health rules. Without PSI
+// support in the kernel a single degraded "pressure" check is reported.
+func checkPressure() map[string]HealthCheck {
+	checks := make(map[string]HealthCheck)
+	rules := getPressureRules()
+	if len(rules) == 0 {
+		return checks
+	}
+	pressures, err := readSystemPressure()
+	if err != nil {
+		checks["pressure"] = HealthCheck{Status: StatusDegraded, Message: err.Error()}
+		return checks
+	}
+	for _, rule := range rules {
+		v, ok := rule.value(pressures)
+		if !ok {
+			checks[rule.name()] = HealthCheck{Status: StatusHealthy, Message: fmt.Sprintf("%s %s pressure not reported by this kernel", rule.Resource, rule.Kind)}
+			continue
+		}
+		status := StatusHealthy
+		if v > rule.Unhealthy {
+			status = StatusUnhealthy
+		} else if v > rule.Degraded {
+			status = StatusDegraded
+		}
+		checks[rule.name()] = HealthCheck{
+			Status:  status,
+			Value:   fmt.Sprintf("%.2f%%", v),
+			Message: fmt.Sprintf("%s %s stall %s (degraded above %g%%, unhealthy above %g%%)", rule.Resource, rule.Kind, rule.Window, rule.Degraded, rule.Unhealthy),
+		}
+	}
+	return checks
+}
+
+// usageCheckEnabled reports whether a percent-used health check (memory, disk,
+// cpu) is listed in OSCTL_HEALTH_USAGE (default: all, "none" disables them)
+func usageCheckEnabled(name string) bool {
+	value := os.Getenv("OSCTL_HEALTH_USAGE")
+	if value == "" {
+		return true
+	}
+	return slices.Contains(splitPatterns(value), name)
+}
+
+// pressureCollector exports system PSI at scrape time
+type pressureCollector struct {
+	avg   *prometheus.Desc
+	stall *prometheus.Desc
+}
+
+func newPressureCollector() *pressureCollector {
+	return &pressureCollector{
+		avg:   prometheus.NewDesc("osctl_pressure_percent", "Share of time tasks stalled on a resource, averaged over a window (10s, 60s, 300s)", []string{"resource", "kind", "window"}, nil),
+		stall: prometheus.NewDesc("osctl_pressure_stall_seconds_total", "Total time tasks stalled on a resource", []string{"resource", "kind"}, nil),
+	}
+}
+
+func (c *pressureCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.avg
+	ch <- c.stall
+}
+
+func (c *pressureCollector) Collect(ch chan<- prometheus.Metric) {
+	pressures, err := readSystemPressure()
+	if err != nil {
+		return
+	}
+	for _, p := range pressures {
+		for kind, line := range map[string]*PressureLine{"some": &p.Some, "full": p.Full} {
+			if line == nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.avg, prometheus.GaugeValue, line.Avg10, p.Resource, kind, "10s")
+			ch <- prometheus.MustNewConstMetric(c.avg, prometheus.GaugeValue, line.Avg60, p.Resource, kind, "60s")
+			ch <- prometheus.MustNewConstMetric(c.avg, prometheus.GaugeValue, line.Avg300, p.Resource, kind, "300s")
+			ch <- prometheus.MustNewConstMetric(c.stall, prometheus.CounterValue, float64(line.Total)/1e6, p.Resource, kind)
+		}
+	}
+}
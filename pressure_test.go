@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+func TestParsePressureRule(t *testing.T) {
+	rule, err := parsePressureRule("memory.full.avg60=5:20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PressureRule{Resource: "memory", Kind: "full", Window: "avg60", Degraded: 5, Unhealthy: 20}
+	if rule != want {
+		t.Errorf("got %+v, want %+v", rule, want)
+	}
+	if rule.name() != "pressure_memory_full_avg60" {
+		t.Errorf("name is %q", rule.name())
+	}
+
+	// Without an unhealthy threshold the rule only degrades
+	if rule, err := parsePressureRule("io.some.avg10=2.5"); err != nil || rule.Degraded != 2.5 || rule.Unhealthy != 100 {
+		t.Errorf("got %+v, %v", rule, err)
+	}
+
+	for _, spec := range []string{
+		"memory.full.avg60",
+		"memory.full=5",
+		"disk.some.avg10=5",
+		"cpu.most.avg10=5",
+		"cpu.some.avg30=5",
+		"cpu.some.avg10=x",
+		"cpu.some.avg10=-1",
+		"cpu.some.avg10=10:5",
+	} {
+		if _, err := parsePressureRule(spec); err == nil {
+			t.Errorf("parsePressureRule(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestPressureRulesAreOptIn(t *testing.T) {
+	t.Setenv("OSCTL_HEALTH_PRESSURE", "")
+	if rules := getPressureRules(); len(rules) != 0 {
+		t.Errorf("got rules %+v without configuration", rules)
+	}
+	if checks := checkPressure(); len(checks) != 0 {
+		t.Errorf("got checks %+v without configuration", checks)
+	}
+	t.Setenv("OSCTL_HEALTH_PRESSURE", "none")
+	if rules := getPressureRules(); len(rules) != 0 {
+		t.Errorf("\"none\" gave rules %+v", rules)
+	}
+
+	// Invalid rules are skipped, valid ones kept
+	t.Setenv("OSCTL_HEALTH_PRESSURE", "memory.full.avg60=5:20, bogus, io.some.avg300=10")
+	rules := getPressureRules()
+	if len(rules) != 2 || rules[0].Resource != "memory" || rules[1].Resource != "io" {
+		t.Errorf("got %+v", rules)
+	}
+}
+
+func TestPressureRuleValue(t *testing.T) {
+	p, err := parsePressure("some avg10=1.50 avg60=2.50 avg300=3.50 total=100\nfull avg10=0.50 avg60=0.75 avg300=1.00 total=50\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pressures := []SystemPressure{{Resource: "memory", Pressure: p}, {Resource: "cpu", Pressure: Pressure{Some: p.Some}}}
+	for _, c := range []struct {
+		rule PressureRule
+		want float64
+		ok   bool
+	}{
+		{PressureRule{Resource: "memory", Kind: "some", Window: "avg10"}, 1.5, true},
+		{PressureRule{Resource: "memory", Kind: "full", Window: "avg60"}, 0.75, true},
+		{PressureRule{Resource: "memory", Kind: "full", Window: "avg300"}, 1, true},
+		{PressureRule{Resource: "cpu", Kind: "full", Window: "avg10"}, 0, false},
+		{PressureRule{Resource: "io", Kind: "some", Window: "avg10"}, 0, false},
+	} {
+		got, ok := c.rule.value(pressures)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s = %v, %v; want %v, %v", c.rule.name(), got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestUsageCheckEnabled(t *testing.T) {
+	t.Setenv("OSCTL_HEALTH_USAGE", "")
+	if !usageCheckEnabled("disk") {
+		t.Error("usage checks are not enabled by default")
+	}
+	t.Setenv("OSCTL_HEALTH_USAGE", "memory,cpu")
+	if usageCheckEnabled("disk") || !usageCheckEnabled("cpu") {
+		t.Error("OSCTL_HEALTH_USAGE=memory,cpu not honoured")
+	}
+	t.Setenv("OSCTL_HEALTH_USAGE", "none")
+	if usageCheckEnabled("memory") {
+		t.Error("\"none\" left the memory check enabled")
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+const (
+	// maxListedFDs caps the descriptors shown in text output; JSON lists all
+	maxListedFDs = 50
+	// maxListedMappings is the number of largest mappings shown
+	maxListedMappings = 5
+	redactedValue     = "<redacted>"
+)
+
+// ProcessRef identifies a process in a parent chain
+type ProcessRef struct {
+	PID  int32  `json:"pid"`
+	Name string `json:"name"`
+}
+
+// ProcessFD is an open file descriptor and what it points to
+type ProcessFD struct {
+	FD     int    `json:"fd"`
+	Target string `json:"target"`
+}
+
+// ProcessSocket is a socket held by the process
+type ProcessSocket struct {
+	FD     uint32 `json:"fd"`
+	Proto  string `json:"proto"`
+	Local  string `json:"local"`
+	Remote string `json:"remote,omitempty"`
+	State  string `json:"state,omitempty"`
+}
+
+// ProcessLimit is one line of /proc/<pid>/limits
+type ProcessLimit struct {
+	Name  string `json:"name"`
+	Soft  string `json:"soft"`
+	Hard  string `json:"hard"`
+	Units string `json:"units,omitempty"`
+}
+
+// MemoryMapping is the resident size of one mapped path
+type MemoryMapping struct {
+	Path string `json:"path"`
+	RSS  uint64 `json:"rss_bytes"`
+}
+
+// MemoryMapsSummary summarizes /proc/<pid>/smaps
+type MemoryMapsSummary struct {
+	Mappings  int             `json:"mappings"`
+	Size      uint64          `json:"size_bytes"`
+	RSS       uint64          `json:"rss_bytes"`
+	PSS       uint64          `json:"pss_bytes"`
+	Anonymous uint64          `json:"anonymous_bytes"`
+	Swap      uint64          `json:"swap_bytes"`
+	Largest   []MemoryMapping `json:"largest,omitempty"`
+}
+
+// ProcessDetails is the extended view of a single process
+type ProcessDetails struct {
+	PID                 int32              `json:"pid"`
+	PPID                int32              `json:"ppid"`
+	Name                string             `json:"name"`
+	Exe                 string             `json:"exe,omitempty"`
+	Command             string             `json:"command"`
+	Status              string             `json:"status"`
+	User                string             `json:"user"`
+	Nice                int32              `json:"nice"`
+	CPUPercent          float64            `json:"cpu_percent"`
+	MemPercent          float32            `json:"mem_percent"`
+	RSS                 uint64             `json:"rss_bytes"`
+	VMS                 uint64             `json:"vms_bytes"`
+	Swap                uint64             `json:"swap_bytes"`
+	Threads             int32              `json:"threads"`
+	StartTime           time.Time          `json:"start_time"`
+	AgeSeconds          int64              `json:"age_seconds"`
+	CWD                 string             `json:"cwd,omitempty"`
+	Parents             []ProcessRef       `json:"parents,omitempty"`
+	ControlGroup        string             `json:"control_group,omitempty"`
+	Unit                string             `json:"unit,omitempty"`
+	Namespaces          map[string]string  `json:"namespaces,omitempty"`
+	FDs                 []ProcessFD        `json:"fds,omitempty"`
+	Sockets             []ProcessSocket    `json:"sockets,omitempty"`
+	Limits              []ProcessLimit     `json:"limits,omitempty"`
+	Environment         []string           `json:"environment,omitempty"`
+	EnvironmentRedacted bool               `json:"environment_redacted"`
+	ReadBytes           uint64             `json:"read_bytes"`
+	WriteBytes          uint64             `json:"write_bytes"`
+	ReadSyscalls        uint64             `json:"read_syscalls"`
+	WriteSyscalls       uint64             `json:"write_syscalls"`
+	VoluntarySwitches   int64              `json:"voluntary_ctx_switches"`
+	InvoluntarySwitches int64              `json:"involuntary_ctx_switches"`
+	MinorFaults         uint64             `json:"minor_faults"`
+	MajorFaults         uint64             `json:"major_faults"`
+	MemoryMaps          *MemoryMapsSummary `json:"memory_maps,omitempty"`
+}
+
+// processParents walks up the parent chain to PID 1, nearest parent first
+func processParents(p *process.Process) []ProcessRef {
+	var parents []ProcessRef
+	seen := map[int32]bool{p.Pid: true}
+	ppid, err := p.Ppid()
+	for err == nil && ppid > 0 && !seen[ppid] {
+		seen[ppid] = true
+		parent, perr := process.NewProcess(ppid)
+		if perr != nil {
+			break
+		}
+		name, _ := parent.Name()
+		parents = append(parents, ProcessRef{PID: ppid, Name: name})
+		ppid, err = parent.Ppid()
+	}
+	return parents
+}
+
+// readProcessFDs lists /proc/<pid>/fd with the link targets, like lsof
+func readProcessFDs(pid int32) ([]ProcessFD, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(int(pid)), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fds := make([]ProcessFD, 0, len(entries))
+	for _, e := range entries {
+		fd, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		fds = append(fds, ProcessFD{FD: fd, Target: target})
+	}
+	sort.Slice(fds, func(i, j int) bool { return fds[i].FD < fds[j].FD })
+	return fds, nil
+}
+
+// readProcessSockets returns the inet and unix sockets of a process
+func readProcessSockets(p *process.Process) []ProcessSocket {
+	conns, err := p.Connections()
+	if err != nil {
+		return nil
+	}
+	sockets := make([]ProcessSocket, 0, len(conns))
+	for _, c := range conns {
+		s := ProcessSocket{FD: c.Fd, State: c.Status}
+		switch c.Family {
+		case syscall.AF_UNIX:
+			s.Proto = "unix"
+			s.Local = c.Laddr.IP
+		default:
+			s.Proto = "tcp"
+			if c.Type == syscall.SOCK_DGRAM {
+				s.Proto = "udp"
+			}
+			if c.Family == syscall.AF_INET6 {
+				s.Proto += "6"
+			}
+			s.Local = fmt.Sprintf("%s:%d", c.Laddr.IP, c.Laddr.Port)
+			if c.Raddr.IP != "" {
+				s.Remote = fmt.Sprintf("%s:%d", c.Raddr.IP, c.Raddr.Port)
+			}
+		}
+		if s.State == "NONE" {
+			s.State = ""
+		}
+		sockets = append(sockets, s)
+	}
+	sort.Slice(sockets, func(i, j int) bool { return sockets[i].FD < sockets[j].FD })
+	return sockets
+}
+
+// readProcessLimits reads /proc/<pid>/limits
+func readProcessLimits(pid int32) ([]ProcessLimit, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "limits"))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcessLimits(string(data))
+}
+
+// parseProcessLimits parses the limits file, which has fixed-width columns
+// positioned by its header
+func parseProcessLimits(data string) ([]ProcessLimit, error) {
+	lines := strings.Split(data, "\n")
+	if len(lines) < 2 {
+		return nil, nil
+	}
+	header := lines[0]
+	softCol, hardCol, unitsCol := strings.Index(header, "Soft Limit"), strings.Index(header, "Hard Limit"), strings.Index(header, "Units")
+	if softCol < 0 || hardCol < 0 || unitsCol < 0 {
+		return nil, fmt.Errorf("unexpected limits format")
+	}
+	var limits []ProcessLimit
+	for _, line := range lines[1:] {
+		if len(line) < unitsCol {
+			continue
+		}
+		limits = append(limits, ProcessLimit{
+			Name:  strings.TrimSpace(line[:softCol]),
+			Soft:  strings.TrimSpace(line[softCol:hardCol]),
+			Hard:  strings.TrimSpace(line[hardCol:unitsCol]),
+			Units: strings.TrimSpace(line[unitsCol:]),
+		})
+	}
+	return limits, nil
+}
+
+// readProcessNamespaces returns the namespace identifiers of a process
+func readProcessNamespaces(pid int32) map[string]string {
+	dir := filepath.Join("/proc", strconv.Itoa(int(pid)), "ns")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	namespaces := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if target, err := os.Readlink(filepath.Join(dir, e.Name())); err == nil {
+			namespaces[e.Name()] = target
+		}
+	}
+	return namespaces
+}
+
+// redactEnvironment keeps variable names and hides their values
+func redactEnvironment(env []string) []string {
+	redacted := make([]string, 0, len(env))
+	for _, kv := range env {
+		if kv == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(kv, "=")
+		redacted = append(redacted, name+"="+redactedValue)
+	}
+	return redacted
+}
+
+// summarizeMemoryMaps totals the mappings of a process and finds the largest paths
+func summarizeMemoryMaps(p *process.Process) *MemoryMapsSummary {
+	maps, err := p.MemoryMaps(false)
+	if err != nil || maps == nil {
+		return nil
+	}
+	summary := &MemoryMapsSummary{Mappings: len(*maps)}
+	byPath := make(map[string]uint64)
+	for _, m := range *maps {
+		// gopsutil reports smaps sizes in kB
+		summary.Size += m.Size * 1024
+		summary.RSS += m.Rss * 1024
+		summary.PSS += m.Pss * 1024
+		summary.Anonymous += m.Anonymous * 1024
+		summary.Swap += m.Swap * 1024
+		// Anonymous mappings have no path; gopsutil then reports the inode column
+		path := m.Path
+		if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "[") {
+			path = "[anon]"
+		}
+		byPath[path] += m.Rss * 1024
+	}
+	for path, rss := range byPath {
+		summary.Largest = append(summary.Largest, MemoryMapping{Path: path, RSS: rss})
+	}
+	sort.Slice(summary.Largest, func(i, j int) bool { return summary.Largest[i].RSS > summary.Largest[j].RSS })
+	if len(summary.Largest) > maxListedMappings {
+		summary.Largest = summary.Largest[:maxListedMappings]
+	}
+	return summary
+}
+
+// collectProcessDetails reads everything known about a process. Fields that
+// cannot be read (e.g. another user's process without root) are left empty.
+func collectProcessDetails(pid int32, showEnv bool) (ProcessDetails, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return ProcessDetails{}, err
+	}
+	d := ProcessDetails{PID: pid, EnvironmentRedacted: !showEnv}
+	d.PPID, _ = p.Ppid()
+	d.Name, _ = p.Name()
+	d.Exe, _ = p.Exe()
+	d.Command, _ = p.Cmdline()
+	d.Status, _ = p.Status()
+	d.User, _ = p.Username()
+	// gopsutil reports the raw getpriority(2) value, which is 20 - nice
+	if raw, err := p.Nice(); err == nil {
+		d.Nice = 20 - raw
+	}
+	d.CPUPercent, _ = p.CPUPercent()
+	d.MemPercent, _ = p.MemoryPercent()
+	if mi, err := p.MemoryInfo(); err == nil && mi != nil {
+		d.RSS, d.VMS, d.Swap = mi.RSS, mi.VMS, mi.Swap
+	}
+	d.Threads, _ = p.NumThreads()
+	if ms, err := p.CreateTime(); err == nil {
+		d.StartTime = time.UnixMilli(ms)
+		d.AgeSeconds = int64(time.Since(d.StartTime).Seconds())
+	}
+	d.CWD, _ = p.Cwd()
+	d.Parents = processParents(p)
+	if group, err := processControlGroup(pid); err == nil {
+		d.ControlGroup = group
+		d.Unit = processUnit(pid)
+	}
+	d.Namespaces = readProcessNamespaces(pid)
+	d.FDs, _ = readProcessFDs(pid)
+	d.Sockets = readProcessSockets(p)
+	d.Limits, _ = readProcessLimits(pid)
+	if env, err := p.Environ(); err == nil {
+		if showEnv {
+			for _, kv := range env {
+				if kv != "" {
+					d.Environment = append(d.Environment, kv)
+				}
+			}
+		} else {
+			d.Environment = redactEnvironment(env)
+		}
+	}
+	if ioc, err := p.IOCounters(); err == nil && ioc != nil {
+		d.ReadBytes, d.WriteBytes = ioc.ReadBytes, ioc.WriteBytes
+		d.ReadSyscalls, d.WriteSyscalls = ioc.ReadCount, ioc.WriteCount
+	}
+	if cs, err := p.NumCtxSwitches(); err == nil && cs != nil {
+		d.VoluntarySwitches, d.InvoluntarySwitches = cs.Voluntary, cs.Involuntary
+	}
+	if pf, err := p.PageFaults(); err == nil && pf != nil {
+		d.MinorFaults, d.MajorFaults = pf.MinorFaults, pf.MajorFaults
+	}
+	d.MemoryMaps = summarizeMemoryMaps(p)
+	return d, nil
+}
+
+// formatProcessDetails renders process details as text
+func formatProcessDetails(d ProcessDetails) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Name: %s\n", d.Name))
+	output.WriteString(fmt.Sprintf("PID: %d (parent %d)\n", d.PID, d.PPID))
+	output.WriteString(fmt.Sprintf("Command: %s\n", d.Command))
+	if d.Exe != "" {
+		output.WriteString(fmt.Sprintf("Executable: %s\n", d.Exe))
+	}
+	output.WriteString(fmt.Sprintf("Status: %s\n", d.Status))
+	output.WriteString(fmt.Sprintf("User: %s\n", d.User))
+	if !d.StartTime.IsZero() {
+		output.WriteString(fmt.Sprintf("Started: %s (%s ago)\n", d.StartTime.Format("2006-01-02 15:04:05 MST"),
+			(time.Duration(d.AgeSeconds) * time.Second).String()))
+	}
+	output.WriteString(fmt.Sprintf("CWD: %s\n", d.CWD))
+	output.WriteString(fmt.Sprintf("Nice: %d\n", d.Nice))
+	output.WriteString(fmt.Sprintf("CPU%%: %.2f (average since start)\n", d.CPUPercent))
+	output.WriteString(fmt.Sprintf("Memory%%: %.2f\n", d.MemPercent))
+	output.WriteString(fmt.Sprintf("RSS: %s  VMS: %s  Swap: %s\n", formatBytes(d.RSS), formatBytes(d.VMS), formatBytes(d.Swap)))
+	output.WriteString(fmt.Sprintf("Threads: %d\n", d.Threads))
+
+	if len(d.Parents) > 0 {
+		chain := make([]string, len(d.Parents))
+		for i, p := range d.Parents {
+			chain[i] = fmt.Sprintf("%s(%d)", p.Name, p.PID)
+		}
+		output.WriteString(fmt.Sprintf("Parents: %s\n", strings.Join(chain, " <- ")))
+	}
+	if d.ControlGroup != "" {
+		output.WriteString(fmt.Sprintf("CGroup: %s\n", d.ControlGroup))
+	}
+	if d.Unit != "" {
+		output.WriteString(fmt.Sprintf("Unit: %s\n", d.Unit))
+	}
+
+	output.WriteString("\nActivity:\n")
+	output.WriteString(fmt.Sprintf("  Read: %s in %d syscalls\n", formatBytes(d.ReadBytes), d.ReadSyscalls))
+	output.WriteString(fmt.Sprintf("  Written: %s in %d syscalls\n", formatBytes(d.WriteBytes), d.WriteSyscalls))
+	output.WriteString(fmt.Sprintf("  Context switches: %d voluntary, %d involuntary\n", d.VoluntarySwitches, d.InvoluntarySwitches))
+	output.WriteString(fmt.Sprintf("  Page faults: %d minor, %d major\n", d.MinorFaults, d.MajorFaults))
+
+	if m := d.MemoryMaps; m != nil {
+		output.WriteString(fmt.Sprintf("\nMemory maps: %d mappings, size %s, RSS %s, PSS %s, anonymous %s, swap %s\n",
+			m.Mappings, formatBytes(m.Size), formatBytes(m.RSS), formatBytes(m.PSS), formatBytes(m.Anonymous), formatBytes(m.Swap)))
+		for _, l := range m.Largest {
+			output.WriteString(fmt.Sprintf("  %10s  %s\n", formatBytes(l.RSS), l.Path))
+		}
+	}
+
+	if len(d.FDs) > 0 {
+		output.WriteString(fmt.Sprintf("\nOpen file descriptors (%d):\n", len(d.FDs)))
+		for i, fd := range d.FDs {
+			if i == maxListedFDs {
+				output.WriteString(fmt.Sprintf("  ... %d more (use --json for all)\n", len(d.FDs)-maxListedFDs))
+				break
+			}
+			output.WriteString(fmt.Sprintf("  %4d  %s\n", fd.FD, fd.Target))
+		}
+	}
+
+	if len(d.Sockets) > 0 {
+		output.WriteString("\nSockets:\n")
+		w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  FD\tPROTO\tLOCAL\tREMOTE\tSTATE")
+		for _, s := range d.Sockets {
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\n", s.FD, s.Proto, s.Local, s.Remote, s.State)
+		}
+		w.Flush()
+	}
+
+	if len(d.Limits) > 0 {
+		output.WriteString("\nLimits:\n")
+		w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  LIMIT\tSOFT\tHARD\tUNITS")
+		for _, l := range d.Limits {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", l.Name, l.Soft, l.Hard, l.Units)
+		}
+		w.Flush()
+	}
+
+	if len(d.Namespaces) > 0 {
+		output.WriteString("\nNamespaces:\n")
+		names := make([]string, 0, len(d.Namespaces))
+		for name := range d.Namespaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			output.WriteString(fmt.Sprintf("  %-18s %s\n", name, d.Namespaces[name]))
+		}
+	}
+
+	if len(d.Environment) > 0 {
+		if d.EnvironmentRedacted {
+			output.WriteString("\nEnvironment (values redacted, use --show-env):\n")
+		} else {
+			output.WriteString("\nEnvironment:\n")
+		}
+		for _, kv := range d.Environment {
+			output.WriteString("  " + kv + "\n")
+		}
+	}
+	return output.String()
+}
+
+// getProcessInfo gets detailed information about a process
+func getProcessInfo(pid string, showEnv, asJSON bool) string {
+	// Validate PID
+	pidInt, err := strconv.Atoi(pid)
+	if err != nil || pidInt <= 0 {
+		return fmt.Sprintf("Invalid PID: %s", pid)
+	}
+
+	details, err := collectProcessDetails(int32(pidInt), showEnv)
+	if err != nil {
+		return fmt.Sprintf("Process %s not found. Error: %v", pid, err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(details, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode process details. Error: %v", err)
+		}
+		return string(data)
+	}
+	return formatProcessDetails(details)
+}
+
+// runProcessInfo parses "osctl process info" arguments
+func runProcessInfo(args []string) string {
+	usage := "Usage: osctl process info <pid> [--show-env] [--json]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+	fs := flag.NewFlagSet("process info", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	showEnv := fs.Bool("show-env", false, "show environment values")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getProcessInfo(args[0], *showEnv, *asJSON)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+const processLimitsTestData = `Limit                     Soft Limit           Hard Limit           Units     
+Max cpu time              unlimited            unlimited            seconds   
+Max open files            1024                 524288               files     
+Max pending signals       63405                63405                signals   
+Max realtime timeout      unlimited            unlimited            us        
+`
+
+func TestParseProcessLimits(t *testing.T) {
+	limits, err := parseProcessLimits(processLimitsTestData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProcessLimit{
+		{Name: "Max cpu time", Soft: "unlimited", Hard: "unlimited", Units: "seconds"},
+		{Name: "Max open files", Soft: "1024", Hard: "524288", Units: "files"},
+		{Name: "Max pending signals", Soft: "63405", Hard: "63405", Units: "signals"},
+		{Name: "Max realtime timeout", Soft: "unlimited", Hard: "unlimited", Units: "us"},
+	}
+	if !slices.Equal(limits, want) {
+		t.Errorf("got %+v, want %+v", limits, want)
+	}
+
+	if limits, err := parseProcessLimits(""); err != nil || len(limits) != 0 {
+		t.Errorf("an empty file gave %+v, %v", limits, err)
+	}
+	if _, err := parseProcessLimits("Limit Soft Hard\nMax cpu time 1 2\n"); err == nil {
+		t.Error("a file without the expected header was accepted")
+	}
+}
+
+func TestReadProcessLimitsOfSelf(t *testing.T) {
+	limits, err := readProcessLimits(int32(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(limits, func(l ProcessLimit) bool { return l.Name == "Max open files" && l.Units == "files" }) {
+		t.Errorf("Max open files missing from %+v", limits)
+	}
+}
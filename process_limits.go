@@ -0,0 +1,440 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/process"
+	"golang.org/x/sys/unix"
+)
+
+// I/O scheduling classes and constants from linux/ioprio.h
+const (
+	ioprioClassShift = 13
+	ioprioWhoProcess = 1
+	ioprioMaxLevel   = 7
+)
+
+// ioprioClasses maps I/O scheduling class names to their kernel values
+var ioprioClasses = map[string]int{
+	"none":        0,
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// rlimitResources maps prlimit resource names to RLIMIT_* values
+var rlimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+// ProcessTuning is the result of reading or changing one process setting
+type ProcessTuning struct {
+	PID     int32  `json:"pid"`
+	Name    string `json:"name"`
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new,omitempty"`
+}
+
+// RlimitChange is a validated prlimit request such as nofile=1024:65535
+type RlimitChange struct {
+	Name     string
+	Resource int
+	Soft     uint64
+	Hard     uint64
+}
+
+// parseTuningPID validates a PID and returns the process name
+func parseTuningPID(pid string) (int32, string, error) {
+	n, err := strconv.Atoi(pid)
+	if err != nil || n <= 0 {
+		return 0, "", fmt.Errorf("invalid PID: %s", pid)
+	}
+	p, err := process.NewProcess(int32(n))
+	if err != nil {
+		return 0, "", fmt.Errorf("process %d not found", n)
+	}
+	name, _ := p.Name()
+	return int32(n), name, nil
+}
+
+// formatTunings renders tuning results as text or JSON
+func formatTunings(results []ProcessTuning, asJSON bool) string {
+	if asJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode results. Error: %v", err)
+		}
+		return string(data)
+	}
+	var output strings.Builder
+	for _, r := range results {
+		if r.New != "" {
+			output.WriteString(fmt.Sprintf("Process %d (%s): %s %s -> %s\n", r.PID, r.Name, r.Setting, r.Old, r.New))
+		} else {
+			output.WriteString(fmt.Sprintf("Process %d (%s): %s %s\n", r.PID, r.Name, r.Setting, r.Old))
+		}
+	}
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+// ioprioString renders an I/O priority as class:level
+func ioprioString(ioprio int) string {
+	class, level := ioprio>>ioprioClassShift, ioprio&((1<<ioprioClassShift)-1)
+	for name, c := range ioprioClasses {
+		if c == class {
+			if class == 0 || class == 3 {
+				return name
+			}
+			return fmt.Sprintf("%s:%d", name, level)
+		}
+	}
+	return strconv.Itoa(ioprio)
+}
+
+// parseIOPriority validates an I/O class and level. Realtime and best-effort
+// default to level 4; none and idle take no level.
+func parseIOPriority(class, level string) (int, error) {
+	c, ok := ioprioClasses[class]
+	if !ok {
+		return 0, fmt.Errorf("invalid I/O class %q. Valid: none, realtime, best-effort, idle", class)
+	}
+	l := 4
+	if c == 0 || c == 3 {
+		if level != "" {
+			return 0, fmt.Errorf("I/O class %s takes no level", class)
+		}
+		l = 0
+	} else if level != "" {
+		n, err := strconv.Atoi(level)
+		if err != nil || n < 0 || n > ioprioMaxLevel {
+			return 0, fmt.Errorf("invalid I/O level %q (must be between 0 and %d)", level, ioprioMaxLevel)
+		}
+		l = n
+	}
+	return c<<ioprioClassShift | l, nil
+}
+
+func getIOPriority(pid int32) (int, error) {
+	r, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+// setProcessIOPriority shows or sets the I/O scheduling class of a process
+func setProcessIOPriority(pid, class, level string, guard ProcessGuard, asJSON bool) string {
+	pidInt, name, err := parseTuningPID(pid)
+	if err != nil {
+		return err.Error()
+	}
+	old, err := getIOPriority(pidInt)
+	if err != nil {
+		return fmt.Sprintf("Failed to read I/O priority of process %s. Error: %v", pid, err)
+	}
+	result := ProcessTuning{PID: pidInt, Name: name, Setting: "ionice", Old: ioprioString(old)}
+	if class == "" {
+		return formatTunings([]ProcessTuning{result}, asJSON)
+	}
+
+	ioprio, err := parseIOPriority(class, level)
+	if err != nil {
+		return err.Error()
+	}
+	if err := checkProcessProtection(pidInt, "ionice", guard); err != nil {
+		return fmt.Sprintf("Refusing to set I/O priority for process %s: %v", pid, err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pidInt), uintptr(ioprio)); errno != 0 {
+		return fmt.Sprintf("Failed to set I/O priority for process %s. Error: %v", pid, errno)
+	}
+	result.New = ioprioString(ioprio)
+	return formatTunings([]ProcessTuning{result}, asJSON)
+}
+
+// onlineCPUCount returns the number of configured CPUs, used to bound affinity masks
+func onlineCPUCount() int {
+	data, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err == nil {
+		if cpus, err := parseCPUList(strings.TrimSpace(string(data)), math.MaxInt32); err == nil && len(cpus) > 0 {
+			return cpus[len(cpus)-1] + 1
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// parseCPUList parses a list such as "0-3,6" into sorted CPU numbers below max
+func parseCPUList(list string, max int) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU range %q", part)
+			}
+		}
+		if end >= max {
+			return nil, fmt.Errorf("CPU %d does not exist (have %d)", end, max)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			seen[cpu] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil, errors.New("empty CPU list")
+	}
+	cpus := make([]int, 0, len(seen))
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// formatCPUList renders CPU numbers compactly, e.g. 0-3,6
+func formatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// cpuSetList returns the CPUs in a set
+func cpuSetList(set *unix.CPUSet, max int) []int {
+	var cpus []int
+	for cpu := 0; cpu < max; cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// setProcessAffinity shows or sets the CPU affinity of a process
+func setProcessAffinity(pid, cpuList string, guard ProcessGuard, asJSON bool) string {
+	pidInt, name, err := parseTuningPID(pid)
+	if err != nil {
+		return err.Error()
+	}
+	max := onlineCPUCount()
+	var old unix.CPUSet
+	if err := unix.SchedGetaffinity(int(pidInt), &old); err != nil {
+		return fmt.Sprintf("Failed to read CPU affinity of process %s. Error: %v", pid, err)
+	}
+	result := ProcessTuning{PID: pidInt, Name: name, Setting: "affinity", Old: formatCPUList(cpuSetList(&old, max))}
+	if cpuList == "" {
+		return formatTunings([]ProcessTuning{result}, asJSON)
+	}
+
+	cpus, err := parseCPUList(cpuList, max)
+	if err != nil {
+		return err.Error()
+	}
+	if err := checkProcessProtection(pidInt, "affinity", guard); err != nil {
+		return fmt.Sprintf("Refusing to set CPU affinity for process %s: %v", pid, err)
+	}
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	if err := unix.SchedSetaffinity(int(pidInt), &set); err != nil {
+		return fmt.Sprintf("Failed to set CPU affinity for process %s. Error: %v", pid, err)
+	}
+	result.New = formatCPUList(cpus)
+	return formatTunings([]ProcessTuning{result}, asJSON)
+}
+
+// parseRlimitValue accepts a number or "unlimited"
+func parseRlimitValue(value string) (uint64, error) {
+	if value == "unlimited" || value == "infinity" {
+		return unix.RLIM_INFINITY, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// rlimitString renders a limit value
+func rlimitString(v uint64) string {
+	if v == unix.RLIM_INFINITY {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// parseRlimitChanges validates specs such as nofile=65535 or nofile=1024:65535.
+// A single value sets both the soft and the hard limit.
+func parseRlimitChanges(specs []string) ([]RlimitChange, error) {
+	var changes []RlimitChange
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		resource, known := rlimitResources[strings.ToLower(name)]
+		if !ok || !known {
+			names := make([]string, 0, len(rlimitResources))
+			for n := range rlimitResources {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("invalid limit %q. Use NAME=SOFT[:HARD] with NAME one of %s", spec, strings.Join(names, ", "))
+		}
+		change := RlimitChange{Name: strings.ToLower(name), Resource: resource}
+		soft, hard, hasHard := strings.Cut(value, ":")
+		var err error
+		if change.Soft, err = parseRlimitValue(soft); err != nil {
+			return nil, fmt.Errorf("invalid value in %q", spec)
+		}
+		change.Hard = change.Soft
+		if hasHard {
+			if change.Hard, err = parseRlimitValue(hard); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", spec)
+			}
+		}
+		if change.Soft > change.Hard {
+			return nil, fmt.Errorf("soft limit exceeds hard limit in %q", spec)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// setProcessLimits shows the resource limits of a process, or applies changes with prlimit(2)
+func setProcessLimits(pid string, specs []string, guard ProcessGuard, asJSON bool) string {
+	pidInt, name, err := parseTuningPID(pid)
+	if err != nil {
+		return err.Error()
+	}
+	if len(specs) == 0 {
+		names := make([]string, 0, len(rlimitResources))
+		for n := range rlimitResources {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		var results []ProcessTuning
+		for _, n := range names {
+			var cur unix.Rlimit
+			if err := unix.Prlimit(int(pidInt), rlimitResources[n], nil, &cur); err != nil {
+				return fmt.Sprintf("Failed to read limits of process %s. Error: %v", pid, err)
+			}
+			results = append(results, ProcessTuning{PID: pidInt, Name: name, Setting: n,
+				Old: rlimitString(cur.Cur) + ":" + rlimitString(cur.Max)})
+		}
+		return formatTunings(results, asJSON)
+	}
+
+	changes, err := parseRlimitChanges(specs)
+	if err != nil {
+		return err.Error()
+	}
+	if err := checkProcessProtection(pidInt, "limit", guard); err != nil {
+		return fmt.Sprintf("Refusing to set limits for process %s: %v", pid, err)
+	}
+	var results []ProcessTuning
+	for _, c := range changes {
+		var old unix.Rlimit
+		updated := unix.Rlimit{Cur: c.Soft, Max: c.Hard}
+		if err := unix.Prlimit(int(pidInt), c.Resource, &updated, &old); err != nil {
+			return fmt.Sprintf("Failed to set %s for process %s. Error: %v", c.Name, pid, err) + "\n" + formatTunings(results, false)
+		}
+		results = append(results, ProcessTuning{PID: pidInt, Name: name, Setting: c.Name,
+			Old: rlimitString(old.Cur) + ":" + rlimitString(old.Max), New: rlimitString(c.Soft) + ":" + rlimitString(c.Hard)})
+	}
+	return formatTunings(results, asJSON)
+}
+
+// splitPositional separates leading positional arguments from flags
+func splitPositional(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// runProcessTuning parses "osctl process ionice|affinity|limit" arguments
+func runProcessTuning(action string, args []string) string {
+	usages := map[string]string{
+		"ionice":   "Usage: osctl process ionice <pid> [--class none|realtime|best-effort|idle] [--level 0-7] [--override] [--json]",
+		"affinity": "Usage: osctl process affinity <pid> [cpu-list] [--override] [--json]",
+		"limit":    "Usage: osctl process limit <pid> [name=soft[:hard] ...] [--override] [--json]",
+	}
+	usage := usages[action]
+	positional, flags := splitPositional(args)
+	if len(positional) < 1 {
+		return usage
+	}
+	fs := flag.NewFlagSet("process "+action, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	class := fs.String("class", "", "I/O scheduling class")
+	level := fs.String("level", "", "I/O priority level within the class")
+	override := fs.Bool("override", false, "act on protected processes (requires root)")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(flags); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	guard := cliProcessGuard(*override)
+
+	switch action {
+	case "ionice":
+		if len(positional) > 1 {
+			return usage
+		}
+		if *class == "" && *level != "" {
+			return fmt.Sprintf("--level requires --class\n%s", usage)
+		}
+		return setProcessIOPriority(positional[0], *class, *level, guard, *asJSON)
+	case "affinity":
+		if len(positional) > 2 {
+			return usage
+		}
+		cpus := ""
+		if len(positional) == 2 {
+			cpus = positional[1]
+		}
+		return setProcessAffinity(positional[0], cpus, guard, *asJSON)
+	default:
+		return setProcessLimits(positional[0], positional[1:], guard, *asJSON)
+	}
+}
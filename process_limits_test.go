@@ -0,0 +1,98 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseCPUList(t *testing.T) {
+	for list, want := range map[string][]int{
+		"0":         {0},
+		"0-3,6":     {0, 1, 2, 3, 6},
+		"6, 2-3, 2": {2, 3, 6},
+		"7":         {7},
+	} {
+		got, err := parseCPUList(list, 8)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("parseCPUList(%q) = %v, %v; want %v", list, got, err, want)
+		}
+	}
+	for _, list := range []string{"", ",", "8", "0-8", "3-1", "-1", "a", "1-b"} {
+		if _, err := parseCPUList(list, 8); err == nil {
+			t.Errorf("parseCPUList(%q) succeeded", list)
+		}
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	for want, cpus := range map[string][]int{
+		"0-3,6":   {0, 1, 2, 3, 6},
+		"1":       {1},
+		"0,2,4-5": {0, 2, 4, 5},
+		"":        nil,
+	} {
+		if got := formatCPUList(cpus); got != want {
+			t.Errorf("formatCPUList(%v) = %q, want %q", cpus, got, want)
+		}
+	}
+	cpus, err := parseCPUList("0-3,6,8-9", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatCPUList(cpus); got != "0-3,6,8-9" {
+		t.Errorf("round trip gave %q", got)
+	}
+}
+
+func TestParseIOPriority(t *testing.T) {
+	for _, c := range []struct {
+		class, level string
+		want         int
+		str          string
+	}{
+		{"best-effort", "", 2<<13 | 4, "best-effort:4"},
+		{"realtime", "0", 1 << 13, "realtime:0"},
+		{"best-effort", "7", 2<<13 | 7, "best-effort:7"},
+		{"idle", "", 3 << 13, "idle"},
+		{"none", "", 0, "none"},
+	} {
+		got, err := parseIOPriority(c.class, c.level)
+		if err != nil || got != c.want {
+			t.Errorf("parseIOPriority(%q, %q) = %d, %v; want %d", c.class, c.level, got, err, c.want)
+			continue
+		}
+		if s := ioprioString(got); s != c.str {
+			t.Errorf("ioprioString(%d) = %q, want %q", got, s, c.str)
+		}
+	}
+	for _, c := range [][2]string{{"fast", ""}, {"idle", "3"}, {"none", "0"}, {"realtime", "8"}, {"best-effort", "-1"}, {"best-effort", "x"}} {
+		if _, err := parseIOPriority(c[0], c[1]); err == nil {
+			t.Errorf("parseIOPriority(%q, %q) succeeded", c[0], c[1])
+		}
+	}
+}
+
+func TestParseRlimitChanges(t *testing.T) {
+	changes, err := parseRlimitChanges([]string{"nofile=1024:65535", "CORE=unlimited", "nproc=100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RlimitChange{
+		{Name: "nofile", Resource: unix.RLIMIT_NOFILE, Soft: 1024, Hard: 65535},
+		{Name: "core", Resource: unix.RLIMIT_CORE, Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY},
+		{Name: "nproc", Resource: unix.RLIMIT_NPROC, Soft: 100, Hard: 100},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+	if got := rlimitString(changes[1].Soft); got != "unlimited" {
+		t.Errorf("infinity rendered as %q", got)
+	}
+	for _, spec := range []string{"nofile", "files=10", "nofile=-1", "nofile=10:x", "nofile=100:10", "nofile=unlimited:10"} {
+		if _, err := parseRlimitChanges([]string{spec}); err == nil {
+			t.Errorf("parseRlimitChanges(%q) succeeded", spec)
+		}
+	}
+}
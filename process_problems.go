@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultProblemDuration   = 5 * time.Second
+	problemSampleStep        = time.Second
+	defaultProblemCPU        = 90.0
+	defaultProblemRSSMB      = 4096
+	defaultProblemFDPercent  = 80.0
+	deletedMappingSuffix     = " (deleted)"
+	maxListedDeletedMappings = 5
+)
+
+// ProcessProblemOptions holds the sampling window and runaway thresholds
+type ProcessProblemOptions struct {
+	Duration  time.Duration
+	CPU       float64
+	RSSMB     uint64
+	FDPercent float64
+	JSON      bool
+}
+
+// ProblemProcess is a process flagged by a problem check
+type ProblemProcess struct {
+	PID        int32  `json:"pid"`
+	PPID       int32  `json:"ppid"`
+	ParentName string `json:"parent_name,omitempty"`
+	User       string `json:"user"`
+	Name       string `json:"name"`
+	Command    string `json:"command,omitempty"`
+	Detail     string `json:"detail"`
+}
+
+// ProcessProblemsReport groups problem processes by kind
+type ProcessProblemsReport struct {
+	Duration           string           `json:"duration"`
+	CPUThreshold       float64          `json:"cpu_threshold_percent"`
+	RSSThresholdMB     uint64           `json:"rss_threshold_mb"`
+	FDThresholdPercent float64          `json:"fd_threshold_percent"`
+	Zombies            []ProblemProcess `json:"zombies"`
+	Uninterruptible    []ProblemProcess `json:"uninterruptible"`
+	Runaway            []ProblemProcess `json:"runaway"`
+	DeletedFiles       []ProblemProcess `json:"deleted_files"`
+}
+
+// problemSample tracks a process across the sampling window
+type problemSample struct {
+	proc      *process.Process
+	prev      processCounters
+	minCPU    float64
+	rssHigh   bool
+	maxRSS    uint64
+	fdsHigh   bool
+	maxFDs    int32
+	fdLimit   uint64
+	dSamples  int
+	lastState string
+}
+
+// newProcessProblemOptions validates options shared by the CLI and API
+func newProcessProblemOptions(duration, cpu, rssMB, fdPercent string, asJSON bool) (ProcessProblemOptions, error) {
+	opts := ProcessProblemOptions{Duration: defaultProblemDuration, CPU: defaultProblemCPU, RSSMB: defaultProblemRSSMB,
+		FDPercent: defaultProblemFDPercent, JSON: asJSON}
+	if duration != "" {
+		d, err := parseDurationValue(duration)
+		if err != nil || d < problemSampleStep || d > maxRateInterval {
+			return opts, fmt.Errorf("invalid duration %q (must be between %s and %s)", duration, problemSampleStep, maxRateInterval)
+		}
+		opts.Duration = d
+	}
+	if cpu != "" {
+		v, err := strconv.ParseFloat(cpu, 64)
+		if err != nil || v <= 0 {
+			return opts, fmt.Errorf("invalid CPU threshold %q (percent of one core)", cpu)
+		}
+		opts.CPU = v
+	}
+	if rssMB != "" {
+		v, err := strconv.ParseUint(rssMB, 10, 64)
+		if err != nil || v == 0 {
+			return opts, fmt.Errorf("invalid RSS threshold %q (MB)", rssMB)
+		}
+		opts.RSSMB = v
+	}
+	if fdPercent != "" {
+		v, err := strconv.ParseFloat(fdPercent, 64)
+		if err != nil || v <= 0 || v > 100 {
+			return opts, fmt.Errorf("invalid FD threshold %q (percent of the open files limit)", fdPercent)
+		}
+		opts.FDPercent = v
+	}
+	return opts, nil
+}
+
+// newProblemProcess reads the identifying fields of a process and its parent
+func newProblemProcess(p *process.Process, detail string) ProblemProcess {
+	t := readProcessTarget(p)
+	pp := ProblemProcess{PID: t.PID, PPID: t.PPID, User: t.User, Name: t.Name, Command: t.Command, Detail: detail}
+	if parent, err := process.NewProcess(t.PPID); err == nil {
+		pp.ParentName, _ = parent.Name()
+	}
+	return pp
+}
+
+// readWaitChannel returns the kernel function a process is blocked in
+func readWaitChannel(pid int32) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "wchan"))
+	if err != nil || string(data) == "0" {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// deletedMappings returns a deleted executable and deleted libraries still
+// mapped by a process, which is how a process that needs a restart after a
+// package update shows up
+func deletedMappings(pid int32) (string, []string) {
+	dir := filepath.Join("/proc", strconv.Itoa(int(pid)))
+	var exe string
+	if target, err := os.Readlink(filepath.Join(dir, "exe")); err == nil && strings.HasSuffix(target, deletedMappingSuffix) {
+		exe = strings.TrimSuffix(target, deletedMappingSuffix)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "maps"))
+	if err != nil {
+		return exe, nil
+	}
+	defer f.Close()
+	return exe, parseDeletedMappings(f, exe)
+}
+
+// parseDeletedMappings returns the deleted files mapped executable in a maps
+// file, other than the executable itself and shared memory
+func parseDeletedMappings(r io.Reader, exe string) []string {
+	seen := make(map[string]bool)
+	var libs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasSuffix(line, deletedMappingSuffix) {
+			continue
+		}
+		// address perms offset dev inode path
+		fields := strings.SplitN(line, " ", 6)
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") {
+			continue
+		}
+		path := strings.TrimSuffix(strings.TrimSpace(fields[5]), deletedMappingSuffix)
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "/dev/shm/") || strings.HasPrefix(path, "/memfd:") ||
+			path == exe || seen[path] {
+			continue
+		}
+		seen[path] = true
+		libs = append(libs, path)
+	}
+	sort.Strings(libs)
+	return libs
+}
+
+// openFileLimit returns the soft RLIMIT_NOFILE of a process, 0 if unknown
+func openFileLimit(pid int32) uint64 {
+	var lim unix.Rlimit
+	if err := unix.Prlimit(int(pid), unix.RLIMIT_NOFILE, nil, &lim); err != nil || lim.Cur == unix.RLIM_INFINITY {
+		return 0
+	}
+	return lim.Cur
+}
+
+// sampleProblemProcesses samples all processes every second over the window
+// and keeps, per process, the lowest CPU rate and whether RSS and open FDs
+// stayed above the thresholds in every sample
+func sampleProblemProcesses(opts ProcessProblemOptions) (map[int32]*problemSample, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	samples := make(map[int32]*problemSample, len(procs))
+	for _, p := range procs {
+		c, ok := readProcessCounters(p)
+		if !ok {
+			continue
+		}
+		samples[p.Pid] = &problemSample{proc: p, prev: c, minCPU: math.Inf(1), rssHigh: true, fdsHigh: true,
+			fdLimit: openFileLimit(p.Pid)}
+	}
+
+	rssLimit := opts.RSSMB * 1024 * 1024
+	steps := int(opts.Duration / problemSampleStep)
+	for i := 0; i < steps; i++ {
+		time.Sleep(problemSampleStep)
+		for pid, s := range samples {
+			c, ok := readProcessCounters(s.proc)
+			if !ok || !c.sameProcess(s.prev) {
+				delete(samples, pid)
+				continue
+			}
+			s.minCPU = math.Min(s.minCPU, c.cpuPercentSince(s.prev))
+			s.prev = c
+
+			if mi, err := s.proc.MemoryInfo(); err == nil && mi != nil {
+				s.maxRSS = max(s.maxRSS, mi.RSS)
+				s.rssHigh = s.rssHigh && mi.RSS >= rssLimit
+			}
+			if s.fdLimit > 0 && s.fdsHigh {
+				fds, err := s.proc.NumFDs()
+				s.maxFDs = max(s.maxFDs, fds)
+				s.fdsHigh = err == nil && float64(fds) >= opts.FDPercent/100*float64(s.fdLimit)
+			}
+			if status, err := s.proc.Status(); err == nil {
+				s.lastState = status
+				if status == "D" {
+					s.dSamples++
+				}
+			}
+		}
+	}
+	return samples, nil
+}
+
+// collectProcessProblems finds zombies, uninterruptible and runaway processes
+// and processes running deleted binaries
+func collectProcessProblems(opts ProcessProblemOptions) (ProcessProblemsReport, error) {
+	report := ProcessProblemsReport{Duration: opts.Duration.String(), CPUThreshold: opts.CPU,
+		RSSThresholdMB: opts.RSSMB, FDThresholdPercent: opts.FDPercent,
+		Zombies: []ProblemProcess{}, Uninterruptible: []ProblemProcess{}, Runaway: []ProblemProcess{}, DeletedFiles: []ProblemProcess{}}
+	samples, err := sampleProblemProcesses(opts)
+	if err != nil {
+		return report, err
+	}
+	steps := int(opts.Duration / problemSampleStep)
+
+	pids := make([]int32, 0, len(samples))
+	for pid := range samples {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	for _, pid := range pids {
+		s := samples[pid]
+		switch s.lastState {
+		case "Z":
+			detail := "zombie, not reaped by its parent"
+			if ms, err := s.proc.CreateTime(); err == nil {
+				detail = fmt.Sprintf("zombie for %s, not reaped by its parent", time.Since(time.UnixMilli(ms)).Round(time.Second))
+			}
+			report.Zombies = append(report.Zombies, newProblemProcess(s.proc, detail))
+			// Zombies hold no resources and mappings
+			continue
+		case "D":
+			detail := fmt.Sprintf("in D state in %d/%d samples", s.dSamples, steps)
+			if wchan := readWaitChannel(pid); wchan != "" {
+				detail = fmt.Sprintf("waiting in %s, %s", wchan, detail)
+			}
+			report.Uninterruptible = append(report.Uninterruptible, newProblemProcess(s.proc, detail))
+		}
+
+		var reasons []string
+		if s.minCPU >= opts.CPU {
+			reasons = append(reasons, fmt.Sprintf("CPU >= %.1f%% for %s", s.minCPU, opts.Duration))
+		}
+		if s.rssHigh && s.maxRSS > 0 {
+			reasons = append(reasons, fmt.Sprintf("RSS %s", formatBytes(s.maxRSS)))
+		}
+		if s.fdsHigh && s.fdLimit > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d of %d open files (%.0f%%)", s.maxFDs, s.fdLimit, 100*float64(s.maxFDs)/float64(s.fdLimit)))
+		}
+		if len(reasons) > 0 {
+			report.Runaway = append(report.Runaway, newProblemProcess(s.proc, strings.Join(reasons, ", ")))
+		}
+
+		exe, libs := deletedMappings(pid)
+		if exe != "" || len(libs) > 0 {
+			var parts []string
+			if exe != "" {
+				parts = append(parts, "executable "+exe)
+			}
+			if len(libs) > maxListedDeletedMappings {
+				libs = append(libs[:maxListedDeletedMappings], fmt.Sprintf("and %d more", len(libs)-maxListedDeletedMappings))
+			}
+			if len(libs) > 0 {
+				parts = append(parts, "libraries "+strings.Join(libs, ", "))
+			}
+			report.DeletedFiles = append(report.DeletedFiles, newProblemProcess(s.proc, "deleted "+strings.Join(parts, "; ")))
+		}
+	}
+	return report, nil
+}
+
+// writeProblemSection renders one group of problem processes
+func writeProblemSection(output *strings.Builder, title, hint string, procs []ProblemProcess) {
+	output.WriteString(fmt.Sprintf("%s (%d):\n", title, len(procs)))
+	if len(procs) == 0 {
+		output.WriteString("  none\n\n")
+		return
+	}
+	for _, p := range procs {
+		output.WriteString(fmt.Sprintf("  %d %s (user %s, parent %d %s): %s\n", p.PID, p.Name, p.User, p.PPID, p.ParentName, p.Detail))
+	}
+	if hint != "" {
+		output.WriteString("  " + hint + "\n")
+	}
+	output.WriteString("\n")
+}
+
+// getProcessProblems reports problem processes
+func getProcessProblems(opts ProcessProblemOptions) string {
+	report, err := collectProcessProblems(opts)
+	if err != nil {
+		return fmt.Sprintf("Failed to check processes. Error: %v", err)
+	}
+	if opts.JSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode process problems. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Process problems (sampled over %s):\n\n", report.Duration))
+	writeProblemSection(&output, "Zombies", "Zombies are reaped when their parent calls wait() or exits.", report.Zombies)
+	writeProblemSection(&output, "Uninterruptible (D state)", "", report.Uninterruptible)
+	writeProblemSection(&output, fmt.Sprintf("Runaway (CPU >= %.0f%%, RSS >= %d MB or open files >= %.0f%% of limit)",
+		report.CPUThreshold, report.RSSThresholdMB, report.FDThresholdPercent), "", report.Runaway)
+	writeProblemSection(&output, "Running deleted files", "Restart these processes to load the updated files.", report.DeletedFiles)
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+// runProcessProblems parses "osctl process problems" arguments
+func runProcessProblems(args []string) string {
+	usage := "Usage: osctl process problems [--duration 5s] [--cpu 90] [--rss-mb 4096] [--fds 80] [--json]"
+	fs := flag.NewFlagSet("process problems", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	duration := fs.String("duration", "", "sampling window for sustained thresholds")
+	cpu := fs.String("cpu", "", "CPU threshold in percent of one core")
+	rssMB := fs.String("rss-mb", "", "RSS threshold in MB")
+	fds := fs.String("fds", "", "open files threshold in percent of the process limit")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newProcessProblemOptions(*duration, *cpu, *rssMB, *fds, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getProcessProblems(opts)
+}
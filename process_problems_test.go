@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const deletedMapsTestData = `55d0c0a00000-55d0c0a21000 r--p 00000000 fd:01 1835013                    /usr/sbin/nginx (deleted)
+55d0c0a21000-55d0c0b00000 r-xp 00021000 fd:01 1835013                    /usr/sbin/nginx (deleted)
+7f2a10000000-7f2a10021000 rw-p 00000000 00:00 0 
+7f2a1c200000-7f2a1c228000 r--p 00000000 fd:01 1837060                    /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)
+7f2a1c228000-7f2a1c3bd000 r-xp 00028000 fd:01 1837060                    /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)
+7f2a1c400000-7f2a1c428000 r-xp 00028000 fd:01 1837061                    /usr/lib/x86_64-linux-gnu/libc.so.6 (deleted)
+7f2a1c500000-7f2a1c528000 r-xp 00000000 fd:01 1837062                    /usr/lib/x86_64-linux-gnu/libz.so.1
+7f2a1c600000-7f2a1c628000 r--p 00000000 fd:01 1837063                    /usr/lib/x86_64-linux-gnu/libdata.so (deleted)
+7f2a1c700000-7f2a1c728000 r-xs 00000000 00:01 2048                       /dev/shm/jit (deleted)
+7f2a1c800000-7f2a1c828000 r-xp 00000000 00:01 2049                       /memfd:jit (deleted)
+7f2a1c900000-7f2a1c928000 r-xp 00028000 fd:01 1837064                    /opt/app/lib with space.so (deleted)
+`
+
+func TestParseDeletedMappings(t *testing.T) {
+	libs := parseDeletedMappings(strings.NewReader(deletedMapsTestData), "/usr/sbin/nginx")
+	want := []string{
+		"/opt/app/lib with space.so",
+		"/usr/lib/x86_64-linux-gnu/libc.so.6",
+		"/usr/lib/x86_64-linux-gnu/libssl.so.3",
+	}
+	if !slices.Equal(libs, want) {
+		t.Errorf("got %q, want %q", libs, want)
+	}
+
+	// Unless it is the deleted executable, a mapped binary is reported like a library
+	if libs := parseDeletedMappings(strings.NewReader(deletedMapsTestData), ""); !slices.Contains(libs, "/usr/sbin/nginx") {
+		t.Errorf("got %q, want the deleted binary included", libs)
+	}
+	if libs := parseDeletedMappings(strings.NewReader(""), ""); len(libs) != 0 {
+		t.Errorf("an empty maps file gave %q", libs)
+	}
+}
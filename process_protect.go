@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// defaultProtectedNames are process names that are never signalled or reniced
+// without an override
+const defaultProtectedNames = "sshd,systemd-journald"
+
+// pfKthread is the PF_KTHREAD flag in /proc/<pid>/stat
+const pfKthread = 0x00200000
+
+// ProcessGuard describes who acts on a process and whether the deny list is
+// overridden. Overrides only take effect with admin scope.
+type ProcessGuard struct {
+	Source   string
+	User     string
+	Admin    bool
+	Override bool
+}
+
+// ProtectionConfig is the deny list for process actions. PID 1, kernel
+// threads and osctl itself are always protected.
+type ProtectionConfig struct {
+	Names []string
+	Users []string
+	Units []string
+}
+
+// getProtectionConfig reads the deny list from OSCTL_PROTECTED_NAMES
+// (default sshd,systemd-journald), OSCTL_PROTECTED_USERS and OSCTL_PROTECTED_UNITS.
+// Setting OSCTL_PROTECTED_NAMES to "none" clears the name list.
+func getProtectionConfig() ProtectionConfig {
+	names := os.Getenv("OSCTL_PROTECTED_NAMES")
+	if names == "" {
+		names = defaultProtectedNames
+	} else if names == "none" {
+		names = ""
+	}
+	return ProtectionConfig{
+		Names: splitPatterns(names),
+		Users: splitPatterns(os.Getenv("OSCTL_PROTECTED_USERS")),
+		Units: splitPatterns(os.Getenv("OSCTL_PROTECTED_UNITS")),
+	}
+}
+
+// cliProcessGuard returns the guard for local CLI use. Admin scope means root.
+func cliProcessGuard(override bool) ProcessGuard {
+	name := os.Getenv("SUDO_USER")
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+	}
+	return ProcessGuard{Source: "cli", User: name, Admin: os.Geteuid() == 0, Override: override}
+}
+
+// hasOverrideFlag reports whether --override is among the CLI arguments
+func hasOverrideFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--override" {
+			return true
+		}
+	}
+	return false
+}
+
+// apiProcessGuard returns the guard for an authenticated API request
+func apiProcessGuard(r *http.Request) ProcessGuard {
+	id := requestIdentity(r)
+	return ProcessGuard{Source: "api", User: id.User, Admin: id.Admin, Override: r.URL.Query().Get("override") == "true"}
+}
+
+// isKernelThread reports whether pid is a kernel thread
+func isKernelThread(pid int32) bool {
+	if pid == 2 {
+		return true
+	}
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "stat"))
+	if err != nil {
+		return false
+	}
+	// Fields after the parenthesized command: state ppid pgrp session tty_nr tpgid flags
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 7 {
+		return false
+	}
+	flags, err := strconv.ParseUint(fields[6], 10, 64)
+	return err == nil && flags&pfKthread != 0
+}
+
+// protectedReason returns why a process is on the deny list, or an empty string
+func protectedReason(pid int32, cfg ProtectionConfig) string {
+	switch {
+	case pid == 1:
+		return "PID 1"
+	case pid == int32(os.Getpid()):
+		return "osctl itself"
+	case isKernelThread(pid):
+		return "kernel thread"
+	}
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return ""
+	}
+	if name, err := p.Name(); err == nil && matchUnitPatterns(cfg.Names, name) {
+		return "protected process " + name
+	}
+	if len(cfg.Users) > 0 {
+		if username, err := p.Username(); err == nil && matchUnitPatterns(cfg.Users, username) {
+			return "protected user " + username
+		}
+	}
+	if len(cfg.Units) > 0 {
+		if unit := processUnit(pid); unit != "" && matchUnitPatterns(cfg.Units, unit) {
+			return "protected unit " + unit
+		}
+	}
+	return ""
+}
+
+// checkProcessProtection refuses actions on protected processes unless the
+// guard overrides the deny list with admin scope. Overrides, allowed or not,
+// are written to the audit log.
+func checkProcessProtection(pid int32, action string, guard ProcessGuard) error {
+	reason := protectedReason(pid, getProtectionConfig())
+	if reason == "" {
+		return nil
+	}
+	if !guard.Override {
+		return fmt.Errorf("process %d is protected (%s); an admin override is required", pid, reason)
+	}
+	event := AuditEvent{Source: guard.Source, User: guard.User, Action: "process-" + action + "-override",
+		Target: strconv.Itoa(int(pid)), Result: "allowed", Detail: reason}
+	if !guard.Admin {
+		event.Result = "denied"
+		writeAuditEvent(event)
+		return fmt.Errorf("overriding protection of process %d (%s) requires admin scope", pid, reason)
+	}
+	writeAuditEvent(event)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSleep starts a child process named "sleep" that is killed when the test ends
+func startSleep(t *testing.T) int32 {
+	t.Helper()
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep not available:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return int32(cmd.Process.Pid)
+}
+
+func TestProtectedReason(t *testing.T) {
+	child := startSleep(t)
+	for _, c := range []struct {
+		name  string
+		pid   int32
+		names string
+		want  string
+	}{
+		{"PID 1", 1, "none", "PID 1"},
+		{"osctl itself", int32(os.Getpid()), "none", "osctl itself"},
+		{"protected name", child, "cron,sleep", "protected process sleep"},
+		{"protected glob", child, "sl*p", "protected process sleep"},
+		{"default list", child, "", ""},
+		{"none clears the list", child, "none", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("OSCTL_PROTECTED_NAMES", c.names)
+			if got := protectedReason(c.pid, getProtectionConfig()); got != c.want {
+				t.Errorf("protectedReason(%d) = %q, want %q", c.pid, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckProcessProtection(t *testing.T) {
+	child := startSleep(t)
+	target := strconv.Itoa(int(child))
+	t.Setenv("OSCTL_PROTECTED_NAMES", "sleep")
+	for _, c := range []struct {
+		name    string
+		guard   ProcessGuard
+		allowed bool
+		audit   string
+	}{
+		{"no override", ProcessGuard{Source: "api", User: "ops", Admin: true}, false, ""},
+		{"override without admin", ProcessGuard{Source: "api", User: "ops", Override: true}, false, "denied"},
+		{"override with admin", ProcessGuard{Source: "api", User: "admin", Admin: true, Override: true}, true, "allowed"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("OSCTL_AUDIT_LOG", filepath.Join(t.TempDir(), "audit.log"))
+			err := checkProcessProtection(child, "signal", c.guard)
+			if (err == nil) != c.allowed {
+				t.Fatalf("checkProcessProtection = %v, want allowed %v", err, c.allowed)
+			}
+			if err != nil && !strings.Contains(err.Error(), "protected process sleep") {
+				t.Errorf("error %q does not name the reason", err)
+			}
+
+			events, err := readAuditEvents(time.Time{}, "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.audit == "" {
+				if len(events) != 0 {
+					t.Errorf("got audit events %+v without an override", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d audit events, want 1: %+v", len(events), events)
+			}
+			e := events[0]
+			if e.Result != c.audit || e.Action != "process-signal-override" || e.Target != target || e.User != c.guard.User || e.Source != "api" {
+				t.Errorf("audit event = %+v, want result %s", e, c.audit)
+			}
+		})
+	}
+
+	// Unprotected processes are allowed without an audit trail
+	t.Setenv("OSCTL_PROTECTED_NAMES", "none")
+	t.Setenv("OSCTL_AUDIT_LOG", filepath.Join(t.TempDir(), "audit.log"))
+	if err := checkProcessProtection(child, "signal", ProcessGuard{Source: "cli"}); err != nil {
+		t.Errorf("an unprotected process was refused: %v", err)
+	}
+	if events, _ := readAuditEvents(time.Time{}, "", 0); len(events) != 0 {
+		t.Errorf("got audit events %+v for an unprotected process", events)
+	}
+}
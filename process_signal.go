@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+const (
+	defaultGracefulTimeout = 10 * time.Second
+	maxGracefulTimeout     = 5 * time.Minute
+	gracefulPollInterval   = 200 * time.Millisecond
+)
+
+// signalNames maps signal names, without the SIG prefix, to signals
+var signalNames = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT, "KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1, "USR2": syscall.SIGUSR2, "TERM": syscall.SIGTERM, "ALRM": syscall.SIGALRM,
+	"CONT": syscall.SIGCONT, "STOP": syscall.SIGSTOP, "TSTP": syscall.SIGTSTP, "WINCH": syscall.SIGWINCH,
+	"ABRT": syscall.SIGABRT, "PIPE": syscall.SIGPIPE, "TTIN": syscall.SIGTTIN, "TTOU": syscall.SIGTTOU,
+}
+
+// SignalOptions controls which processes are signalled and how
+type SignalOptions struct {
+	Signal   syscall.Signal
+	Tree     bool
+	User     string
+	Graceful bool
+	Timeout  time.Duration
+}
+
+// ProcessTarget is a process matched for signalling
+type ProcessTarget struct {
+	PID     int32  `json:"pid"`
+	PPID    int32  `json:"ppid"`
+	User    string `json:"user"`
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	// StartTicks identifies the process across PID reuse
+	StartTicks uint64 `json:"-"`
+}
+
+// SignalResult is the outcome of signalling one process
+type SignalResult struct {
+	ProcessTarget
+	Signal string `json:"signal"`
+	Sent   bool   `json:"sent"`
+	Exited bool   `json:"exited,omitempty"`
+	Killed bool   `json:"killed,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// parseSignal accepts a signal name with or without the SIG prefix, or a number
+func parseSignal(value string) (syscall.Signal, error) {
+	if value == "" {
+		return syscall.SIGTERM, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(value), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", value)
+}
+
+// signalName renders a signal as SIGNAME
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// newSignalOptions validates signal options shared by the CLI and API
+func newSignalOptions(signal, user, timeout string, tree, graceful bool) (SignalOptions, error) {
+	opts := SignalOptions{Tree: tree, User: user, Graceful: graceful, Timeout: defaultGracefulTimeout}
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return opts, err
+	}
+	opts.Signal = sig
+	if timeout != "" {
+		d, err := parseDurationValue(timeout)
+		if err != nil || d <= 0 || d > maxGracefulTimeout {
+			return opts, fmt.Errorf("invalid timeout %q (must be between 0 and %s)", timeout, maxGracefulTimeout)
+		}
+		opts.Timeout = d
+	}
+	return opts, nil
+}
+
+// readProcessTarget reads the identifying fields of a process
+func readProcessTarget(p *process.Process) ProcessTarget {
+	t := ProcessTarget{PID: p.Pid}
+	t.PPID, _ = p.Ppid()
+	t.User, _ = p.Username()
+	t.Name, _ = p.Name()
+	t.Command, _ = p.Cmdline()
+	t.StartTicks, _ = processStartTicks(p.Pid)
+	return t
+}
+
+// targetReplaced reports whether the process matched as t has exited and its
+// PID may have been reused, so that it must not be signalled
+func targetReplaced(t ProcessTarget) bool {
+	if t.StartTicks == 0 {
+		return false
+	}
+	ticks, err := processStartTicks(t.PID)
+	return err != nil || ticks != t.StartTicks
+}
+
+// matchSignalTargets resolves a PID, exact process name or glob pattern to
+// processes, optionally adding their descendants and filtering by user.
+// osctl itself is never matched by name.
+func matchSignalTargets(target string, opts SignalOptions) ([]ProcessTarget, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ProcessTarget, 0, len(procs))
+	for _, p := range procs {
+		list = append(list, readProcessTarget(p))
+	}
+	return selectSignalTargets(list, target, opts)
+}
+
+// selectSignalTargets picks the targets of matchSignalTargets from a process list
+func selectSignalTargets(procs []ProcessTarget, target string, opts SignalOptions) ([]ProcessTarget, error) {
+	all := make(map[int32]ProcessTarget, len(procs))
+	children := make(map[int32][]int32)
+	for _, t := range procs {
+		all[t.PID] = t
+		children[t.PPID] = append(children[t.PPID], t.PID)
+	}
+
+	var roots []int32
+	if pid, err := strconv.Atoi(target); err == nil {
+		if _, ok := all[int32(pid)]; !ok {
+			return nil, fmt.Errorf("process %d not found", pid)
+		}
+		roots = []int32{int32(pid)}
+	} else {
+		self := int32(os.Getpid())
+		glob := strings.ContainsAny(target, "*?[")
+		for pid, t := range all {
+			if pid == self {
+				continue
+			}
+			matched := t.Name == target
+			if glob {
+				matched, _ = filepath.Match(target, t.Name)
+			}
+			if matched {
+				roots = append(roots, pid)
+			}
+		}
+		sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+	}
+
+	// Parents come before their descendants so supervisors cannot respawn children
+	var targets []ProcessTarget
+	seen := make(map[int32]bool)
+	queue := roots
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		t := all[pid]
+		if opts.User == "" || t.User == opts.User {
+			targets = append(targets, t)
+		}
+		if opts.Tree {
+			queue = append(queue, children[pid]...)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no processes match %q", target)
+	}
+	return targets, nil
+}
+
+// processAlive reports whether a process still exists and is not a zombie
+func processAlive(pid int32) bool {
+	if err := syscall.Kill(int(pid), 0); err != nil {
+		return false
+	}
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return false
+	}
+	status, err := p.Status()
+	return err == nil && status != "Z"
+}
+
+// signalProcesses sends the signal to every target. In graceful mode it waits
+// up to the timeout for the processes to exit and then sends SIGKILL.
+func signalProcesses(targets []ProcessTarget, opts SignalOptions) []SignalResult {
+	results := make([]SignalResult, len(targets))
+	for i, t := range targets {
+		results[i] = SignalResult{ProcessTarget: t, Signal: signalName(opts.Signal)}
+		if targetReplaced(t) {
+			results[i].Error = "process exited since it was matched"
+			continue
+		}
+		if err := syscall.Kill(int(t.PID), opts.Signal); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Sent = true
+	}
+	if !opts.Graceful {
+		return results
+	}
+
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		remaining := 0
+		for i := range results {
+			if !results[i].Sent || results[i].Exited {
+				continue
+			}
+			if processAlive(results[i].PID) && !targetReplaced(results[i].ProcessTarget) {
+				remaining++
+			} else {
+				results[i].Exited = true
+			}
+		}
+		if remaining == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(gracefulPollInterval)
+	}
+	for i := range results {
+		if !results[i].Sent || results[i].Exited {
+			continue
+		}
+		if targetReplaced(results[i].ProcessTarget) {
+			results[i].Exited = true
+			continue
+		}
+		if err := syscall.Kill(int(results[i].PID), syscall.SIGKILL); err != nil {
+			results[i].Error = fmt.Sprintf("SIGKILL: %v", err)
+			continue
+		}
+		results[i].Killed = true
+	}
+	return results
+}
+
+// formatSignalTargets lists matched processes before acting
+func formatSignalTargets(targets []ProcessTarget, opts SignalOptions) string {
+	var output strings.Builder
+	action := signalName(opts.Signal)
+	if opts.Graceful {
+		action += fmt.Sprintf(", then SIGKILL after %s", opts.Timeout)
+	}
+	output.WriteString(fmt.Sprintf("Matched %d processes (%s):\n", len(targets), action))
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PID\tPPID\tUSER\tNAME\tCOMMAND")
+	for _, t := range targets {
+		command := t.Command
+		if len(command) > 80 {
+			command = command[:77] + "..."
+		}
+		fmt.Fprintf(w, "  %d\t%d\t%s\t%s\t%s\n", t.PID, t.PPID, t.User, t.Name, command)
+	}
+	w.Flush()
+	return output.String()
+}
+
+// formatSignalResults renders the outcome per process
+func formatSignalResults(results []SignalResult) string {
+	var output strings.Builder
+	for _, r := range results {
+		output.WriteString(fmt.Sprintf("  %d (%s): ", r.PID, r.Name))
+		switch {
+		case !r.Sent:
+			output.WriteString(fmt.Sprintf("FAILED to send %s: %s", r.Signal, r.Error))
+		case r.Killed:
+			output.WriteString(fmt.Sprintf("%s sent, killed with SIGKILL after timeout", r.Signal))
+		case r.Exited:
+			output.WriteString(fmt.Sprintf("%s sent, exited", r.Signal))
+		case r.Error != "":
+			output.WriteString(fmt.Sprintf("%s sent, %s", r.Signal, r.Error))
+		default:
+			output.WriteString(fmt.Sprintf("%s sent", r.Signal))
+		}
+		output.WriteString("\n")
+	}
+	return output.String()
+}
+
+// splitProtectedTargets drops processes on the deny list unless the guard
+// overrides it with admin scope, describing each dropped process
+func splitProtectedTargets(targets []ProcessTarget, guard ProcessGuard) ([]ProcessTarget, []string) {
+	cfg := getProtectionConfig()
+	var allowed []ProcessTarget
+	var skipped []string
+	for _, t := range targets {
+		if reason := protectedReason(t.PID, cfg); reason != "" && !(guard.Override && guard.Admin) {
+			skipped = append(skipped, fmt.Sprintf("%d (%s): %s", t.PID, t.Name, reason))
+			continue
+		}
+		allowed = append(allowed, t)
+	}
+	return allowed, skipped
+}
+
+// formatSkippedTargets lists protected processes that will not be signalled
+func formatSkippedTargets(skipped []string) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	return "Skipped protected processes (override requires admin scope):\n  " + strings.Join(skipped, "\n  ") + "\n"
+}
+
+// matchAllowedTargets matches target and removes protected processes
+func matchAllowedTargets(target string, opts SignalOptions, guard ProcessGuard) ([]ProcessTarget, []string, error) {
+	targets, err := matchSignalTargets(target, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowed, skipped := splitProtectedTargets(targets, guard)
+	if len(allowed) == 0 {
+		return nil, skipped, fmt.Errorf("all matching processes are protected: %s", strings.Join(skipped, "; "))
+	}
+	return allowed, skipped, nil
+}
+
+// sendSignal checks protection once more, auditing overrides, and signals the targets
+func sendSignal(targets []ProcessTarget, opts SignalOptions, guard ProcessGuard) []SignalResult {
+	var allowed []ProcessTarget
+	var refused []SignalResult
+	for _, t := range targets {
+		if err := checkProcessProtection(t.PID, "signal", guard); err != nil {
+			refused = append(refused, SignalResult{ProcessTarget: t, Signal: signalName(opts.Signal), Error: err.Error()})
+			continue
+		}
+		allowed = append(allowed, t)
+	}
+	return append(signalProcesses(allowed, opts), refused...)
+}
+
+// processSignal signals the processes matching target. Without confirmation it
+// only lists what would be signalled.
+func processSignal(target string, opts SignalOptions, guard ProcessGuard, confirmed, asJSON bool) string {
+	targets, skipped, err := matchAllowedTargets(target, opts, guard)
+	if err != nil {
+		return fmt.Sprintf("Failed to signal %s. Error: %v", target, err)
+	}
+	if !confirmed {
+		if asJSON {
+			data, _ := json.MarshalIndent(targets, "", "  ")
+			return string(data)
+		}
+		return formatSignalTargets(targets, opts) + formatSkippedTargets(skipped) + "\nNothing sent. Confirm with --yes (API: yes=true)."
+	}
+	results := sendSignal(targets, opts, guard)
+	if asJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode results. Error: %v", err)
+		}
+		return string(data)
+	}
+	return formatSignalTargets(targets, opts) + formatSkippedTargets(skipped) + "\n" + formatSignalResults(results)
+}
+
+// runProcessSignal parses "osctl process signal" arguments and asks for confirmation on the terminal
+func runProcessSignal(args []string) string {
+	usage := "Usage: osctl process signal <pid|name|pattern> [--signal TERM] [--tree] [--user NAME] [--graceful] [--timeout 10s] [--yes] [--json] [--override]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+	target := args[0]
+	fs := flag.NewFlagSet("process signal", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	signal := fs.String("signal", "", "signal name or number")
+	tree := fs.Bool("tree", false, "also signal descendants")
+	user := fs.String("user", "", "only processes of this user")
+	graceful := fs.Bool("graceful", false, "send SIGKILL to processes still running after the timeout")
+	timeout := fs.String("timeout", "", "graceful timeout")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	asJSON := fs.Bool("json", false, "output JSON")
+	override := fs.Bool("override", false, "include protected processes (requires root)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newSignalOptions(*signal, *user, *timeout, *tree, *graceful)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	guard := cliProcessGuard(*override)
+	if *yes || *asJSON {
+		return processSignal(target, opts, guard, *yes, *asJSON)
+	}
+
+	targets, skipped, err := matchAllowedTargets(target, opts, guard)
+	if err != nil {
+		return fmt.Sprintf("Failed to signal %s. Error: %v", target, err)
+	}
+	fmt.Print(formatSignalTargets(targets, opts) + formatSkippedTargets(skipped))
+	fmt.Printf("Send %s to %d processes? [y/N] ", signalName(opts.Signal), len(targets))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return "Aborted, nothing sent."
+	}
+	return formatSignalResults(sendSignal(targets, opts, guard))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+func signalTestProcesses() []ProcessTarget {
+	return []ProcessTarget{
+		{PID: 1, PPID: 0, User: "root", Name: "systemd"},
+		{PID: 40, PPID: 1, User: "root", Name: "nginx"},
+		{PID: 41, PPID: 40, User: "www-data", Name: "nginx"},
+		{PID: 42, PPID: 40, User: "www-data", Name: "nginx"},
+		{PID: 50, PPID: 41, User: "www-data", Name: "php"},
+		{PID: 30, PPID: 1, User: "root", Name: "nginx-exporter"},
+	}
+}
+
+func targetPIDs(targets []ProcessTarget) []int32 {
+	var pids []int32
+	for _, t := range targets {
+		pids = append(pids, t.PID)
+	}
+	return pids
+}
+
+func TestSelectSignalTargets(t *testing.T) {
+	for _, c := range []struct {
+		target string
+		opts   SignalOptions
+		want   []int32
+	}{
+		// Name matches are sorted by PID
+		{"nginx", SignalOptions{}, []int32{40, 41, 42}},
+		{"nginx*", SignalOptions{}, []int32{30, 40, 41, 42}},
+		// Parents come before descendants and nothing is listed twice
+		{"40", SignalOptions{Tree: true}, []int32{40, 41, 42, 50}},
+		{"nginx", SignalOptions{Tree: true}, []int32{40, 41, 42, 50}},
+		{"40", SignalOptions{}, []int32{40}},
+		// The user filter applies to descendants too
+		{"40", SignalOptions{Tree: true, User: "www-data"}, []int32{41, 42, 50}},
+		{"nginx", SignalOptions{User: "root"}, []int32{40}},
+	} {
+		got, err := selectSignalTargets(signalTestProcesses(), c.target, c.opts)
+		if err != nil || !slices.Equal(targetPIDs(got), c.want) {
+			t.Errorf("selectSignalTargets(%q, %+v) = %v, %v; want %v", c.target, c.opts, targetPIDs(got), err, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		target string
+		opts   SignalOptions
+	}{
+		{"4242", SignalOptions{}},
+		{"apache", SignalOptions{}},
+		{"php", SignalOptions{User: "root"}},
+	} {
+		if got, err := selectSignalTargets(signalTestProcesses(), c.target, c.opts); err == nil {
+			t.Errorf("selectSignalTargets(%q, %+v) = %v, want an error", c.target, c.opts, targetPIDs(got))
+		}
+	}
+}
+
+func TestSelectSignalTargetsSkipsSelf(t *testing.T) {
+	procs := append(signalTestProcesses(), ProcessTarget{PID: int32(os.Getpid()), PPID: 1, Name: "nginx"})
+	got, err := selectSignalTargets(procs, "nginx", SignalOptions{})
+	if err != nil || slices.Contains(targetPIDs(got), int32(os.Getpid())) {
+		t.Errorf("got %v, %v; osctl itself was matched by name", targetPIDs(got), err)
+	}
+}
+
+func TestSignalProcessesSkipsReplacedTargets(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep not available:", err)
+	}
+	defer cmd.Process.Kill()
+	pid := int32(cmd.Process.Pid)
+	ticks, err := processStartTicks(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A different start time stands for a PID reused after the match
+	results := signalProcesses([]ProcessTarget{{PID: pid, StartTicks: ticks + 1}}, SignalOptions{Signal: syscall.SIGTERM})
+	if len(results) != 1 || results[0].Sent || results[0].Error == "" {
+		t.Fatalf("a replaced process was signalled: %+v", results)
+	}
+	if !processAlive(pid) {
+		t.Fatal("the process was signalled")
+	}
+
+	results = signalProcesses([]ProcessTarget{{PID: pid, StartTicks: ticks}}, SignalOptions{Signal: syscall.SIGTERM})
+	if len(results) != 1 || !results[0].Sent {
+		t.Errorf("the matched process was not signalled: %+v", results)
+	}
+	cmd.Wait()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ProcessTreeOptions selects the part of the process tree to show
+type ProcessTreeOptions struct {
+	Root     int32
+	User     string
+	Unit     string
+	Interval time.Duration
+	JSON     bool
+}
+
+// ProcessNode is a process with its own and cumulative (self plus descendants) usage
+type ProcessNode struct {
+	PID             int32          `json:"pid"`
+	PPID            int32          `json:"ppid"`
+	User            string         `json:"user"`
+	Name            string         `json:"name"`
+	Command         string         `json:"command,omitempty"`
+	Unit            string         `json:"unit,omitempty"`
+	CPUPercent      float64        `json:"cpu_percent"`
+	RSS             uint64         `json:"rss_bytes"`
+	Threads         int32          `json:"threads"`
+	TotalCPUPercent float64        `json:"total_cpu_percent"`
+	TotalRSS        uint64         `json:"total_rss_bytes"`
+	TotalThreads    int32          `json:"total_threads"`
+	Descendants     int            `json:"descendants"`
+	Children        []*ProcessNode `json:"children,omitempty"`
+}
+
+// ProcessTreeReport is the result of a process tree query
+type ProcessTreeReport struct {
+	Interval  string         `json:"interval"`
+	Processes int            `json:"processes"`
+	Roots     []*ProcessNode `json:"roots"`
+}
+
+// newProcessTreeOptions validates process tree options shared by the CLI and API
+func newProcessTreeOptions(root, user, unit, interval string, asJSON bool) (ProcessTreeOptions, error) {
+	opts := ProcessTreeOptions{User: user, Unit: unit, Interval: defaultTopInterval, JSON: asJSON}
+	if root != "" {
+		pid, err := strconv.Atoi(root)
+		if err != nil || pid <= 0 {
+			return opts, fmt.Errorf("invalid root PID %q", root)
+		}
+		opts.Root = int32(pid)
+	}
+	if interval != "" {
+		d, err := parseDurationValue(interval)
+		if err != nil || d <= 0 || d > maxRateInterval {
+			return opts, fmt.Errorf("invalid interval %q (must be between 0 and %s)", interval, maxRateInterval)
+		}
+		opts.Interval = d
+	}
+	return opts, nil
+}
+
+// buildProcessTree links sampled processes by parent PID. A process whose
+// parent is not part of the selection becomes a root.
+func buildProcessTree(stats []ProcessStat, opts ProcessTreeOptions) ([]*ProcessNode, int, error) {
+	nodes := make(map[int32]*ProcessNode, len(stats))
+	for _, st := range stats {
+		nodes[st.PID] = &ProcessNode{PID: st.PID, PPID: st.PPID, User: st.User, Name: st.Name, Command: st.Command,
+			CPUPercent: st.CPUPercent, RSS: st.RSS, Threads: st.Threads}
+	}
+
+	// Restrict to the subtree below the root PID first
+	if opts.Root != 0 {
+		if _, ok := nodes[opts.Root]; !ok {
+			return nil, 0, fmt.Errorf("process %d not found", opts.Root)
+		}
+		children := make(map[int32][]int32)
+		for _, n := range nodes {
+			children[n.PPID] = append(children[n.PPID], n.PID)
+		}
+		keep := make(map[int32]*ProcessNode)
+		queue := []int32{opts.Root}
+		for len(queue) > 0 {
+			pid := queue[0]
+			queue = queue[1:]
+			if _, seen := keep[pid]; seen {
+				continue
+			}
+			keep[pid] = nodes[pid]
+			queue = append(queue, children[pid]...)
+		}
+		nodes = keep
+	}
+
+	for pid, n := range nodes {
+		if opts.User != "" && n.User != opts.User {
+			delete(nodes, pid)
+			continue
+		}
+		if opts.Unit != "" {
+			n.Unit = processUnit(pid)
+			if !matchUnitPatterns([]string{opts.Unit}, n.Unit) {
+				delete(nodes, pid)
+			}
+		}
+	}
+
+	var roots []*ProcessNode
+	for _, n := range nodes {
+		parent, ok := nodes[n.PPID]
+		if ok && n.PID != opts.Root && n.PPID != n.PID {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	sortProcessNodes(roots)
+	for _, n := range roots {
+		rollupProcessNode(n)
+	}
+	return roots, len(nodes), nil
+}
+
+// sortProcessNodes orders nodes and their children by PID
+func sortProcessNodes(nodes []*ProcessNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].PID < nodes[j].PID })
+	for _, n := range nodes {
+		sortProcessNodes(n.Children)
+	}
+}
+
+// rollupProcessNode fills the cumulative fields of a node and its descendants
+func rollupProcessNode(n *ProcessNode) {
+	n.TotalCPUPercent, n.TotalRSS, n.TotalThreads, n.Descendants = n.CPUPercent, n.RSS, n.Threads, 0
+	for _, c := range n.Children {
+		rollupProcessNode(c)
+		n.TotalCPUPercent += c.TotalCPUPercent
+		n.TotalRSS += c.TotalRSS
+		n.TotalThreads += c.TotalThreads
+		n.Descendants += c.Descendants + 1
+	}
+}
+
+// getProcessTreeReport samples processes and builds the tree
+func getProcessTreeReport(opts ProcessTreeOptions) (ProcessTreeReport, error) {
+	stats, _, err := collectProcessStats(opts.Interval)
+	if err != nil {
+		return ProcessTreeReport{}, err
+	}
+	roots, count, err := buildProcessTree(stats, opts)
+	if err != nil {
+		return ProcessTreeReport{}, err
+	}
+	return ProcessTreeReport{Interval: opts.Interval.String(), Processes: count, Roots: roots}, nil
+}
+
+// writeProcessNode renders a node and its children with tree branches
+func writeProcessNode(w io.Writer, n *ProcessNode, prefix, branch string) {
+	fmt.Fprintf(w, "%d\t%.1f\t%s\t%d\t%.1f\t%s\t%d\t%s%s\n", n.PID, n.CPUPercent, formatBytes(n.RSS), n.Threads,
+		n.TotalCPUPercent, formatBytes(n.TotalRSS), n.TotalThreads, prefix+branch, n.Name)
+	childPrefix := prefix
+	switch branch {
+	case "├─ ":
+		childPrefix += "│  "
+	case "└─ ":
+		childPrefix += "   "
+	}
+	for i, c := range n.Children {
+		if i == len(n.Children)-1 {
+			writeProcessNode(w, c, childPrefix, "└─ ")
+		} else {
+			writeProcessNode(w, c, childPrefix, "├─ ")
+		}
+	}
+}
+
+// getProcessTree shows the process tree with per-process and cumulative CPU, RSS and threads
+func getProcessTree(opts ProcessTreeOptions) string {
+	report, err := getProcessTreeReport(opts)
+	if err != nil {
+		return fmt.Sprintf("Failed to get process tree. Error: %v", err)
+	}
+	if opts.JSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode process tree. Error: %v", err)
+		}
+		return string(data)
+	}
+	if len(report.Roots) == 0 {
+		return "No matching processes"
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Process tree (%d processes, CPU sampled over %s; TOTAL includes descendants)\n\n", report.Processes, report.Interval))
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	io.WriteString(w, "PID\tCPU%\tRSS\tTHR\tTOTAL CPU%\tTOTAL RSS\tTOTAL THR\tPROCESS\n")
+	for _, n := range report.Roots {
+		writeProcessNode(w, n, "", "")
+	}
+	w.Flush()
+	return output.String()
+}
+
+// runProcessTree parses "osctl process tree" arguments
+func runProcessTree(args []string) string {
+	usage := "Usage: osctl process tree [--pid PID] [--user NAME] [--unit UNIT] [--interval 1s] [--json]"
+	fs := flag.NewFlagSet("process tree", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	root := fs.String("pid", "", "show the subtree below this PID")
+	user := fs.String("user", "", "only processes of this user")
+	unit := fs.String("unit", "", "only processes in this systemd unit")
+	interval := fs.String("interval", "", "CPU sampling interval")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newProcessTreeOptions(*root, *user, *unit, *interval, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getProcessTree(opts)
+}
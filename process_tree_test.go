@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func processTreeTestStats() []ProcessStat {
+	return []ProcessStat{
+		{PID: 1, PPID: 0, User: "root", Name: "systemd", CPUPercent: 1, RSS: 100, Threads: 1},
+		{PID: 10, PPID: 1, User: "root", Name: "nginx", CPUPercent: 2, RSS: 200, Threads: 1},
+		{PID: 11, PPID: 10, User: "www-data", Name: "nginx", CPUPercent: 3, RSS: 300, Threads: 4},
+		{PID: 12, PPID: 10, User: "www-data", Name: "nginx", CPUPercent: 4, RSS: 400, Threads: 4},
+		{PID: 20, PPID: 1, User: "postgres", Name: "postgres", CPUPercent: 5, RSS: 500, Threads: 1},
+		{PID: 30, PPID: 999, User: "root", Name: "orphan", RSS: 10, Threads: 1},
+	}
+}
+
+func TestBuildProcessTree(t *testing.T) {
+	roots, count, err := buildProcessTree(processTreeTestStats(), ProcessTreeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 || len(roots) != 2 || roots[0].PID != 1 || roots[1].PID != 30 {
+		t.Fatalf("got %d processes with roots %+v", count, roots)
+	}
+	init := roots[0]
+	if len(init.Children) != 2 || init.Children[0].PID != 10 || init.Children[1].PID != 20 {
+		t.Fatalf("children of PID 1 = %+v", init.Children)
+	}
+	if init.Descendants != 4 || init.TotalRSS != 1500 || init.TotalThreads != 11 || init.TotalCPUPercent != 15 {
+		t.Errorf("PID 1 rollup = %d descendants, %d RSS, %d threads, %v%% CPU", init.Descendants, init.TotalRSS, init.TotalThreads, init.TotalCPUPercent)
+	}
+	if nginx := init.Children[0]; nginx.Descendants != 2 || nginx.TotalRSS != 900 || nginx.RSS != 200 {
+		t.Errorf("nginx rollup = %+v", nginx)
+	}
+}
+
+func TestBuildProcessTreeSubtree(t *testing.T) {
+	roots, count, err := buildProcessTree(processTreeTestStats(), ProcessTreeOptions{Root: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || len(roots) != 1 || roots[0].PID != 10 || roots[0].Descendants != 2 {
+		t.Errorf("got %d processes with roots %+v", count, roots)
+	}
+
+	if _, _, err := buildProcessTree(processTreeTestStats(), ProcessTreeOptions{Root: 4242}); err == nil {
+		t.Error("a missing root PID was accepted")
+	}
+}
+
+func TestBuildProcessTreeUserFilter(t *testing.T) {
+	// Processes whose parent is filtered out become roots
+	roots, count, err := buildProcessTree(processTreeTestStats(), ProcessTreeOptions{User: "www-data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || len(roots) != 2 || roots[0].PID != 11 || roots[1].PID != 12 {
+		t.Errorf("got %d processes with roots %+v", count, roots)
+	}
+}
+
+func TestRollupProcessNodeResetsTotals(t *testing.T) {
+	n := &ProcessNode{PID: 1, RSS: 10, Threads: 1, Children: []*ProcessNode{{PID: 2, RSS: 5, Threads: 2}}}
+	rollupProcessNode(n)
+	rollupProcessNode(n)
+	if n.TotalRSS != 15 || n.TotalThreads != 3 || n.Descendants != 1 {
+		t.Errorf("rolling up twice gave %+v", n)
+	}
+}
+
+func TestNewProcessTreeOptions(t *testing.T) {
+	if _, err := newProcessTreeOptions("1", "", "", "2s", false); err != nil {
+		t.Errorf("valid options rejected: %v", err)
+	}
+	for _, c := range []struct{ root, interval string }{{"0", ""}, {"init", ""}, {"", "0s"}, {"", "forever"}} {
+		if _, err := newProcessTreeOptions(c.root, "", "", c.interval, false); err == nil {
+			t.Errorf("newProcessTreeOptions(root %q, interval %q) succeeded", c.root, c.interval)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+const (
+	defaultWatchInterval = 250 * time.Millisecond
+	minWatchInterval     = 50 * time.Millisecond
+	maxWatchInterval     = 10 * time.Second
+	// userHZ is the clock tick of /proc/<pid>/stat times, fixed at 100 on Linux
+	userHZ = 100
+)
+
+// ProcessWatchOptions controls polling and filtering of process events
+type ProcessWatchOptions struct {
+	Interval time.Duration
+	Duration time.Duration
+	User     string
+	Name     string
+	JSON     bool
+}
+
+// ProcessEvent is a process start or exit observed between two polls of /proc
+type ProcessEvent struct {
+	Type           string     `json:"type"`
+	Time           time.Time  `json:"time"`
+	PID            int32      `json:"pid"`
+	PPID           int32      `json:"ppid"`
+	User           string     `json:"user"`
+	Name           string     `json:"name"`
+	Command        string     `json:"command,omitempty"`
+	StartTime      time.Time  `json:"start_time"`
+	ExitTime       *time.Time `json:"exit_time,omitempty"`
+	RuntimeSeconds float64    `json:"runtime_seconds,omitempty"`
+}
+
+// watchedProcess is what is remembered about a running process so its exit can be reported
+type watchedProcess struct {
+	ProcessTarget
+	start      time.Time
+	startTicks uint64
+}
+
+// newProcessWatchOptions validates watch options shared by the CLI and API
+func newProcessWatchOptions(interval, duration, user, name string, asJSON bool) (ProcessWatchOptions, error) {
+	opts := ProcessWatchOptions{Interval: defaultWatchInterval, User: user, Name: name, JSON: asJSON}
+	if interval != "" {
+		d, err := parseDurationValue(interval)
+		if err != nil || d < minWatchInterval || d > maxWatchInterval {
+			return opts, fmt.Errorf("invalid interval %q (must be between %s and %s)", interval, minWatchInterval, maxWatchInterval)
+		}
+		opts.Interval = d
+	}
+	if duration != "" {
+		d, err := parseDurationValue(duration)
+		if err != nil || d <= 0 {
+			return opts, fmt.Errorf("invalid duration %q", duration)
+		}
+		opts.Duration = d
+	}
+	return opts, nil
+}
+
+// readWatchedProcess reads a process as soon as it is seen. Short-lived
+// processes may already be gone, in which case ok is false and only the PID
+// and whatever could still be read (usually nothing) are returned.
+func readWatchedProcess(pid int32) (watchedProcess, bool) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return watchedProcess{ProcessTarget: ProcessTarget{PID: pid}}, false
+	}
+	ticks, _ := processStartTicks(pid)
+	w := watchedProcess{ProcessTarget: readProcessTarget(p), start: processStartTime(pid), startTicks: ticks}
+	return w, w.Name != ""
+}
+
+// watchReader reads the processes compared between two polls
+type watchReader struct {
+	startTicks func(pid int32) (uint64, error)
+	read       func(pid int32) (watchedProcess, bool)
+}
+
+var procWatchReader = watchReader{startTicks: processStartTicks, read: readWatchedProcess}
+
+// processStartTicks reads the start time of a process in clock ticks since
+// boot. Together with the PID it identifies a process, since PIDs are reused.
+func processStartTicks(pid int32) (uint64, error) {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// Field 22 (starttime) is the 20th field after the parenthesized command
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// processStartTime derives the start time of a process from its start tick in
+// /proc/<pid>/stat and /proc/uptime, which is more precise than gopsutil's
+// CreateTime (based on the boot time in whole seconds)
+func processStartTime(pid int32) time.Time {
+	ticks, err := processStartTicks(pid)
+	if err != nil {
+		return time.Time{}
+	}
+	uptime, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Time{}
+	}
+	up := strings.Fields(string(uptime))
+	if len(up) < 1 {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseFloat(up[0], 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration((seconds - float64(ticks)/userHZ) * float64(time.Second)))
+}
+
+// exitEvent builds the exit event of a process that is no longer running
+func exitEvent(pid int32, w watchedProcess, now time.Time) ProcessEvent {
+	e := ProcessEvent{Type: "exit", Time: now, PID: pid, PPID: w.PPID, User: w.User, Name: w.Name,
+		Command: w.Command, StartTime: w.start, ExitTime: &now}
+	if !w.start.IsZero() {
+		e.RuntimeSeconds = now.Sub(w.start).Seconds()
+	}
+	return e
+}
+
+// matchWatchFilter applies the user and name filters to an event
+func matchWatchFilter(e ProcessEvent, opts ProcessWatchOptions) bool {
+	if opts.User != "" && e.User != opts.User {
+		return false
+	}
+	return matchProcessName(opts.Name, e.Name, e.Command)
+}
+
+// diffProcesses compares the current PID list with the known processes,
+// updates known and returns the starts and exits in between. A process that
+// exits before it can be read is still reported, by PID alone.
+func diffProcesses(known map[int32]watchedProcess, pids []int32, now time.Time, r watchReader) []ProcessEvent {
+	alive := make(map[int32]bool, len(pids))
+	var events []ProcessEvent
+	for _, pid := range pids {
+		alive[pid] = true
+		if old, seen := known[pid]; seen {
+			// A different start time means the PID was reused within one interval
+			ticks, err := r.startTicks(pid)
+			if err != nil || old.startTicks == 0 || ticks == old.startTicks {
+				continue
+			}
+			delete(known, pid)
+			events = append(events, exitEvent(pid, old, now))
+		}
+		w, _ := r.read(pid)
+		known[pid] = w
+		events = append(events, ProcessEvent{Type: "start", Time: now, PID: pid, PPID: w.PPID, User: w.User,
+			Name: w.Name, Command: w.Command, StartTime: w.start})
+	}
+	for pid, w := range known {
+		if alive[pid] {
+			continue
+		}
+		delete(known, pid)
+		events = append(events, exitEvent(pid, w, now))
+	}
+	return events
+}
+
+// watchProcesses polls the PID list and calls emit for every matching start
+// and exit until ctx is done or emit returns false. Processes running when the
+// watch starts are not reported.
+func watchProcesses(ctx context.Context, opts ProcessWatchOptions, emit func(ProcessEvent) bool) error {
+	pids, err := process.Pids()
+	if err != nil {
+		return err
+	}
+	known := make(map[int32]watchedProcess, len(pids))
+	for _, pid := range pids {
+		known[pid], _ = readWatchedProcess(pid)
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		pids, err := process.Pids()
+		if err != nil {
+			return err
+		}
+		events := diffProcesses(known, pids, time.Now(), procWatchReader)
+		for _, e := range events {
+			if matchWatchFilter(e, opts) && !emit(e) {
+				return nil
+			}
+		}
+	}
+}
+
+// formatProcessEvent renders an event as one line of text
+func formatProcessEvent(e ProcessEvent) string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s %-5s pid=%d ppid=%d (gone before it could be read)", e.Time.Format("15:04:05.000"), e.Type, e.PID, e.PPID)
+	}
+	line := fmt.Sprintf("%s %-5s pid=%d ppid=%d user=%s %s", e.Time.Format("15:04:05.000"), e.Type, e.PID, e.PPID, e.User, e.Name)
+	if e.Type == "exit" && e.RuntimeSeconds > 0 {
+		line += fmt.Sprintf(" runtime=%s", formatSeconds(e.RuntimeSeconds))
+	}
+	if e.Command != "" {
+		line += " cmd=" + e.Command
+	}
+	return line
+}
+
+// streamProcessEvents serves process events as server-sent events until the client disconnects
+func streamProcessEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts, err := newProcessWatchOptions(q.Get("interval"), q.Get("duration"), q.Get("user"), q.Get("name"), true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	ctx := r.Context()
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	err = watchProcesses(ctx, opts, func(e ProcessEvent) bool {
+		data, _ := json.Marshal(e)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+		flusher.Flush()
+	}
+}
+
+// runProcessWatch parses "osctl process watch" arguments and prints events until interrupted
+func runProcessWatch(args []string) string {
+	usage := "Usage: osctl process watch [--user NAME] [--name PATTERN] [--interval 250ms] [--duration 1m] [--json]\n" +
+		"/proc is polled, so processes that start and exit within one interval are not seen and\n" +
+		"ones that exit before they can be read are reported by PID only"
+	fs := flag.NewFlagSet("process watch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	user := fs.String("user", "", "only processes of this user")
+	name := fs.String("name", "", "only processes whose name matches (glob or substring)")
+	interval := fs.String("interval", "", "polling interval")
+	duration := fs.String("duration", "", "stop after this long")
+	asJSON := fs.Bool("json", false, "output one JSON object per line")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newProcessWatchOptions(*interval, *duration, *user, *name, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	if !opts.JSON {
+		fmt.Printf("Watching process starts and exits every %s (Ctrl-C to stop, processes living shorter than that may be missed)...\n", opts.Interval)
+	}
+
+	counts := map[string]int{}
+	err = watchProcesses(ctx, opts, func(e ProcessEvent) bool {
+		counts[e.Type]++
+		if opts.JSON {
+			data, _ := json.Marshal(e)
+			fmt.Println(string(data))
+		} else {
+			fmt.Println(formatProcessEvent(e))
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Sprintf("Failed to watch processes. Error: %v", err)
+	}
+	if opts.JSON {
+		return ""
+	}
+	return fmt.Sprintf("%d starts, %d exits", counts["start"], counts["exit"])
+}
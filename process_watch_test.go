@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeWatchReader serves processes from a map keyed by PID
+func fakeWatchReader(procs map[int32]watchedProcess) watchReader {
+	return watchReader{
+		startTicks: func(pid int32) (uint64, error) {
+			w, ok := procs[pid]
+			if !ok {
+				return 0, errors.New("no such process")
+			}
+			return w.startTicks, nil
+		},
+		read: func(pid int32) (watchedProcess, bool) {
+			w, ok := procs[pid]
+			if !ok {
+				return watchedProcess{ProcessTarget: ProcessTarget{PID: pid}}, false
+			}
+			return w, true
+		},
+	}
+}
+
+func watchTestProcess(pid, ppid int32, name string, ticks uint64) watchedProcess {
+	return watchedProcess{ProcessTarget: ProcessTarget{PID: pid, PPID: ppid, User: "root", Name: name}, startTicks: ticks}
+}
+
+func eventSummary(events []ProcessEvent) map[string]ProcessEvent {
+	summary := make(map[string]ProcessEvent)
+	for _, e := range events {
+		summary[e.Type+" "+e.Name] = e
+	}
+	return summary
+}
+
+func TestDiffProcessesStartsAndExits(t *testing.T) {
+	start := time.Now()
+	procs := map[int32]watchedProcess{1: watchTestProcess(1, 0, "init", 1), 10: watchTestProcess(10, 1, "cron", 100)}
+	known := map[int32]watchedProcess{1: procs[1], 10: {ProcessTarget: procs[10].ProcessTarget, start: start, startTicks: 100}}
+
+	procs[20] = watchTestProcess(20, 10, "job", 200)
+	delete(procs, 10)
+	now := start.Add(time.Second)
+	events := eventSummary(diffProcesses(known, []int32{1, 20}, now, fakeWatchReader(procs)))
+	if len(events) != 2 {
+		t.Fatalf("got %v, want one start and one exit", events)
+	}
+	if e, ok := events["start job"]; !ok || e.PID != 20 || e.PPID != 10 {
+		t.Errorf("start event = %+v", e)
+	}
+	if e, ok := events["exit cron"]; !ok || e.PID != 10 || e.RuntimeSeconds != 1 || e.ExitTime == nil {
+		t.Errorf("exit event = %+v", e)
+	}
+	if _, ok := known[10]; ok || known[20].Name != "job" {
+		t.Errorf("known processes not updated: %v", known)
+	}
+
+	// Nothing changed, nothing to report
+	if events := diffProcesses(known, []int32{1, 20}, now, fakeWatchReader(procs)); len(events) != 0 {
+		t.Errorf("got %+v without changes", events)
+	}
+}
+
+func TestDiffProcessesReusedPID(t *testing.T) {
+	procs := map[int32]watchedProcess{30: watchTestProcess(30, 1, "old", 300)}
+	known := map[int32]watchedProcess{30: procs[30]}
+	procs[30] = watchTestProcess(30, 1, "new", 301)
+
+	events := diffProcesses(known, []int32{30}, time.Now(), fakeWatchReader(procs))
+	if len(events) != 2 || events[0].Type != "exit" || events[0].Name != "old" || events[1].Type != "start" || events[1].Name != "new" {
+		t.Errorf("got %+v, want the exit of the old process before the start of the new one", events)
+	}
+	if known[30].startTicks != 301 {
+		t.Errorf("known process is %+v", known[30])
+	}
+}
+
+func TestDiffProcessesUnreadableProcess(t *testing.T) {
+	// The PID was listed but the process exited before it could be read
+	known := map[int32]watchedProcess{}
+	events := diffProcesses(known, []int32{40}, time.Now(), fakeWatchReader(nil))
+	if len(events) != 1 || events[0].Type != "start" || events[0].PID != 40 || events[0].Name != "" {
+		t.Fatalf("got %+v, want a start event with the PID only", events)
+	}
+	if got := formatProcessEvent(events[0]); got == "" {
+		t.Error("the event was not rendered")
+	}
+
+	// It is not reported again while listed, and its exit is reported once gone
+	if events := diffProcesses(known, []int32{40}, time.Now(), fakeWatchReader(nil)); len(events) != 0 {
+		t.Errorf("got %+v while the PID is still listed", events)
+	}
+	events = diffProcesses(known, nil, time.Now(), fakeWatchReader(nil))
+	if len(events) != 1 || events[0].Type != "exit" || events[0].PID != 40 || events[0].RuntimeSeconds != 0 {
+		t.Errorf("got %+v, want an exit event without runtime", events)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultRestartBackoff = 2 * time.Second
+	maxRestartBackoff     = 5 * time.Minute
+	maxRestartAttempts    = 10
+	// maxRestartFailedWait bounds the backoff slept over a whole run, which
+	// also executes inside an API request
+	maxRestartFailedWait     = 10 * time.Minute
+	restartFailedReasonLines = 5
+)
+
+// RestartFailedOptions controls restarting failed units
+type RestartFailedOptions struct {
+	Include     []string
+	Exclude     []string
+	MaxAttempts int
+	Backoff     time.Duration
+	ResetFailed bool
+	DryRun      bool
+	JSON        bool
+}
+
+// RestartFailedResult is the outcome for one failed unit
+type RestartFailedResult struct {
+	Unit     string   `json:"unit"`
+	Order    int      `json:"order,omitempty"`
+	Result   string   `json:"result,omitempty"`
+	Reason   []string `json:"reason,omitempty"`
+	Status   string   `json:"status"`
+	Attempts int      `json:"attempts,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// splitPatterns splits a comma-separated list of unit globs
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// newRestartFailedOptions validates restart-failed options shared by the CLI and API
+func newRestartFailedOptions(include, exclude, attempts, backoff string, resetFailed, dryRun, asJSON bool) (RestartFailedOptions, error) {
+	opts := RestartFailedOptions{
+		Include:     splitPatterns(include),
+		Exclude:     splitPatterns(exclude),
+		MaxAttempts: 1,
+		Backoff:     defaultRestartBackoff,
+		ResetFailed: resetFailed,
+		DryRun:      dryRun,
+		JSON:        asJSON,
+	}
+	for _, p := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return opts, fmt.Errorf("invalid pattern %q", p)
+		}
+	}
+	if attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 || n > maxRestartAttempts {
+			return opts, fmt.Errorf("invalid max attempts %q (must be 1-%d)", attempts, maxRestartAttempts)
+		}
+		opts.MaxAttempts = n
+	}
+	if backoff != "" {
+		d, err := parseDurationValue(backoff)
+		if err != nil || d < 0 || d > maxRestartBackoff {
+			return opts, fmt.Errorf("invalid backoff %q (must be between 0 and %s)", backoff, maxRestartBackoff)
+		}
+		opts.Backoff = d
+	}
+	return opts, nil
+}
+
+// matchUnitPatterns matches a unit by full name or by name without the .service suffix
+func matchUnitPatterns(patterns []string, unit string) bool {
+	short := strings.TrimSuffix(unit, ".service")
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, unit); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, short); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// orderUnitsByDependencies sorts units so that each one comes after the
+// units it is ordered After= or Requires=; cycles fall back to name order
+func orderUnitsByDependencies(m unitManager, units []string) []string {
+	pending := make(map[string]bool, len(units))
+	for _, u := range units {
+		pending[u] = true
+	}
+	deps := make(map[string][]string, len(units))
+	for _, u := range units {
+		props, err := m.UnitProperties(u, "Unit", []string{"After", "Requires"})
+		if err != nil {
+			continue
+		}
+		for _, d := range strings.Fields(props["After"] + " " + props["Requires"]) {
+			if pending[d] && d != u {
+				deps[u] = append(deps[u], d)
+			}
+		}
+	}
+
+	sorted := append([]string{}, units...)
+	sort.Strings(sorted)
+	var ordered []string
+	for len(pending) > 0 {
+		progressed := false
+		for _, u := range sorted {
+			if !pending[u] {
+				continue
+			}
+			ready := true
+			for _, d := range deps[u] {
+				if pending[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, u)
+				delete(pending, u)
+				progressed = true
+			}
+		}
+		if !progressed {
+			// Dependency cycle: take the remaining units in name order
+			for _, u := range sorted {
+				if pending[u] {
+					ordered = append(ordered, u)
+					delete(pending, u)
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// restartFailedUnits restarts failed units in dependency order with retries,
+// returning a per-unit report
+func restartFailedUnits(opts RestartFailedOptions) ([]RestartFailedResult, error) {
+	m, err := newUnitManager()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	entries, err := m.ListUnits([]string{"failed"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []RestartFailedResult
+	var selected []string
+	for _, e := range entries {
+		if (len(opts.Include) > 0 && !matchUnitPatterns(opts.Include, e.Name)) || matchUnitPatterns(opts.Exclude, e.Name) {
+			results = append(results, RestartFailedResult{Unit: e.Name, Status: "skipped"})
+			continue
+		}
+		selected = append(selected, e.Name)
+	}
+
+	var waited time.Duration
+	for i, unit := range orderUnitsByDependencies(m, selected) {
+		r := RestartFailedResult{Unit: unit, Order: i + 1}
+		if info, err := getUnitInfo(m, unit); err == nil {
+			r.Result = info.Result
+		}
+		// The journal tail explains why the unit failed before it is restarted
+		r.Reason, _ = getUnitJournal(unit, restartFailedReasonLines)
+
+		if opts.DryRun {
+			r.Status = "planned"
+			results = append(results, r)
+			continue
+		}
+		if opts.ResetFailed {
+			if err := m.ResetFailedUnit(unit); err != nil {
+				r.Error = fmt.Sprintf("reset-failed: %v", err)
+			}
+		}
+		backoff := opts.Backoff
+		for r.Attempts < opts.MaxAttempts {
+			r.Attempts++
+			if _, err = m.RunUnitJob("restart", unit); err == nil {
+				break
+			}
+			if r.Attempts >= opts.MaxAttempts {
+				break
+			}
+			if waited+backoff > maxRestartFailedWait {
+				err = fmt.Errorf("%v (retries stopped: backoff would exceed %s in total)", err, maxRestartFailedWait)
+				break
+			}
+			time.Sleep(backoff)
+			waited += backoff
+			backoff = min(backoff*2, maxRestartBackoff)
+		}
+		if err != nil {
+			r.Status = "failed"
+			r.Error = err.Error()
+		} else {
+			r.Status = "restarted"
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// getRestartFailed renders the restart-failed report
+func getRestartFailed(opts RestartFailedOptions) string {
+	results, err := restartFailedUnits(opts)
+	if err != nil {
+		return fmt.Sprintf("Failed to restart failed services: %v", err)
+	}
+	if opts.JSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode report: %v", err)
+		}
+		return string(data)
+	}
+	if len(results) == 0 {
+		return "No failed services found"
+	}
+
+	var output strings.Builder
+	if opts.DryRun {
+		output.WriteString("Dry run, nothing was restarted. Plan:\n\n")
+	}
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER\tUNIT\tRESULT\tSTATUS\tATTEMPTS\tERROR")
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		order, attempts := "-", "-"
+		if r.Order > 0 {
+			order = strconv.Itoa(r.Order)
+		}
+		if r.Attempts > 0 {
+			attempts = strconv.Itoa(r.Attempts)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", order, r.Unit, r.Result, r.Status, attempts, r.Error)
+	}
+	w.Flush()
+
+	for _, r := range results {
+		if len(r.Reason) == 0 {
+			continue
+		}
+		output.WriteString(fmt.Sprintf("\n%s failed with:\n  %s\n", r.Unit, strings.Join(r.Reason, "\n  ")))
+	}
+	output.WriteString(fmt.Sprintf("\n%d restarted, %d failed, %d planned, %d skipped\n", counts["restarted"], counts["failed"], counts["planned"], counts["skipped"]))
+	return output.String()
+}
+
+// runRestartFailedCommand parses "osctl maintenance restart-failed" arguments
+func runRestartFailedCommand(args []string) string {
+	usage := "Usage: osctl maintenance restart-failed [--include 'nginx*,php*'] [--exclude GLOBS] [--max-attempts 1] [--backoff 2s] [--reset-failed] [--dry-run] [--json]"
+	fs := flag.NewFlagSet("restart-failed", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	include := fs.String("include", "", "comma-separated unit globs to restart")
+	exclude := fs.String("exclude", "", "comma-separated unit globs to leave alone")
+	attempts := fs.String("max-attempts", "", "restart attempts per unit")
+	backoff := fs.String("backoff", "", "delay before the second attempt, doubled for each further attempt up to 5m")
+	resetFailed := fs.Bool("reset-failed", false, "reset the failed state before restarting")
+	dryRun := fs.Bool("dry-run", false, "only print the plan")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newRestartFailedOptions(*include, *exclude, *attempts, *backoff, *resetFailed, *dryRun, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getRestartFailed(opts)
+}
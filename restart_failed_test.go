@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOrderUnitsByDependencies(t *testing.T) {
+	m := &fakeUnitManager{
+		props: map[string]map[string]string{
+			"app.service/Unit":   {"After": "network.target db.service", "Requires": "cache.service"},
+			"db.service/Unit":    {"After": "network.target", "Requires": ""},
+			"cache.service/Unit": {"After": "db.service"},
+			"web.service/Unit":   {"After": "app.service"},
+		},
+	}
+	got := orderUnitsByDependencies(m, []string{"web.service", "app.service", "cache.service", "db.service", "other.service"})
+	want := []string{"db.service", "other.service", "cache.service", "app.service", "web.service"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOrderUnitsByDependenciesBreaksCycles(t *testing.T) {
+	m := &fakeUnitManager{
+		props: map[string]map[string]string{
+			"a.service/Unit": {"After": "b.service"},
+			"b.service/Unit": {"After": "a.service"},
+			"c.service/Unit": {"After": "a.service"},
+		},
+	}
+	got := orderUnitsByDependencies(m, []string{"c.service", "b.service", "a.service"})
+	want := []string{"a.service", "b.service", "c.service"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMatchUnitPatterns(t *testing.T) {
+	patterns := splitPatterns(" nginx , php*-fpm.service,,")
+	if !slices.Equal(patterns, []string{"nginx", "php*-fpm.service"}) {
+		t.Fatalf("splitPatterns gave %q", patterns)
+	}
+	for unit, want := range map[string]bool{
+		"nginx.service":      true,
+		"nginx.socket":       false,
+		"php8.2-fpm.service": true,
+		"apache2.service":    false,
+	} {
+		if got := matchUnitPatterns(patterns, unit); got != want {
+			t.Errorf("matchUnitPatterns(%q) = %v, want %v", unit, got, want)
+		}
+	}
+}
+
+func TestNewRestartFailedOptions(t *testing.T) {
+	opts, err := newRestartFailedOptions("nginx", "", "3", "500ms", true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.MaxAttempts != 3 || opts.Backoff != 500*time.Millisecond || !opts.ResetFailed {
+		t.Errorf("got %+v", opts)
+	}
+	for _, c := range []struct{ include, attempts, backoff string }{
+		{"[", "", ""},
+		{"", "0", ""},
+		{"", "11", ""},
+		{"", "", "-1s"},
+		{"", "", "6m"},
+	} {
+		if _, err := newRestartFailedOptions(c.include, "", c.attempts, c.backoff, false, false, false); err == nil {
+			t.Errorf("newRestartFailedOptions(%q, attempts %q, backoff %q) succeeded", c.include, c.attempts, c.backoff)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const overrideFileName = "osctl.conf"
+
+// systemdUnitDir is where administrator drop-ins are written
+var systemdUnitDir = "/etc/systemd/system"
+
+// overrideKeys are the settings osctl may override, mapped to their section.
+// "" means the unit type's own section, e.g. [Service] or [Socket].
+var overrideKeys = map[string]string{
+	"Restart":               "",
+	"RestartSec":            "",
+	"Environment":           "",
+	"EnvironmentFile":       "",
+	"TimeoutStartSec":       "",
+	"TimeoutStopSec":        "",
+	"WatchdogSec":           "",
+	"Nice":                  "",
+	"LimitNOFILE":           "",
+	"LimitNPROC":            "",
+	"LimitCORE":             "",
+	"MemoryMax":             "",
+	"MemoryHigh":            "",
+	"MemorySwapMax":         "",
+	"CPUQuota":              "",
+	"CPUWeight":             "",
+	"AllowedCPUs":           "",
+	"IOWeight":              "",
+	"TasksMax":              "",
+	"OOMScoreAdjust":        "",
+	"StartLimitIntervalSec": "Unit",
+	"StartLimitBurst":       "Unit",
+}
+
+var (
+	validRestartValues = map[string]bool{
+		"no": true, "always": true, "on-success": true, "on-failure": true,
+		"on-abnormal": true, "on-abort": true, "on-watchdog": true,
+	}
+	memoryValuePattern = regexp.MustCompile(`^(infinity|\d+(\.\d+)?%|\d+[KMGT]?)$`)
+	cpuQuotaPattern    = regexp.MustCompile(`^\d+%$`)
+	envAssignPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+)
+
+// overrideEntry is one assignment in the drop-in file
+type overrideEntry struct {
+	Section string
+	Key     string
+	Value   string
+}
+
+// overrideFilePath returns the osctl drop-in path of a unit
+func overrideFilePath(unit string) string {
+	return filepath.Join(systemdUnitDir, unit+".d", overrideFileName)
+}
+
+// validateOverride checks a key against the known list and sanity-checks common values
+func validateOverride(key, value string) error {
+	if _, ok := overrideKeys[key]; !ok {
+		keys := make([]string, 0, len(overrideKeys))
+		for k := range overrideKeys {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("unsupported key %q. Valid: %s", key, strings.Join(keys, ", "))
+	}
+	if strings.ContainsAny(value, "\n\r") {
+		return fmt.Errorf("invalid value for %s: contains a newline", key)
+	}
+	switch key {
+	case "Restart":
+		if !validRestartValues[value] {
+			return fmt.Errorf("invalid Restart value %q", value)
+		}
+	case "MemoryMax", "MemoryHigh", "MemorySwapMax":
+		if !memoryValuePattern.MatchString(value) {
+			return fmt.Errorf("invalid %s value %q (e.g. 512M, 2G, 50%%, infinity)", key, value)
+		}
+	case "CPUQuota":
+		if !cpuQuotaPattern.MatchString(value) {
+			return fmt.Errorf("invalid CPUQuota value %q (e.g. 150%%)", value)
+		}
+	case "Environment":
+		if !envAssignPattern.MatchString(value) {
+			return fmt.Errorf("invalid Environment value %q (expected NAME=value)", value)
+		}
+	}
+	return nil
+}
+
+// overrideSection returns the section a key is written to for a unit
+func overrideSection(unit, key string) string {
+	if section := overrideKeys[key]; section != "" {
+		return section
+	}
+	return unitType(unit)
+}
+
+// readOverrides parses the osctl drop-in of a unit; a missing file has no entries
+func readOverrides(unit string) ([]overrideEntry, error) {
+	data, err := os.ReadFile(overrideFilePath(unit))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []overrideEntry
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if key == "Environment" {
+				value = unquoteEnvironment(value)
+			}
+			entries = append(entries, overrideEntry{Section: section, Key: key, Value: value})
+		}
+	}
+	return entries, nil
+}
+
+// quoteEnvironment quotes a NAME=value assignment for Environment=, so values
+// with spaces stay one assignment. Backslashes and quotes are escaped, and %
+// is doubled because systemd expands specifiers in Environment=.
+func quoteEnvironment(assignment string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%")
+	return `"` + r.Replace(assignment) + `"`
+}
+
+// unquoteEnvironment reverses quoteEnvironment; unquoted values are returned as is
+func unquoteEnvironment(value string) string {
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return value
+	}
+	r := strings.NewReplacer(`\\`, `\`, `\"`, `"`, "%%", "%")
+	return r.Replace(value[1 : len(value)-1])
+}
+
+// writeOverrides writes the drop-in grouped by section, removing it when empty
+func writeOverrides(unit string, entries []overrideEntry) error {
+	path := overrideFilePath(unit)
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		// Leave the directory alone if other drop-ins live there
+		os.Remove(filepath.Dir(path))
+		return nil
+	}
+
+	var sections []string
+	bySection := make(map[string][]overrideEntry)
+	for _, e := range entries {
+		if _, ok := bySection[e.Section]; !ok {
+			sections = append(sections, e.Section)
+		}
+		bySection[e.Section] = append(bySection[e.Section], e)
+	}
+	// [Unit] conventionally comes first
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i] == "Unit" && sections[j] != "Unit" })
+
+	var content strings.Builder
+	content.WriteString("# Managed by osctl (osctl service override). Manual changes may be overwritten.\n")
+	for _, section := range sections {
+		content.WriteString(fmt.Sprintf("\n[%s]\n", section))
+		for _, e := range bySection[section] {
+			value := e.Value
+			if e.Key == "Environment" {
+				value = quoteEnvironment(value)
+			}
+			content.WriteString(fmt.Sprintf("%s=%s\n", e.Key, value))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sameOverride reports whether two entries assign the same setting.
+// Environment entries are distinguished by variable name.
+func sameOverride(a, b overrideEntry) bool {
+	if a.Key != b.Key || a.Section != b.Section {
+		return false
+	}
+	if a.Key == "Environment" {
+		an, _, _ := strings.Cut(a.Value, "=")
+		bn, _, _ := strings.Cut(b.Value, "=")
+		return an == bn
+	}
+	return true
+}
+
+// setOverrides applies Key=Value assignments, replacing earlier values of the same setting
+func setOverrides(unit string, entries []overrideEntry, assignments []string) ([]overrideEntry, error) {
+	for _, a := range assignments {
+		key, value, ok := strings.Cut(a, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid assignment %q (expected Key=Value)", a)
+		}
+		if err := validateOverride(key, value); err != nil {
+			return nil, err
+		}
+		entry := overrideEntry{Section: overrideSection(unit, key), Key: key, Value: value}
+		replaced := false
+		for i := range entries {
+			if sameOverride(entries[i], entry) {
+				entries[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// unsetOverrides removes settings; "Environment=NAME" removes a single variable
+func unsetOverrides(unit string, entries []overrideEntry, keys []string) ([]overrideEntry, error) {
+	for _, k := range keys {
+		key, name, hasName := strings.Cut(k, "=")
+		if _, ok := overrideKeys[key]; !ok {
+			return nil, fmt.Errorf("unsupported key %q", key)
+		}
+		kept := entries[:0]
+		for _, e := range entries {
+			match := e.Key == key
+			if match && hasName {
+				n, _, _ := strings.Cut(e.Value, "=")
+				match = n == name
+			}
+			if !match {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	return entries, nil
+}
+
+// formatOverrides renders the drop-in entries of a unit
+func formatOverrides(unit string, entries []overrideEntry) string {
+	if len(entries) == 0 {
+		return fmt.Sprintf("No osctl overrides for %s.", unit)
+	}
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Overrides for %s (%s):\n", unit, overrideFilePath(unit)))
+	for _, e := range entries {
+		output.WriteString(fmt.Sprintf("  [%s] %s=%s\n", e.Section, e.Key, e.Value))
+	}
+	return output.String()
+}
+
+// manageServiceOverride shows, sets or unsets settings in the unit's osctl drop-in and reloads systemd
+func manageServiceOverride(service, op string, args []string) string {
+	if strings.ContainsAny(service, ";|&$`\n\r/") {
+		return "Invalid service name: contains forbidden characters"
+	}
+	unit := normalizeUnitName(service)
+	entries, err := readOverrides(unit)
+	if err != nil {
+		return fmt.Sprintf("Failed to read overrides of %s. Error: %v", unit, err)
+	}
+
+	switch op {
+	case "show":
+		return formatOverrides(unit, entries)
+	case "set":
+		entries, err = setOverrides(unit, entries, args)
+	case "unset":
+		entries, err = unsetOverrides(unit, entries, args)
+	default:
+		return fmt.Sprintf("Invalid override operation '%s'. Valid: show, set, unset", op)
+	}
+	if err != nil {
+		return fmt.Sprintf("Failed to %s override of %s. Error: %v", op, unit, err)
+	}
+	if len(args) == 0 {
+		return fmt.Sprintf("Nothing to %s. Usage: osctl service override <service_name> %s Key=Value...", op, op)
+	}
+
+	if err := writeOverrides(unit, entries); err != nil {
+		return fmt.Sprintf("Failed to write overrides of %s. Error: %v", unit, err)
+	}
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Overrides written but systemd could not be reloaded. Error: %v", err)
+	}
+	defer m.Close()
+	if err := m.DaemonReload(); err != nil {
+		return fmt.Sprintf("Overrides written but daemon-reload failed. Error: %v", err)
+	}
+	return formatOverrides(unit, entries) + "\nsystemd reloaded. The changes take effect the next time the unit is restarted."
+}
+
+// runServiceOverride parses "osctl service override" arguments; without an operation the overrides are shown
+func runServiceOverride(args []string) string {
+	if len(args) == 0 {
+		return "Usage: osctl service override <service_name> [show|set Key=Value...|unset Key...]"
+	}
+	if len(args) == 1 {
+		return manageServiceOverride(args[0], "show", nil)
+	}
+	return manageServiceOverride(args[0], args[1], args[2:])
+}
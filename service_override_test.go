@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetOverridesReplacesSameSetting(t *testing.T) {
+	entries, err := setOverrides("nginx.service", nil, []string{"MemoryMax=1G", "Environment=A=1", "Environment=B=2", "StartLimitBurst=5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = setOverrides("nginx.service", entries, []string{"MemoryMax=2G", "Environment=A=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []overrideEntry{
+		{Section: "Service", Key: "MemoryMax", Value: "2G"},
+		{Section: "Service", Key: "Environment", Value: "A=3"},
+		{Section: "Service", Key: "Environment", Value: "B=2"},
+		{Section: "Unit", Key: "StartLimitBurst", Value: "5"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+}
+
+func TestSetOverridesRejectsInvalid(t *testing.T) {
+	for _, a := range []string{"MemoryMax", "Bogus=1", "Restart=sometimes", "MemoryMax=lots", "CPUQuota=1.5", "Environment=1A=x", "Nice=1\n2"} {
+		if _, err := setOverrides("nginx.service", nil, []string{a}); err == nil {
+			t.Errorf("setOverrides(%q) succeeded, want an error", a)
+		}
+	}
+}
+
+func TestUnsetOverrides(t *testing.T) {
+	entries := []overrideEntry{
+		{Section: "Service", Key: "MemoryMax", Value: "1G"},
+		{Section: "Service", Key: "Environment", Value: "A=1"},
+		{Section: "Service", Key: "Environment", Value: "B=2"},
+	}
+	got, err := unsetOverrides("nginx.service", entries, []string{"Environment=A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Value != "B=2" {
+		t.Errorf("unset Environment=A left %+v", got)
+	}
+	got, err = unsetOverrides("nginx.service", got, []string{"Environment", "MemoryMax"})
+	if err != nil || len(got) != 0 {
+		t.Errorf("unset all left %+v (err %v)", got, err)
+	}
+	if _, err := unsetOverrides("nginx.service", nil, []string{"Bogus"}); err == nil {
+		t.Error("unset of an unsupported key succeeded")
+	}
+}
+
+func TestEnvironmentQuoting(t *testing.T) {
+	for _, value := range []string{"A=1", "GREETING=hello world", `PATTERN=a\b "c" 100%`} {
+		quoted := quoteEnvironment(value)
+		if !strings.HasPrefix(quoted, `"`) || !strings.HasSuffix(quoted, `"`) {
+			t.Errorf("quoteEnvironment(%q) = %s, want a quoted string", value, quoted)
+		}
+		if got := unquoteEnvironment(quoted); got != value {
+			t.Errorf("round trip of %q gave %q", value, got)
+		}
+	}
+	if got := quoteEnvironment("MSG=50% done"); got != `"MSG=50%% done"` {
+		t.Errorf("quoteEnvironment escaped %% as %s", got)
+	}
+	if got := unquoteEnvironment("A=1"); got != "A=1" {
+		t.Errorf("unquoted value changed to %q", got)
+	}
+}
+
+func TestWriteAndReadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	saved := systemdUnitDir
+	systemdUnitDir = dir
+	defer func() { systemdUnitDir = saved }()
+
+	entries := []overrideEntry{
+		{Section: "Service", Key: "Environment", Value: "GREETING=hello world"},
+		{Section: "Unit", Key: "StartLimitBurst", Value: "5"},
+	}
+	if err := writeOverrides("app.service", entries); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "app.service.d", overrideFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if strings.Index(content, "[Unit]") > strings.Index(content, "[Service]") {
+		t.Errorf("[Unit] is not written first:\n%s", content)
+	}
+	if !strings.Contains(content, `Environment="GREETING=hello world"`) {
+		t.Errorf("Environment is not quoted:\n%s", content)
+	}
+
+	got, err := readOverrides("app.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []overrideEntry{entries[1], entries[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read back %+v, want %+v", got, want)
+	}
+
+	if err := writeOverrides("app.service", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.service.d")); !os.IsNotExist(err) {
+		t.Errorf("empty overrides left the drop-in directory behind (err %v)", err)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultServiceResourcesLimit = 20
+
+var validServiceResourceSorts = map[string]bool{"memory": true, "cpu": true, "io": true, "pids": true, "pressure": true}
+
+// ServiceResources is the cgroup v2 accounting of one service
+type ServiceResources struct {
+	Unit               string    `json:"unit"`
+	ControlGroup       string    `json:"control_group"`
+	MemoryCurrent      uint64    `json:"memory_bytes"`
+	MemoryPeak         uint64    `json:"memory_peak_bytes,omitempty"`
+	CPUUsageUsec       uint64    `json:"cpu_usage_usec"`
+	CPUPercent         float64   `json:"cpu_percent"`
+	IOReadBytes        uint64    `json:"io_read_bytes"`
+	IOWriteBytes       uint64    `json:"io_write_bytes"`
+	IOReadBytesPerSec  float64   `json:"io_read_bytes_per_sec"`
+	IOWriteBytesPerSec float64   `json:"io_write_bytes_per_sec"`
+	PIDs               uint64    `json:"pids"`
+	CPUPressure        *Pressure `json:"cpu_pressure,omitempty"`
+	MemoryPressure     *Pressure `json:"memory_pressure,omitempty"`
+	IOPressure         *Pressure `json:"io_pressure,omitempty"`
+	// sampledAt is when the counters were read, so rates use the measured interval
+	sampledAt time.Time
+}
+
+// ServiceResourcesReport is the structured output of service resources
+type ServiceResourcesReport struct {
+	Interval string             `json:"interval"`
+	Sort     string             `json:"sort"`
+	Services []ServiceResources `json:"services"`
+}
+
+// readServiceResources reads the accounting files of a service's cgroup.
+// Controllers that are not enabled for the group are left at zero.
+func readServiceResources(unit, controlGroup string) (ServiceResources, error) {
+	r := ServiceResources{Unit: unit, ControlGroup: controlGroup, sampledAt: time.Now()}
+	dir, err := cgroupDir(controlGroup)
+	if err != nil {
+		return r, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return r, err
+	}
+	r.MemoryCurrent, _ = readCgroupUint(dir, "memory.current")
+	r.MemoryPeak, _ = readCgroupUint(dir, "memory.peak")
+	if stat, err := readCgroupKeyed(dir, "cpu.stat"); err == nil {
+		r.CPUUsageUsec = stat["usage_usec"]
+	}
+	r.IOReadBytes, r.IOWriteBytes, _ = readCgroupIOStat(dir)
+	r.PIDs, _ = readCgroupUint(dir, "pids.current")
+	if p, err := readPressureFile(filepath.Join(dir, "cpu.pressure")); err == nil {
+		r.CPUPressure = &p
+	}
+	if p, err := readPressureFile(filepath.Join(dir, "memory.pressure")); err == nil {
+		r.MemoryPressure = &p
+	}
+	if p, err := readPressureFile(filepath.Join(dir, "io.pressure")); err == nil {
+		r.IOPressure = &p
+	}
+	return r, nil
+}
+
+// serviceCgroups returns the control group of one unit, or of all services
+func serviceCgroups(service string) (map[string]string, error) {
+	if service == "" {
+		return listServiceCgroups()
+	}
+	m, err := newUnitManager()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	unit := normalizeUnitName(service)
+	info, err := getUnitInfo(m, unit)
+	if err != nil {
+		return nil, err
+	}
+	if info.ControlGroup == "" {
+		return nil, fmt.Errorf("unit %s has no control group (is it running?)", unit)
+	}
+	return map[string]string{unit: info.ControlGroup}, nil
+}
+
+// readAllServiceResources reads the accounting of every given control group, skipping vanished ones
+func readAllServiceResources(groups map[string]string) map[string]ServiceResources {
+	resources := make(map[string]ServiceResources, len(groups))
+	for unit, cg := range groups {
+		if r, err := readServiceResources(unit, cg); err == nil {
+			resources[unit] = r
+		}
+	}
+	return resources
+}
+
+// collectServiceResources samples service cgroups twice, interval apart, to derive CPU and I/O rates
+func collectServiceResources(service string, interval time.Duration) ([]ServiceResources, error) {
+	groups, err := serviceCgroups(service)
+	if err != nil {
+		return nil, err
+	}
+	prev := readAllServiceResources(groups)
+	time.Sleep(interval)
+	cur := readAllServiceResources(groups)
+
+	services := make([]ServiceResources, 0, len(cur))
+	for unit, r := range cur {
+		if p, ok := prev[unit]; ok {
+			elapsed := r.sampledAt.Sub(p.sampledAt)
+			r.CPUPercent = counterRate(p.CPUUsageUsec, r.CPUUsageUsec, elapsed) / 1e6 * 100
+			r.IOReadBytesPerSec = counterRate(p.IOReadBytes, r.IOReadBytes, elapsed)
+			r.IOWriteBytesPerSec = counterRate(p.IOWriteBytes, r.IOWriteBytes, elapsed)
+		}
+		services = append(services, r)
+	}
+	if service != "" && len(services) == 0 {
+		return nil, fmt.Errorf("cannot read the cgroup of %s", service)
+	}
+	return services, nil
+}
+
+// memoryPressure returns the 10s "some" memory stall percentage of a service
+func (r ServiceResources) memoryPressure() float64 {
+	if r.MemoryPressure == nil {
+		return 0
+	}
+	return r.MemoryPressure.Some.Avg10
+}
+
+// sortServiceResources orders services descending by the given resource
+func sortServiceResources(services []ServiceResources, key string) {
+	value := func(r ServiceResources) float64 {
+		switch key {
+		case "cpu":
+			return r.CPUPercent
+		case "io":
+			return r.IOReadBytesPerSec + r.IOWriteBytesPerSec
+		case "pids":
+			return float64(r.PIDs)
+		case "pressure":
+			return r.memoryPressure()
+		default:
+			return float64(r.MemoryCurrent)
+		}
+	}
+	sort.SliceStable(services, func(i, j int) bool {
+		a, b := value(services[i]), value(services[j])
+		if a != b {
+			return a > b
+		}
+		return services[i].Unit < services[j].Unit
+	})
+}
+
+// getServiceResources renders per-service resource usage, heaviest first
+func getServiceResources(service, intervalValue, sortBy, limitValue string, asJSON bool) string {
+	interval := time.Second
+	if intervalValue != "" {
+		d, err := parseDurationValue(intervalValue)
+		if err != nil || d <= 0 || d > maxRateInterval {
+			return fmt.Sprintf("Invalid interval %q (must be between 0 and %s)", intervalValue, maxRateInterval)
+		}
+		interval = d
+	}
+	if sortBy == "" {
+		sortBy = "memory"
+	}
+	if !validServiceResourceSorts[sortBy] {
+		return fmt.Sprintf("Invalid sort key %q. Valid: memory, cpu, io, pids, pressure", sortBy)
+	}
+	limit := defaultServiceResourcesLimit
+	if limitValue != "" {
+		n, err := strconv.Atoi(limitValue)
+		if err != nil || n < 0 {
+			return fmt.Sprintf("Invalid limit %q (0 shows all services)", limitValue)
+		}
+		limit = n
+	}
+	if strings.ContainsAny(service, ";|&$`\n\r") {
+		return "Invalid service name: contains forbidden characters"
+	}
+
+	services, err := collectServiceResources(service, interval)
+	if err != nil {
+		return fmt.Sprintf("Failed to read service resources. Error: %v", err)
+	}
+	sortServiceResources(services, sortBy)
+	if limit > 0 && len(services) > limit {
+		services = services[:limit]
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(ServiceResourcesReport{Interval: interval.String(), Sort: sortBy, Services: services}, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode service resources. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	io.WriteString(w, "UNIT\tMEMORY\tPEAK\tCPU%\tCPU TIME\tREAD/s\tWRITE/s\tPIDS\tMEM PSI%\n")
+	for _, r := range services {
+		peak := "-"
+		if r.MemoryPeak > 0 {
+			peak = formatBytes(r.MemoryPeak)
+		}
+		psi := "-"
+		if r.MemoryPressure != nil {
+			psi = fmt.Sprintf("%.2f", r.MemoryPressure.Some.Avg10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t%s\t%s\t%s\t%d\t%s\n", r.Unit, formatBytes(r.MemoryCurrent), peak, r.CPUPercent,
+			(time.Duration(r.CPUUsageUsec) * time.Microsecond).Truncate(time.Millisecond),
+			formatBytes(uint64(r.IOReadBytesPerSec)), formatBytes(uint64(r.IOWriteBytesPerSec)), r.PIDs, psi)
+	}
+	w.Flush()
+	output.WriteString(fmt.Sprintf("\nSorted by %s, sampled over %s\n", sortBy, interval))
+	return output.String()
+}
+
+// runServiceResources parses "osctl service resources" arguments
+func runServiceResources(args []string) string {
+	usage := "Usage: osctl service resources [service_name] [--sort memory|cpu|io|pids|pressure] [--limit 20] [--interval 1s] [--json]"
+	service := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		service, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("service resources", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	sortBy := fs.String("sort", "", "sort key")
+	limit := fs.String("limit", "", "number of services to show (0 shows all)")
+	interval := fs.String("interval", "", "sampling interval for CPU and I/O rates")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getServiceResources(service, *interval, *sortBy, *limit, *asJSON)
+}
+
+// serviceResourceCollector exports cgroup accounting of all services at scrape time,
+// so units that stop disappear from /metrics instead of leaving stale series
+type serviceResourceCollector struct {
+	memory   *prometheus.Desc
+	cpu      *prometheus.Desc
+	io       *prometheus.Desc
+	pids     *prometheus.Desc
+	pressure *prometheus.Desc
+}
+
+func newServiceResourceCollector() *serviceResourceCollector {
+	return &serviceResourceCollector{
+		memory:   prometheus.NewDesc("osctl_service_memory_bytes", "Memory charged to a service's cgroup (type=current|peak)", []string{"unit", "type"}, nil),
+		cpu:      prometheus.NewDesc("osctl_service_cpu_seconds_total", "CPU time consumed by a service's cgroup", []string{"unit"}, nil),
+		io:       prometheus.NewDesc("osctl_service_io_bytes_total", "Bytes read and written by a service's cgroup", []string{"unit", "direction"}, nil),
+		pids:     prometheus.NewDesc("osctl_service_pids", "Number of tasks in a service's cgroup", []string{"unit"}, nil),
+		pressure: prometheus.NewDesc("osctl_service_pressure_percent", "Share of time a service's tasks stalled on a resource over the last 10s", []string{"unit", "resource", "kind"}, nil),
+	}
+}
+
+func (c *serviceResourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.memory
+	ch <- c.cpu
+	ch <- c.io
+	ch <- c.pids
+	ch <- c.pressure
+}
+
+func (c *serviceResourceCollector) Collect(ch chan<- prometheus.Metric) {
+	groups, err := listServiceCgroups()
+	if err != nil {
+		return
+	}
+	for unit, r := range readAllServiceResources(groups) {
+		ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(r.MemoryCurrent), unit, "current")
+		if r.MemoryPeak > 0 {
+			ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(r.MemoryPeak), unit, "peak")
+		}
+		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, float64(r.CPUUsageUsec)/1e6, unit)
+		ch <- prometheus.MustNewConstMetric(c.io, prometheus.CounterValue, float64(r.IOReadBytes), unit, "read")
+		ch <- prometheus.MustNewConstMetric(c.io, prometheus.CounterValue, float64(r.IOWriteBytes), unit, "write")
+		ch <- prometheus.MustNewConstMetric(c.pids, prometheus.GaugeValue, float64(r.PIDs), unit)
+		for resource, p := range map[string]*Pressure{"cpu": r.CPUPressure, "memory": r.MemoryPressure, "io": r.IOPressure} {
+			if p == nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.pressure, prometheus.GaugeValue, p.Some.Avg10, unit, resource, "some")
+			if p.Full != nil {
+				ch <- prometheus.MustNewConstMetric(c.pressure, prometheus.GaugeValue, p.Full.Avg10, unit, resource, "full")
+			}
+		}
+	}
+}
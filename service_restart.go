@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVerifyTimeout = 30 * time.Second
+	maxVerifyTimeout     = 10 * time.Minute
+	verifyPollInterval   = 500 * time.Millisecond
+	// verifySettleTime is how long a unit must stay active before it is considered healthy
+	verifySettleTime = 2 * time.Second
+	// maxProbeTimeout bounds a single probe attempt
+	maxProbeTimeout = 5 * time.Second
+)
+
+// builtinConfigTests are the configuration checks used by --config-test auto
+var builtinConfigTests = map[string][]string{
+	"nginx":   {"nginx", "-t"},
+	"apache2": {"apache2ctl", "configtest"},
+	"httpd":   {"apachectl", "configtest"},
+	"sshd":    {"sshd", "-t"},
+	"ssh":     {"sshd", "-t"},
+	"haproxy": {"haproxy", "-c", "-f", "/etc/haproxy/haproxy.cfg"},
+	"named":   {"named-checkconf"},
+	"bind9":   {"named-checkconf"},
+	"postfix": {"postfix", "check"},
+	"squid":   {"squid", "-k", "parse"},
+}
+
+// RestartOptions controls a verified restart
+type RestartOptions struct {
+	Verify      bool
+	ReloadFirst bool
+	ConfigTest  string
+	Probe       string
+	ProbeCmd    string
+	Timeout     time.Duration
+}
+
+// safe reports whether any option beyond a plain restart was requested
+func (o RestartOptions) safe() bool {
+	return o.Verify || o.ReloadFirst || o.ConfigTest != ""
+}
+
+// newRestartOptions validates restart options. Probes imply verification.
+func newRestartOptions(verify, reloadFirst bool, configTest, probe, probeCmd, timeout string) (RestartOptions, error) {
+	opts := RestartOptions{
+		Verify:      verify || probe != "" || probeCmd != "",
+		ReloadFirst: reloadFirst,
+		ConfigTest:  configTest,
+		Probe:       probe,
+		ProbeCmd:    probeCmd,
+		Timeout:     defaultVerifyTimeout,
+	}
+	if timeout != "" {
+		d, err := parseDurationValue(timeout)
+		if err != nil || d <= 0 || d > maxVerifyTimeout {
+			return opts, fmt.Errorf("invalid timeout %q (must be between 0 and %s)", timeout, maxVerifyTimeout)
+		}
+		opts.Timeout = d
+	}
+	if probeCmd != "" && strings.TrimSpace(probeCmd) == "" {
+		return opts, fmt.Errorf("invalid probe command: empty")
+	}
+	if probe != "" && !strings.HasPrefix(probe, "tcp://") && !strings.HasPrefix(probe, "http://") && !strings.HasPrefix(probe, "https://") {
+		return opts, fmt.Errorf("invalid probe %q (use tcp://host:port or http(s)://url)", probe)
+	}
+	return opts, nil
+}
+
+// configTestCommand resolves --config-test to a command; "auto" picks a built-in check for the unit
+func configTestCommand(unit, configTest string) ([]string, error) {
+	if configTest != "auto" {
+		return strings.Fields(configTest), nil
+	}
+	name := strings.TrimSuffix(filepath.Base(unit), ".service")
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	cmd, ok := builtinConfigTests[name]
+	if !ok {
+		return nil, fmt.Errorf("no built-in configuration test for %s", unit)
+	}
+	return cmd, nil
+}
+
+// runCommandTimeout runs a command and kills it once timeout has passed.
+// WaitDelay stops waiting for output held open by its children.
+func runCommandTimeout(timeout time.Duration, args []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out, fmt.Errorf("timed out after %s", timeout)
+	}
+	return out, err
+}
+
+// runProbe checks a TCP or HTTP endpoint, or runs a command, once
+func runProbe(probe, probeCmd string, timeout time.Duration) error {
+	if probeCmd != "" {
+		args := strings.Fields(probeCmd)
+		if len(args) == 0 {
+			return fmt.Errorf("probe command is empty")
+		}
+		out, err := runCommandTimeout(timeout, args)
+		if err != nil {
+			return fmt.Errorf("probe command failed: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	switch {
+	case strings.HasPrefix(probe, "tcp://"):
+		conn, err := net.DialTimeout("tcp", strings.TrimPrefix(probe, "tcp://"), timeout)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	case probe != "":
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(probe)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %s", probe, resp.Status)
+		}
+	}
+	return nil
+}
+
+// verifyUnit waits until the unit is active and stays active without
+// restarting, then probes it until the probe succeeds or the timeout expires
+func verifyUnit(m unitManager, unit string, opts RestartOptions) (time.Duration, error) {
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+
+	var info UnitInfo
+	var activeAt time.Time
+	for {
+		var err error
+		info, err = getUnitInfo(m, unit)
+		if err != nil {
+			return time.Since(start), err
+		}
+		switch info.ActiveState {
+		case "active":
+			if activeAt.IsZero() {
+				activeAt = time.Now()
+			}
+		case "failed":
+			return time.Since(start), fmt.Errorf("unit entered failed state (result: %s)", info.Result)
+		default:
+			activeAt = time.Time{}
+		}
+		if !activeAt.IsZero() && time.Since(activeAt) >= verifySettleTime {
+			break
+		}
+		if time.Now().After(deadline) {
+			return time.Since(start), fmt.Errorf("unit did not stay active within %s (state: %s/%s)", opts.Timeout, info.ActiveState, info.SubState)
+		}
+		time.Sleep(verifyPollInterval)
+	}
+
+	if opts.Probe == "" && opts.ProbeCmd == "" {
+		return time.Since(start), nil
+	}
+	for {
+		timeout := min(time.Until(deadline), maxProbeTimeout)
+		if timeout <= 0 {
+			timeout = time.Second
+		}
+		err := runProbe(opts.Probe, opts.ProbeCmd, timeout)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return time.Since(start), fmt.Errorf("probe did not succeed within %s: %v", opts.Timeout, err)
+		}
+		time.Sleep(verifyPollInterval)
+	}
+
+	// The probe must not have hit an instance that crashed and was restarted meanwhile
+	after, err := getUnitInfo(m, unit)
+	if err != nil {
+		return time.Since(start), err
+	}
+	if after.ActiveState != "active" || after.NRestarts != info.NRestarts {
+		return time.Since(start), fmt.Errorf("unit restarted or left the active state during verification (state: %s/%s)", after.ActiveState, after.SubState)
+	}
+	return time.Since(start), nil
+}
+
+// restartServiceSafely restarts (or reloads) a unit, optionally refusing on a
+// failed configuration test and verifying that it comes back healthy. When a
+// reload passes but verification fails, it falls back to a full restart.
+func restartServiceSafely(service string, opts RestartOptions) string {
+	if strings.ContainsAny(service, ";|&$`\n\r") {
+		return "Invalid service name: contains forbidden characters"
+	}
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+	unit := normalizeUnitName(service)
+
+	var output strings.Builder
+	if opts.ConfigTest != "" {
+		args, err := configTestCommand(unit, opts.ConfigTest)
+		if err != nil {
+			return fmt.Sprintf("Refusing to restart service %s. Error: %v", service, err)
+		}
+		if len(args) == 0 {
+			return fmt.Sprintf("Refusing to restart service %s. Error: empty configuration test", service)
+		}
+		out, err := runCommandTimeout(opts.Timeout, args)
+		if err != nil {
+			msg := fmt.Sprintf("Refusing to restart service %s: configuration test '%s' failed. Error: %v", service, strings.Join(args, " "), err)
+			if detail := strings.TrimSpace(string(out)); detail != "" {
+				msg += "\n" + detail
+			}
+			return msg
+		}
+		output.WriteString(fmt.Sprintf("Configuration test '%s' passed.\n", strings.Join(args, " ")))
+	}
+
+	action := "restart"
+	if opts.ReloadFirst {
+		if _, err := m.RunUnitJob("reload", unit); err == nil {
+			action = "reload"
+		} else {
+			output.WriteString(fmt.Sprintf("Reload failed (%v), restarting instead.\n", err))
+		}
+	}
+	if action == "restart" {
+		if _, err := m.RunUnitJob("restart", unit); err != nil {
+			return output.String() + formatVerifyFailure(service, "restart", err, unit)
+		}
+	}
+	if !opts.Verify {
+		return output.String() + fmt.Sprintf("Service %s %s successfully.", service, serviceActionPastTense[action])
+	}
+
+	took, err := verifyUnit(m, unit, opts)
+	if err != nil && action == "reload" {
+		output.WriteString(fmt.Sprintf("Verification after reload failed (%v), restarting instead.\n", err))
+		action = "restart"
+		if _, err := m.RunUnitJob("restart", unit); err != nil {
+			return output.String() + formatVerifyFailure(service, "restart", err, unit)
+		}
+		took, err = verifyUnit(m, unit, opts)
+	}
+	if err != nil {
+		return output.String() + formatVerifyFailure(service, "verify", err, unit)
+	}
+	output.WriteString(fmt.Sprintf("Service %s %s and verified healthy in %s.", service, serviceActionPastTense[action], took.Truncate(100*time.Millisecond)))
+	return output.String()
+}
+
+// formatVerifyFailure reports a failed restart together with the unit's journal tail
+func formatVerifyFailure(service, step string, err error, unit string) string {
+	msg := fmt.Sprintf("Failed to %s service %s. Error: %v", step, service, err)
+	if logs, jerr := getUnitJournal(unit, defaultServiceLogLines); jerr == nil && len(logs) > 0 {
+		msg += "\n\nRecent logs:\n  " + strings.Join(logs, "\n  ")
+	}
+	return msg
+}
+
+// runServiceRestart parses "osctl service restart" arguments
+func runServiceRestart(args []string) string {
+	usage := "Usage: osctl service restart <service_name> [--verify] [--probe tcp://host:port|http://url] [--probe-cmd CMD] [--reload-first] [--config-test auto|CMD] [--timeout 30s]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+	fs := flag.NewFlagSet("service restart", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	verify := fs.Bool("verify", false, "wait for the unit to become active")
+	probe := fs.String("probe", "", "TCP or HTTP endpoint to probe")
+	probeCmd := fs.String("probe-cmd", "", "command to probe")
+	reloadFirst := fs.Bool("reload-first", false, "try reload before restart")
+	configTest := fs.String("config-test", "", "configuration test command, or auto")
+	timeout := fs.String("timeout", "", "verification timeout")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newRestartOptions(*verify, *reloadFirst, *configTest, *probe, *probeCmd, *timeout)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	if !opts.safe() {
+		return manageService("restart", args[0])
+	}
+	return restartServiceSafely(args[0], opts)
+}
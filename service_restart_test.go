@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunProbeCommandTimesOut(t *testing.T) {
+	start := time.Now()
+	err := runProbe("", "sleep 10", 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("probe returned after %s", elapsed)
+	}
+}
+
+func TestRunProbeCommand(t *testing.T) {
+	if err := runProbe("", "true", time.Second); err != nil {
+		t.Errorf("true failed: %v", err)
+	}
+	if err := runProbe("", "false", time.Second); err == nil {
+		t.Error("false succeeded")
+	}
+}
+
+func TestNewRestartOptionsRejectsBlankProbeCommand(t *testing.T) {
+	if _, err := newRestartOptions(false, false, "", "", " ", ""); err == nil {
+		t.Error("a blank probe command was accepted")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/net"
+)
+
+const (
+	defaultServiceLogLines = 20
+	maxServiceLogLines     = 1000
+)
+
+// unitDependencyProperties are the dependency properties shown by service show
+var unitDependencyProperties = []string{"Wants", "Requires", "After", "Before", "WantedBy", "RequiredBy"}
+
+// ServicePort is a socket a unit's processes are listening on
+type ServicePort struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	PID      int32  `json:"pid"`
+}
+
+// ServiceDetails is the detail view of a single unit
+type ServiceDetails struct {
+	UnitInfo
+	UptimeSeconds int64               `json:"uptime_seconds,omitempty"`
+	PIDs          []int32             `json:"pids,omitempty"`
+	Dependencies  map[string][]string `json:"dependencies,omitempty"`
+	Ports         []ServicePort       `json:"ports,omitempty"`
+	Logs          []string            `json:"logs,omitempty"`
+}
+
+// parseServiceLogLines validates the number of journal lines to show
+func parseServiceLogLines(value string) (int, error) {
+	if value == "" {
+		return defaultServiceLogLines, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > maxServiceLogLines {
+		return 0, fmt.Errorf("invalid lines %q (must be 0-%d)", value, maxServiceLogLines)
+	}
+	return n, nil
+}
+
+// collectServiceDetails gathers unit state, cgroup accounting, dependencies,
+// listening ports and recent journal lines for a unit
+func collectServiceDetails(m unitManager, unit string, lines int) (ServiceDetails, error) {
+	info, err := getUnitInfo(m, unit)
+	if err != nil {
+		return ServiceDetails{}, err
+	}
+	if info.LoadState == "not-found" {
+		return ServiceDetails{}, fmt.Errorf("unit %s not found", unit)
+	}
+	details := ServiceDetails{UnitInfo: info}
+	if info.ActiveState == "active" && !info.ActiveSince.IsZero() {
+		details.UptimeSeconds = int64(time.Since(info.ActiveSince).Seconds())
+	}
+
+	// Prefer live cgroup accounting over the values systemd last reported
+	if dir, err := cgroupDir(info.ControlGroup); err == nil {
+		if v, err := readCgroupUint(dir, "memory.current"); err == nil {
+			details.MemoryCurrent = v
+		}
+		if stat, err := readCgroupKeyed(dir, "cpu.stat"); err == nil {
+			if usec, ok := stat["usage_usec"]; ok {
+				details.CPUUsageNSec = usec * 1000
+			}
+		}
+		if pids, err := cgroupPIDs(dir); err == nil {
+			details.PIDs = pids
+		}
+	}
+	if len(details.PIDs) == 0 && info.MainPID != 0 {
+		details.PIDs = []int32{int32(info.MainPID)}
+	}
+
+	if deps, err := m.UnitProperties(unit, "Unit", unitDependencyProperties); err == nil {
+		details.Dependencies = make(map[string][]string)
+		for _, name := range unitDependencyProperties {
+			if units := strings.Fields(deps[name]); len(units) > 0 {
+				details.Dependencies[name] = units
+			}
+		}
+	}
+
+	details.Ports = listeningPorts(details.PIDs)
+	if lines > 0 {
+		details.Logs, _ = getUnitJournal(unit, lines)
+	}
+	return details, nil
+}
+
+// listeningPorts returns the listening TCP sockets and bound UDP sockets owned by the given processes
+func listeningPorts(pids []int32) []ServicePort {
+	if len(pids) == 0 {
+		return nil
+	}
+	owned := make(map[int32]bool, len(pids))
+	for _, pid := range pids {
+		owned[pid] = true
+	}
+	conns, err := net.Connections("inet")
+	if err != nil {
+		return nil
+	}
+
+	var ports []ServicePort
+	seen := make(map[string]bool)
+	for _, c := range conns {
+		if !owned[c.Pid] {
+			continue
+		}
+		protocol := "tcp"
+		if c.Type == syscall.SOCK_DGRAM {
+			if c.Raddr.IP != "" && c.Raddr.Port != 0 {
+				continue
+			}
+			protocol = "udp"
+		} else if c.Status != "LISTEN" {
+			continue
+		}
+		if c.Family == syscall.AF_INET6 {
+			protocol += "6"
+		}
+		key := fmt.Sprintf("%s/%s/%d", protocol, c.Laddr.IP, c.Laddr.Port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, ServicePort{Protocol: protocol, Address: c.Laddr.IP, Port: c.Laddr.Port, PID: c.Pid})
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports
+}
+
+// getUnitJournal returns the last n journal lines of a unit
+func getUnitJournal(unit string, n int) ([]string, error) {
+	cmd := exec.Command("journalctl", "-u", unit, "-n", strconv.Itoa(n), "--no-pager", "-o", "short-iso", "-q")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	text := strings.TrimRight(string(out), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// getServiceDetails renders the detail view of a unit
+func getServiceDetails(service string, lines int, asJSON bool) string {
+	if strings.ContainsAny(service, ";|&$`\n\r") {
+		return "Invalid service name: contains forbidden characters"
+	}
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+
+	details, err := collectServiceDetails(m, normalizeUnitName(service), lines)
+	if err != nil {
+		return fmt.Sprintf("Failed to show service %s. Error: %v", service, err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(details, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode service details. Error: %v", err)
+		}
+		return string(data)
+	}
+	return formatServiceDetails(details)
+}
+
+// formatServiceDetails renders service details as text
+func formatServiceDetails(d ServiceDetails) string {
+	var output strings.Builder
+	output.WriteString(formatUnitStatus(d.UnitInfo))
+	if len(d.PIDs) > 1 {
+		output.WriteString(fmt.Sprintf("  Tasks: %d processes\n", len(d.PIDs)))
+	}
+	if d.ControlGroup != "" {
+		output.WriteString(fmt.Sprintf("  CGroup: %s\n", d.ControlGroup))
+	}
+	if d.FragmentPath != "" {
+		output.WriteString(fmt.Sprintf("  Unit file: %s\n", d.FragmentPath))
+	}
+	for _, path := range d.DropInPaths {
+		output.WriteString(fmt.Sprintf("  Drop-in: %s\n", path))
+	}
+
+	if len(d.Dependencies) > 0 {
+		output.WriteString("\nDependencies:\n")
+		for _, name := range unitDependencyProperties {
+			if units := d.Dependencies[name]; len(units) > 0 {
+				output.WriteString(fmt.Sprintf("  %-11s %s\n", name+":", strings.Join(units, " ")))
+			}
+		}
+	}
+
+	if len(d.Ports) > 0 {
+		output.WriteString("\nListening:\n")
+		for _, p := range d.Ports {
+			output.WriteString(fmt.Sprintf("  %-5s %s:%d (pid %d)\n", p.Protocol, p.Address, p.Port, p.PID))
+		}
+	}
+
+	if len(d.Logs) > 0 {
+		output.WriteString("\nRecent logs:\n")
+		for _, line := range d.Logs {
+			output.WriteString("  " + line + "\n")
+		}
+	}
+	return output.String()
+}
+
+// runServiceShow parses "osctl service show" arguments
+func runServiceShow(args []string) string {
+	usage := "Usage: osctl service show <service_name> [--lines 20] [--json]"
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+	fs := flag.NewFlagSet("service show", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	lines := fs.String("lines", "", "number of journal lines to show")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	n, err := parseServiceLogLines(*lines)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getServiceDetails(args[0], n, *asJSON)
+}
@@ -1,27 +1,49 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"slices"
+	"sort"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/vishvananda/netlink"
 )
 
+// serviceActionPastTense is used to report successful service actions
+var serviceActionPastTense = map[string]string{
+	"start":   "started",
+	"stop":    "stopped",
+	"restart": "restarted",
+	"reload":  "reloaded",
+	"enable":  "enabled",
+	"disable": "disabled",
+	"mask":    "masked",
+	"unmask":  "unmasked",
+}
+
 func manageService(action, service string) string {
 	// Validate action
 	validActions := map[string]bool{
 		"start":   true,
 		"stop":    true,
 		"restart": true,
+		"reload":  true,
 		"status":  true,
 		"enable":  true,
 		"disable": true,
+		"mask":    true,
+		"unmask":  true,
 	}
 	if !validActions[action] {
-		return fmt.Sprintf("Invalid action '%s'. Valid actions: start, stop, restart, status, enable, disable", action)
+		return fmt.Sprintf("Invalid action '%s'. Valid actions: start, stop, restart, reload, status, enable, disable, mask, unmask", action)
 	}
 
 	// Basic validation for service name (prevent command injection)
@@ -29,12 +51,60 @@ func manageService(action, service string) string {
 		return "Invalid service name: contains forbidden characters"
 	}
 
-	cmd := exec.Command("systemctl", action, service)
-	err := cmd.Run()
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+	unit := normalizeUnitName(service)
+
+	switch action {
+	case "status":
+		info, err := getUnitInfo(m, unit)
+		if err != nil {
+			return fmt.Sprintf("Failed to get status of service %s. Error: %v", service, err)
+		}
+		return formatUnitStatus(info)
+	case "enable", "disable", "mask", "unmask":
+		err = m.ChangeUnitFile(action, unit)
+	default:
+		_, err = m.RunUnitJob(action, unit)
+	}
 	if err != nil {
 		return fmt.Sprintf("Failed to %s service %s. Error: %v", action, service, err)
 	}
-	return fmt.Sprintf("Service %s %sed successfully.", service, action)
+	return fmt.Sprintf("Service %s %s successfully.", service, serviceActionPastTense[action])
+}
+
+// formatUnitStatus renders a short status summary of a unit
+func formatUnitStatus(info UnitInfo) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("%s - %s\n", info.Name, info.Description))
+	output.WriteString(fmt.Sprintf("  State: %s (%s)", info.ActiveState, info.SubState))
+	if !info.ActiveSince.IsZero() && info.ActiveState == "active" {
+		output.WriteString(fmt.Sprintf(" since %s (%s ago)", info.ActiveSince.Format("2006-01-02 15:04:05"),
+			time.Since(info.ActiveSince).Truncate(time.Second)))
+	}
+	output.WriteString("\n")
+	output.WriteString(fmt.Sprintf("  Loaded: %s", info.LoadState))
+	if info.UnitFileState != "" {
+		output.WriteString(fmt.Sprintf(" (%s)", info.UnitFileState))
+	}
+	output.WriteString("\n")
+	if info.MainPID != 0 {
+		output.WriteString(fmt.Sprintf("  Main PID: %d\n", info.MainPID))
+	}
+	if info.MemoryCurrent != 0 {
+		output.WriteString(fmt.Sprintf("  Memory: %s\n", formatBytes(info.MemoryCurrent)))
+	}
+	if info.CPUUsageNSec != 0 {
+		output.WriteString(fmt.Sprintf("  CPU: %s\n", time.Duration(info.CPUUsageNSec).Truncate(time.Millisecond)))
+	}
+	output.WriteString(fmt.Sprintf("  Restarts: %d\n", info.NRestarts))
+	if info.Result != "" && info.Result != "success" {
+		output.WriteString(fmt.Sprintf("  Result: %s\n", info.Result))
+	}
+	return output.String()
 }
 
 func shutdownSystem() string {
@@ -145,11 +215,213 @@ func getFirewalldRules() string {
 	return string(out)
 }
 
-func getServiceStatuses() string {
-	cmd := exec.Command("systemctl", "list-units", "--type=service", "--state=running")
-	out, err := cmd.CombinedOutput()
+// ServiceListOptions controls the unit listing
+type ServiceListOptions struct {
+	State   string
+	Type    string
+	Pattern string
+	Enabled bool
+	Sort    string
+	JSON    bool
+}
+
+var (
+	validServiceStates = map[string]bool{"running": true, "active": true, "failed": true, "inactive": true, "all": true}
+	validUnitTypes     = map[string]bool{"service": true, "timer": true, "socket": true, "mount": true, "target": true, "path": true, "slice": true, "scope": true, "all": true}
+	validServiceSorts  = map[string]bool{"name": true, "memory": true, "restarts": true, "state": true}
+)
+
+// ServiceListReport is the structured unit listing
+type ServiceListReport struct {
+	State   string     `json:"state"`
+	Type    string     `json:"type"`
+	Pattern string     `json:"pattern,omitempty"`
+	Units   []UnitInfo `json:"units"`
+}
+
+// newServiceListOptions validates unit listing options shared by the CLI and API
+func newServiceListOptions(state, unitType, pattern, sortBy string, enabled, asJSON bool) (ServiceListOptions, error) {
+	opts := ServiceListOptions{State: "running", Type: "service", Pattern: pattern, Enabled: enabled, Sort: "name", JSON: asJSON}
+	if unitType != "" {
+		if !validUnitTypes[unitType] {
+			return opts, fmt.Errorf("invalid type %q. Valid: service, timer, socket, mount, target, path, slice, scope, all", unitType)
+		}
+		opts.Type = unitType
+	}
+	// Only services have a "running" sub-state; other unit types default to active
+	if opts.Type != "service" {
+		opts.State = "active"
+	}
+	if state != "" {
+		if !validServiceStates[state] {
+			return opts, fmt.Errorf("invalid state %q. Valid: running, active, failed, inactive, all", state)
+		}
+		opts.State = state
+	}
+	if sortBy != "" {
+		if !validServiceSorts[sortBy] {
+			return opts, fmt.Errorf("invalid sort key %q. Valid: name, memory, restarts, state", sortBy)
+		}
+		opts.Sort = sortBy
+	}
+	if strings.ContainsAny(pattern, ";|&$`\n\r ") {
+		return opts, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	return opts, nil
+}
+
+// listUnits returns the units matching the options with their properties
+func listUnits(m unitManager, opts ServiceListOptions) ([]UnitInfo, error) {
+	var states []string
+	if opts.State != "all" {
+		states = []string{opts.State}
+	}
+	entries, err := m.ListUnits(states, []string{unitListPattern(opts.Pattern, opts.Type)})
+	if err != nil {
+		return nil, err
+	}
+
+	entries = slices.DeleteFunc(entries, func(e UnitListEntry) bool {
+		return opts.Type != "all" && !strings.HasSuffix(e.Name, "."+opts.Type)
+	})
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	infos, err := getUnitInfos(m, names)
+	if err != nil {
+		return nil, err
+	}
+
+	units := make([]UnitInfo, 0, len(entries))
+	for _, e := range entries {
+		info, ok := infos[e.Name]
+		if !ok {
+			// Fall back to the listing columns if the unit vanished meanwhile
+			info = UnitInfo{Name: e.Name, Description: e.Description, LoadState: e.LoadState, ActiveState: e.ActiveState, SubState: e.SubState}
+		}
+		if opts.Enabled && !strings.HasPrefix(info.UnitFileState, "enabled") {
+			continue
+		}
+		units = append(units, info)
+	}
+	sortUnits(units, opts.Sort)
+	return units, nil
+}
+
+// unitListPattern restricts the name glob to one unit type so that systemd
+// filters the listing, e.g. "nginx*" with type service becomes "nginx*.service"
+func unitListPattern(pattern, unitType string) string {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if unitType == "all" || strings.HasSuffix(pattern, "."+unitType) {
+		return pattern
+	}
+	return pattern + "." + unitType
+}
+
+// sortUnits orders units by name, or descending by memory or restart count
+func sortUnits(units []UnitInfo, key string) {
+	sort.SliceStable(units, func(i, j int) bool {
+		a, b := units[i], units[j]
+		switch key {
+		case "memory":
+			if a.MemoryCurrent != b.MemoryCurrent {
+				return a.MemoryCurrent > b.MemoryCurrent
+			}
+		case "restarts":
+			if a.NRestarts != b.NRestarts {
+				return a.NRestarts > b.NRestarts
+			}
+		case "state":
+			if a.ActiveState != b.ActiveState {
+				return a.ActiveState < b.ActiveState
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+func getServiceStatuses(opts ServiceListOptions) string {
+	m, err := newUnitManager()
 	if err != nil {
 		return fmt.Sprintf("Failed to get service statuses. Error: %v", err)
 	}
-	return string(out)
+	defer m.Close()
+
+	units, err := listUnits(m, opts)
+	if err != nil {
+		return fmt.Sprintf("Failed to get service statuses. Error: %v", err)
+	}
+
+	if opts.JSON {
+		data, err := json.MarshalIndent(ServiceListReport{State: opts.State, Type: opts.Type, Pattern: opts.Pattern, Units: units}, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode service statuses. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UNIT\tLOAD\tACTIVE\tSUB\tENABLED\tMEMORY\tRESTARTS\tDESCRIPTION")
+	for _, u := range units {
+		memory := "-"
+		if u.MemoryCurrent != 0 {
+			memory = formatBytes(u.MemoryCurrent)
+		}
+		enabled := u.UnitFileState
+		if enabled == "" {
+			enabled = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", u.Name, u.LoadState, u.ActiveState, u.SubState, enabled, memory, u.NRestarts, u.Description)
+	}
+	w.Flush()
+	kind := "units"
+	if opts.Type != "all" {
+		kind = opts.Type + " units"
+	}
+	output.WriteString(fmt.Sprintf("\n%d %s (state: %s)\n", len(units), kind, opts.State))
+	return output.String()
+}
+
+// runServicesCommand parses "osctl services" arguments
+func runServicesCommand(args []string) string {
+	usage := "Usage: osctl services [--state running|active|failed|inactive|all] [--type service|timer|socket|mount|...|all] [--pattern 'nginx*'] [--enabled] [--sort name|memory|restarts|state] [--json]"
+	fs := flag.NewFlagSet("services", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	state := fs.String("state", "", "unit state filter")
+	unitType := fs.String("type", "", "unit type filter")
+	pattern := fs.String("pattern", "", "unit name glob")
+	enabled := fs.Bool("enabled", false, "only enabled units")
+	sortBy := fs.String("sort", "", "sort key")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newServiceListOptions(*state, *unitType, *pattern, *sortBy, *enabled, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getServiceStatuses(opts)
+}
+
+// listFailedUnits returns the names of all units in the failed state
+func listFailedUnits() ([]string, error) {
+	m, err := newUnitManager()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	units, err := m.ListUnits([]string{"failed"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(units))
+	for _, u := range units {
+		names = append(names, u.Name)
+	}
+	return names, nil
 }
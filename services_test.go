@@ -0,0 +1,65 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnitListPattern(t *testing.T) {
+	for _, c := range []struct{ pattern, unitType, want string }{
+		{"", "service", "*.service"},
+		{"nginx*", "service", "nginx*.service"},
+		{"nginx.service", "service", "nginx.service"},
+		{"", "all", "*"},
+		{"backup*", "all", "backup*"},
+	} {
+		if got := unitListPattern(c.pattern, c.unitType); got != c.want {
+			t.Errorf("unitListPattern(%q, %q) = %q, want %q", c.pattern, c.unitType, got, c.want)
+		}
+	}
+}
+
+func TestListUnitsBatchesProperties(t *testing.T) {
+	m := &fakeUnitManager{
+		units: []UnitListEntry{
+			{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			{Name: "nginx-exporter.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Description: "exporter"},
+			{Name: "nginx.socket", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+		props: map[string]map[string]string{
+			"nginx.service/Unit":    {"Id": "nginx.service", "LoadState": "loaded", "ActiveState": "active", "UnitFileState": "enabled"},
+			"nginx.service/Service": {"MemoryCurrent": "2048"},
+			"nginx.socket/Unit":     {"Id": "nginx.socket", "LoadState": "loaded"},
+		},
+	}
+	opts, err := newServiceListOptions("", "", "nginx*", "memory", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	units, err := listUnits(m, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.patterns) != 1 || !slices.Equal(m.patterns[0], []string{"nginx*.service"}) {
+		t.Errorf("listed with patterns %q, want the type in the pattern", m.patterns)
+	}
+	if m.batches != 2 {
+		t.Errorf("got %d property lookups, want one per interface", m.batches)
+	}
+	if len(units) != 2 || units[0].Name != "nginx.service" || units[0].MemoryCurrent != 2048 {
+		t.Fatalf("got %+v", units)
+	}
+	// A unit whose properties cannot be read keeps its listing columns
+	if units[1].Name != "nginx-exporter.service" || units[1].Description != "exporter" {
+		t.Errorf("second unit = %+v", units[1])
+	}
+
+	opts.Enabled = true
+	units, err = listUnits(m, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 1 || units[0].Name != "nginx.service" {
+		t.Errorf("--enabled kept %+v", units)
+	}
+}
@@ -3,17 +3,13 @@ package main
 import (
 	"fmt"
 	"os/exec"
-	"sort"
 	"strings"
 	"time"
 
-	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/host"
-	"github.com/shirou/gopsutil/load"
 	"github.com/shirou/gopsutil/mem"
 	"github.com/shirou/gopsutil/net"
-	"github.com/shirou/gopsutil/process"
 )
 
 func getRamUsage() string {
@@ -44,23 +40,6 @@ func getDiskUsage() string {
 		d.Total/1024/1024/1024, d.Used/1024/1024/1024, d.Free/1024/1024/1024)
 }
 
-func getCpuUsage() string {
-	cpuPercentages, err := cpu.Percent(0, false)
-	if err != nil {
-		return fmt.Sprintf("Error getting CPU usage: %v", err)
-	}
-	cpuUsage.Set(cpuPercentages[0])
-	return fmt.Sprintf("CPU Usage: %.2f%%", cpuPercentages[0])
-}
-
-func getLoadAverage() string {
-	avg, err := load.Avg()
-	if err != nil {
-		return fmt.Sprintf("Error getting load average: %v", err)
-	}
-	return fmt.Sprintf("Load Average: 1 min: %.2f, 5 min: %.2f, 15 min: %.2f", avg.Load1, avg.Load5, avg.Load15)
-}
-
 func getNetworkStats() string {
 	stats, err := net.IOCounters(true)
 	if err != nil {
@@ -169,47 +148,3 @@ func getOSInfo() string {
 	}
 	return fmt.Sprintf("OS: %s %s\nKernel: %s", info.Platform, info.PlatformVersion, info.KernelVersion)
 }
-
-func getTopProcesses() string {
-	procs, err := process.Processes()
-	if err != nil {
-		return fmt.Sprintf("Error getting processes: %v", err)
-	}
-
-	type procInfo struct {
-		PID  int32
-		Name string
-		CPU  float64
-		Mem  float32
-	}
-
-	var procList []procInfo
-	for _, p := range procs {
-		name, err := p.Name()
-		if err != nil {
-			continue
-		}
-		cpu, err := p.CPUPercent()
-		if err != nil {
-			continue
-		}
-		mem, err := p.MemoryPercent()
-		if err != nil {
-			continue
-		}
-		procList = append(procList, procInfo{PID: p.Pid, Name: name, CPU: cpu, Mem: mem})
-	}
-
-	sort.Slice(procList, func(i, j int) bool {
-		return procList[i].CPU > procList[j].CPU
-	})
-
-	output := "PID\tName\t\tCPU%\tMemory%\n"
-	for i, p := range procList {
-		if i >= 10 {
-			break
-		}
-		output += fmt.Sprintf("%d\t%s\t\t%.2f\t%.2f\n", p.PID, p.Name, p.CPU, p.Mem)
-	}
-	return output
-}
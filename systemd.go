@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	sdbus "github.com/coreos/go-systemd/v22/dbus"
+)
+
+const defaultSystemdTimeout = 90 * time.Second
+
+// unitSuffixes are the unit types systemd knows; names without one are services
+var unitSuffixes = []string{
+	".service", ".socket", ".timer", ".mount", ".automount", ".swap",
+	".target", ".path", ".slice", ".scope", ".device",
+}
+
+// unitInfoProperties and serviceInfoProperties are read for every UnitInfo
+var (
+	unitInfoProperties = []string{
+		"Id", "Description", "LoadState", "ActiveState", "SubState", "UnitFileState",
+		"FragmentPath", "DropInPaths", "ActiveEnterTimestamp", "StateChangeTimestamp",
+	}
+	serviceInfoProperties = []string{
+		"MainPID", "NRestarts", "MemoryCurrent", "CPUUsageNSec", "ControlGroup", "Result",
+	}
+)
+
+// UnitInfo holds the structured properties of a systemd unit
+type UnitInfo struct {
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	LoadState      string    `json:"load_state"`
+	ActiveState    string    `json:"active_state"`
+	SubState       string    `json:"sub_state"`
+	UnitFileState  string    `json:"unit_file_state,omitempty"`
+	MainPID        uint32    `json:"main_pid,omitempty"`
+	NRestarts      uint32    `json:"restarts"`
+	MemoryCurrent  uint64    `json:"memory_bytes,omitempty"`
+	CPUUsageNSec   uint64    `json:"cpu_usage_nsec,omitempty"`
+	ControlGroup   string    `json:"control_group,omitempty"`
+	Result         string    `json:"result,omitempty"`
+	FragmentPath   string    `json:"fragment_path,omitempty"`
+	DropInPaths    []string  `json:"drop_in_paths,omitempty"`
+	ActiveSince    time.Time `json:"active_since,omitempty"`
+	StateChangedAt time.Time `json:"state_changed_at,omitempty"`
+}
+
+// UnitListEntry is one row of a unit listing
+type UnitListEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	LoadState   string `json:"load_state"`
+	ActiveState string `json:"active_state"`
+	SubState    string `json:"sub_state"`
+}
+
+// unitManager performs unit operations. It is implemented natively over
+// D-Bus and, as a fallback, by running systemctl.
+type unitManager interface {
+	// Backend names the implementation ("dbus" or "systemctl")
+	Backend() string
+	// RunUnitJob runs start, stop, restart, reload, try-restart or
+	// reload-or-restart and waits for the job to finish, returning its result
+	RunUnitJob(action, unit string) (string, error)
+	// ChangeUnitFile enables, disables, masks or unmasks a unit file and reloads the daemon
+	ChangeUnitFile(action, unit string) error
+	ResetFailedUnit(unit string) error
+	DaemonReload() error
+	// UnitProperties reads the named properties of the unit's generic
+	// ("Unit") or type-specific (e.g. "Service") interface as strings
+	UnitProperties(unit, iface string, names []string) (map[string]string, error)
+	// UnitPropertiesBatch reads the same properties of several units at once,
+	// keyed by unit name. Units whose properties cannot be read are left out.
+	UnitPropertiesBatch(units []string, iface string, names []string) (map[string]map[string]string, error)
+	// ManagerProperties reads properties of the service manager itself, e.g. boot timestamps
+	ManagerProperties(names []string) (map[string]string, error)
+	ListUnits(states, patterns []string) ([]UnitListEntry, error)
+	Close()
+}
+
+// newUnitManager connects to systemd over D-Bus, falling back to systemctl
+// when the bus is unavailable. OSCTL_SYSTEMD_BACKEND=dbus|systemctl forces a
+// backend; the system bus address honours DBUS_SYSTEM_BUS_ADDRESS.
+func newUnitManager() (unitManager, error) {
+	backend := os.Getenv("OSCTL_SYSTEMD_BACKEND")
+	if backend != "" && backend != "dbus" && backend != "systemctl" {
+		return nil, fmt.Errorf("invalid OSCTL_SYSTEMD_BACKEND %q (valid: dbus, systemctl)", backend)
+	}
+	if backend != "systemctl" {
+		// The context is bound to the connection's lifetime, so it must not be cancelled here
+		conn, err := sdbus.NewWithContext(context.Background())
+		if err == nil {
+			return &dbusUnitManager{conn: conn, timeout: getSystemdTimeout()}, nil
+		}
+		if backend == "dbus" {
+			return nil, fmt.Errorf("failed to connect to systemd over D-Bus: %v", err)
+		}
+	}
+	return &systemctlUnitManager{timeout: getSystemdTimeout()}, nil
+}
+
+// getSystemdTimeout returns how long to wait for a unit job (OSCTL_SYSTEMD_TIMEOUT)
+func getSystemdTimeout() time.Duration {
+	if v := os.Getenv("OSCTL_SYSTEMD_TIMEOUT"); v != "" {
+		if d, err := parseDurationValue(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultSystemdTimeout
+}
+
+// normalizeUnitName appends ".service" to names without a unit type suffix
+func normalizeUnitName(name string) string {
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+// unitType returns the D-Bus interface suffix for a unit, e.g. "Service"
+func unitType(unit string) string {
+	i := strings.LastIndex(unit, ".")
+	if i < 0 || i == len(unit)-1 {
+		return ""
+	}
+	t := unit[i+1:]
+	if t == "automount" {
+		return "Automount"
+	}
+	return strings.ToUpper(t[:1]) + t[1:]
+}
+
+// parseUnitTimestamp accepts microseconds since the epoch (D-Bus) or the
+// formatted timestamp printed by systemctl show
+func parseUnitTimestamp(value string) time.Time {
+	if value == "" || value == "0" || value == "n/a" {
+		return time.Time{}
+	}
+	if usec, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return time.UnixMicro(int64(usec))
+	}
+	if t, err := time.Parse("Mon 2006-01-02 15:04:05 MST", value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// parseUnitUint parses numeric properties; systemd reports "unset" as the
+// maximum value (or "[not set]" in systemctl output)
+func parseUnitUint(value string) uint64 {
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n == ^uint64(0) {
+		return 0
+	}
+	return n
+}
+
+// getUnitInfo reads the structured properties of a unit
+func getUnitInfo(m unitManager, unit string) (UnitInfo, error) {
+	props, err := m.UnitProperties(unit, "Unit", unitInfoProperties)
+	if err != nil {
+		return UnitInfo{}, err
+	}
+	info := newUnitInfo(unit, props)
+	if unitType(unit) == "Service" && info.LoadState == "loaded" {
+		svc, err := m.UnitProperties(unit, "Service", serviceInfoProperties)
+		if err != nil {
+			return info, err
+		}
+		info.setServiceProperties(svc)
+	}
+	return info, nil
+}
+
+// getUnitInfos reads the structured properties of several units with one
+// batched lookup per interface. Units that cannot be read are left out.
+func getUnitInfos(m unitManager, units []string) (map[string]UnitInfo, error) {
+	props, err := m.UnitPropertiesBatch(units, "Unit", unitInfoProperties)
+	if err != nil {
+		return nil, err
+	}
+	infos := make(map[string]UnitInfo, len(props))
+	var services []string
+	for _, unit := range units {
+		p, ok := props[unit]
+		if !ok {
+			continue
+		}
+		info := newUnitInfo(unit, p)
+		if unitType(unit) == "Service" && info.LoadState == "loaded" {
+			services = append(services, unit)
+		}
+		infos[unit] = info
+	}
+	if len(services) == 0 {
+		return infos, nil
+	}
+	svcProps, err := m.UnitPropertiesBatch(services, "Service", serviceInfoProperties)
+	if err != nil {
+		return infos, err
+	}
+	for _, unit := range services {
+		if svc, ok := svcProps[unit]; ok {
+			info := infos[unit]
+			info.setServiceProperties(svc)
+			infos[unit] = info
+		}
+	}
+	return infos, nil
+}
+
+// newUnitInfo builds a UnitInfo from the generic unit properties
+func newUnitInfo(unit string, props map[string]string) UnitInfo {
+	info := UnitInfo{
+		Name:           props["Id"],
+		Description:    props["Description"],
+		LoadState:      props["LoadState"],
+		ActiveState:    props["ActiveState"],
+		SubState:       props["SubState"],
+		UnitFileState:  props["UnitFileState"],
+		FragmentPath:   props["FragmentPath"],
+		DropInPaths:    strings.Fields(props["DropInPaths"]),
+		ActiveSince:    parseUnitTimestamp(props["ActiveEnterTimestamp"]),
+		StateChangedAt: parseUnitTimestamp(props["StateChangeTimestamp"]),
+	}
+	if info.Name == "" {
+		info.Name = unit
+	}
+	return info
+}
+
+// setServiceProperties fills in the properties of the Service interface
+func (info *UnitInfo) setServiceProperties(svc map[string]string) {
+	info.MainPID = uint32(parseUnitUint(svc["MainPID"]))
+	info.NRestarts = uint32(parseUnitUint(svc["NRestarts"]))
+	info.MemoryCurrent = parseUnitUint(svc["MemoryCurrent"])
+	info.CPUUsageNSec = parseUnitUint(svc["CPUUsageNSec"])
+	info.ControlGroup = svc["ControlGroup"]
+	info.Result = svc["Result"]
+}
+
+// dbusUnitManager talks to org.freedesktop.systemd1 directly
+type dbusUnitManager struct {
+	conn    *sdbus.Conn
+	timeout time.Duration
+}
+
+func (m *dbusUnitManager) Backend() string { return "dbus" }
+
+func (m *dbusUnitManager) Close() { m.conn.Close() }
+
+func (m *dbusUnitManager) RunUnitJob(action, unit string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	jobs := map[string]func(context.Context, string, string, chan<- string) (int, error){
+		"start":             m.conn.StartUnitContext,
+		"stop":              m.conn.StopUnitContext,
+		"restart":           m.conn.RestartUnitContext,
+		"reload":            m.conn.ReloadUnitContext,
+		"try-restart":       m.conn.TryRestartUnitContext,
+		"reload-or-restart": m.conn.ReloadOrRestartUnitContext,
+	}
+	job, ok := jobs[action]
+	if !ok {
+		return "", fmt.Errorf("unsupported job %q", action)
+	}
+
+	done := make(chan string, 1)
+	if _, err := job(ctx, unit, "replace", done); err != nil {
+		return "", err
+	}
+	select {
+	case result := <-done:
+		if result != "done" {
+			return result, fmt.Errorf("job %s", result)
+		}
+		return result, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("timed out after %s waiting for %s job", m.timeout, action)
+	}
+}
+
+func (m *dbusUnitManager) ChangeUnitFile(action, unit string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	units := []string{unit}
+	var err error
+	switch action {
+	case "enable":
+		_, _, err = m.conn.EnableUnitFilesContext(ctx, units, false, false)
+	case "disable":
+		_, err = m.conn.DisableUnitFilesContext(ctx, units, false)
+	case "mask":
+		_, err = m.conn.MaskUnitFilesContext(ctx, units, false, false)
+	case "unmask":
+		_, err = m.conn.UnmaskUnitFilesContext(ctx, units, false)
+	default:
+		return fmt.Errorf("unsupported unit file action %q", action)
+	}
+	if err != nil {
+		return err
+	}
+	return m.conn.ReloadContext(ctx)
+}
+
+func (m *dbusUnitManager) ResetFailedUnit(unit string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return m.conn.ResetFailedUnitContext(ctx, unit)
+}
+
+func (m *dbusUnitManager) DaemonReload() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return m.conn.ReloadContext(ctx)
+}
+
+func (m *dbusUnitManager) UnitProperties(unit, iface string, names []string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var all map[string]interface{}
+	var err error
+	if iface == "Unit" {
+		all, err = m.conn.GetUnitPropertiesContext(ctx, unit)
+	} else {
+		all, err = m.conn.GetUnitTypePropertiesContext(ctx, unit, iface)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string, len(names))
+	for _, name := range names {
+		if v, ok := all[name]; ok {
+			props[name] = dbusValueString(v)
+		}
+	}
+	return props, nil
+}
+
+// UnitPropertiesBatch reads each unit over the existing connection; unlike
+// the systemctl backend this does not start a process per unit
+func (m *dbusUnitManager) UnitPropertiesBatch(units []string, iface string, names []string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string, len(units))
+	for _, unit := range units {
+		if props, err := m.UnitProperties(unit, iface, names); err == nil {
+			result[unit] = props
+		}
+	}
+	return result, nil
+}
+
+func (m *dbusUnitManager) ManagerProperties(names []string) (map[string]string, error) {
+	props := make(map[string]string, len(names))
+	for _, name := range names {
+		value, err := m.conn.GetManagerProperty(name)
+		if err != nil {
+			return nil, err
+		}
+		// Values use GVariant text format, e.g. "@t 1234" or "'graphical.target'"
+		if i := strings.LastIndex(value, " "); strings.HasPrefix(value, "@") && i > 0 {
+			value = value[i+1:]
+		}
+		props[name] = strings.Trim(value, "'")
+	}
+	return props, nil
+}
+
+// dbusValueString renders a property value the way systemctl show would
+func dbusValueString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []string:
+		return strings.Join(t, " ")
+	case [][]interface{}:
+		// Timer specs (TimersCalendar a(sst), TimersMonotonic a(stt))
+		specs := make([]string, 0, len(t))
+		for _, spec := range t {
+			if len(spec) != 3 {
+				continue
+			}
+			value := spec[1]
+			if usec, ok := value.(uint64); ok {
+				value = time.Duration(usec) * time.Microsecond
+			}
+			specs = append(specs, fmt.Sprintf("{ %v=%v ; next_elapse=%v }", spec[0], value, spec[2]))
+		}
+		return strings.Join(specs, " ")
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func (m *dbusUnitManager) ListUnits(states, patterns []string) ([]UnitListEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	units, err := m.conn.ListUnitsByPatternsContext(ctx, states, patterns)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]UnitListEntry, 0, len(units))
+	for _, u := range units {
+		entries = append(entries, UnitListEntry{
+			Name:        u.Name,
+			Description: u.Description,
+			LoadState:   u.LoadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+		})
+	}
+	return entries, nil
+}
+
+// systemctlUnitManager runs systemctl and parses its output
+type systemctlUnitManager struct {
+	timeout time.Duration
+}
+
+func (m *systemctlUnitManager) Backend() string { return "systemctl" }
+
+func (m *systemctlUnitManager) Close() {}
+
+func (m *systemctlUnitManager) systemctl(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "systemctl", args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.ReplaceAll(strings.TrimSpace(string(out)), "\n", "; "); msg != "" {
+			return string(out), fmt.Errorf("%v: %s", err, msg)
+		}
+		return string(out), err
+	}
+	return string(out), nil
+}
+
+func (m *systemctlUnitManager) RunUnitJob(action, unit string) (string, error) {
+	switch action {
+	case "start", "stop", "restart", "reload", "try-restart", "reload-or-restart":
+	default:
+		return "", fmt.Errorf("unsupported job %q", action)
+	}
+	// systemctl blocks until the job has finished
+	if _, err := m.systemctl(action, unit); err != nil {
+		return "failed", err
+	}
+	return "done", nil
+}
+
+func (m *systemctlUnitManager) ChangeUnitFile(action, unit string) error {
+	switch action {
+	case "enable", "disable", "mask", "unmask":
+	default:
+		return fmt.Errorf("unsupported unit file action %q", action)
+	}
+	_, err := m.systemctl(action, unit)
+	return err
+}
+
+func (m *systemctlUnitManager) ResetFailedUnit(unit string) error {
+	_, err := m.systemctl("reset-failed", unit)
+	return err
+}
+
+func (m *systemctlUnitManager) DaemonReload() error {
+	_, err := m.systemctl("daemon-reload")
+	return err
+}
+
+func (m *systemctlUnitManager) UnitProperties(unit, iface string, names []string) (map[string]string, error) {
+	out, err := m.systemctl("show", unit, "--no-pager", "--property="+strings.Join(names, ","))
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string, len(names))
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	if len(props) == 0 {
+		return nil, errors.New("no properties returned")
+	}
+	return props, nil
+}
+
+// systemctlShowBatch is the number of units passed to one systemctl show
+const systemctlShowBatch = 200
+
+// UnitPropertiesBatch runs one systemctl show per batch of units. It prints
+// one block per unit, in argument order, separated by blank lines; Id is
+// always requested so that no block is empty.
+func (m *systemctlUnitManager) UnitPropertiesBatch(units []string, iface string, names []string) (map[string]map[string]string, error) {
+	if !slices.Contains(names, "Id") {
+		names = append([]string{"Id"}, names...)
+	}
+	result := make(map[string]map[string]string, len(units))
+	for start := 0; start < len(units); start += systemctlShowBatch {
+		batch := units[start:min(start+systemctlShowBatch, len(units))]
+		args := append([]string{"show", "--no-pager", "--property=" + strings.Join(names, ",")}, batch...)
+		out, err := m.systemctl(args...)
+		if err != nil {
+			return nil, err
+		}
+		blocks := parseShowBlocks(out)
+		if len(blocks) != len(batch) {
+			return nil, fmt.Errorf("systemctl show returned %d units, expected %d", len(blocks), len(batch))
+		}
+		for i, unit := range batch {
+			result[unit] = blocks[i]
+		}
+	}
+	return result, nil
+}
+
+// parseShowBlocks splits systemctl show output for several units
+func parseShowBlocks(out string) []map[string]string {
+	var blocks []map[string]string
+	var current map[string]string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			if strings.TrimSpace(line) == "" {
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string)
+			blocks = append(blocks, current)
+		}
+		current[key] = value
+	}
+	return blocks
+}
+
+func (m *systemctlUnitManager) ManagerProperties(names []string) (map[string]string, error) {
+	out, err := m.systemctl("show", "--no-pager", "--property="+strings.Join(names, ","))
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string, len(names))
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	return props, nil
+}
+
+func (m *systemctlUnitManager) ListUnits(states, patterns []string) ([]UnitListEntry, error) {
+	args := []string{"list-units", "--all", "--plain", "--no-legend", "--no-pager"}
+	if len(states) > 0 {
+		args = append(args, "--state="+strings.Join(states, ","))
+	}
+	args = append(args, patterns...)
+	out, err := m.systemctl(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []UnitListEntry
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "●"))
+		if len(fields) < 4 {
+			continue
+		}
+		entries = append(entries, UnitListEntry{
+			Name:        fields[0],
+			LoadState:   fields[1],
+			ActiveState: fields[2],
+			SubState:    fields[3],
+			Description: strings.Join(fields[4:], " "),
+		})
+	}
+	return entries, nil
+}
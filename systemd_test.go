@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeUnitManager is an in-memory unitManager. Properties are keyed by
+// "unit/interface"; jobs on units listed in failJobs end with that result.
+type fakeUnitManager struct {
+	units    []UnitListEntry
+	props    map[string]map[string]string
+	manager  map[string]string
+	failJobs map[string]string
+
+	jobs     []string
+	patterns [][]string
+	batches  int
+}
+
+func (m *fakeUnitManager) Backend() string { return "fake" }
+
+func (m *fakeUnitManager) Close() {}
+
+func (m *fakeUnitManager) RunUnitJob(action, unit string) (string, error) {
+	m.jobs = append(m.jobs, action+" "+unit)
+	if result, ok := m.failJobs[unit]; ok {
+		return result, fmt.Errorf("job %s", result)
+	}
+	return "done", nil
+}
+
+func (m *fakeUnitManager) ChangeUnitFile(action, unit string) error {
+	m.jobs = append(m.jobs, action+" "+unit)
+	return nil
+}
+
+func (m *fakeUnitManager) ResetFailedUnit(unit string) error {
+	m.jobs = append(m.jobs, "reset-failed "+unit)
+	return nil
+}
+
+func (m *fakeUnitManager) DaemonReload() error {
+	m.jobs = append(m.jobs, "daemon-reload")
+	return nil
+}
+
+func (m *fakeUnitManager) UnitProperties(unit, iface string, names []string) (map[string]string, error) {
+	all, ok := m.props[unit+"/"+iface]
+	if !ok {
+		return nil, fmt.Errorf("unit %s not found", unit)
+	}
+	props := make(map[string]string, len(names))
+	for _, name := range names {
+		if v, ok := all[name]; ok {
+			props[name] = v
+		}
+	}
+	return props, nil
+}
+
+func (m *fakeUnitManager) UnitPropertiesBatch(units []string, iface string, names []string) (map[string]map[string]string, error) {
+	m.batches++
+	result := make(map[string]map[string]string, len(units))
+	for _, unit := range units {
+		if props, err := m.UnitProperties(unit, iface, names); err == nil {
+			result[unit] = props
+		}
+	}
+	return result, nil
+}
+
+func (m *fakeUnitManager) ManagerProperties(names []string) (map[string]string, error) {
+	return m.manager, nil
+}
+
+func (m *fakeUnitManager) ListUnits(states, patterns []string) ([]UnitListEntry, error) {
+	m.patterns = append(m.patterns, patterns)
+	var entries []UnitListEntry
+	for _, u := range m.units {
+		if len(states) > 0 && !slices.Contains(states, u.ActiveState) && !slices.Contains(states, u.SubState) {
+			continue
+		}
+		if len(patterns) > 0 && !slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(p, u.Name)
+			return ok
+		}) {
+			continue
+		}
+		entries = append(entries, u)
+	}
+	return entries, nil
+}
+
+func TestGetUnitInfosBatchesPerInterface(t *testing.T) {
+	m := &fakeUnitManager{
+		props: map[string]map[string]string{
+			"nginx.service/Unit":    {"Id": "nginx.service", "LoadState": "loaded", "ActiveState": "active", "DropInPaths": "/a.conf /b.conf"},
+			"nginx.service/Service": {"MainPID": "42", "NRestarts": "2", "MemoryCurrent": "18446744073709551615"},
+			"backup.timer/Unit":     {"Id": "backup.timer", "LoadState": "loaded"},
+			"gone.service/Unit":     {"LoadState": "not-found"},
+		},
+	}
+	infos, err := getUnitInfos(m, []string{"nginx.service", "backup.timer", "gone.service", "missing.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.batches != 2 {
+		t.Errorf("got %d property lookups, want one per interface", m.batches)
+	}
+	nginx := infos["nginx.service"]
+	if nginx.MainPID != 42 || nginx.NRestarts != 2 || nginx.MemoryCurrent != 0 || len(nginx.DropInPaths) != 2 {
+		t.Errorf("nginx.service = %+v", nginx)
+	}
+	if infos["gone.service"].Name != "gone.service" {
+		t.Errorf("a unit without Id is named %q", infos["gone.service"].Name)
+	}
+	if _, ok := infos["missing.service"]; ok {
+		t.Error("an unreadable unit was returned")
+	}
+}
+
+func TestNormalizeUnitName(t *testing.T) {
+	for name, want := range map[string]string{
+		"nginx":         "nginx.service",
+		"nginx.service": "nginx.service",
+		"backup.timer":  "backup.timer",
+		"my.app":        "my.app.service",
+	} {
+		if got := normalizeUnitName(name); got != want {
+			t.Errorf("normalizeUnitName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseUnitTimestamp(t *testing.T) {
+	if got := parseUnitTimestamp("1700000000000000"); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("microseconds parsed as %v", got)
+	}
+	if got := parseUnitTimestamp("Tue 2023-11-14 22:13:20 UTC"); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("systemctl timestamp parsed as %v", got)
+	}
+	for _, v := range []string{"", "0", "n/a"} {
+		if !parseUnitTimestamp(v).IsZero() {
+			t.Errorf("parseUnitTimestamp(%q) is not zero", v)
+		}
+	}
+}
+
+// fakeSystemd serves the job methods of org.freedesktop.systemd1.Manager.
+// Jobs on "failing.service" end with "failed", jobs on "hanging.service"
+// never finish, and all others end with "done".
+type fakeSystemd struct {
+	conn *dbus.Conn
+	mu   sync.Mutex
+	next uint32
+}
+
+func (s *fakeSystemd) job(unit string) (dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	s.next++
+	id := s.next
+	s.mu.Unlock()
+	job := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/systemd1/job/%d", id))
+	if unit != "hanging.service" {
+		result := "done"
+		if unit == "failing.service" {
+			result = "failed"
+		}
+		go func() {
+			// Finish after the caller has registered the job
+			time.Sleep(50 * time.Millisecond)
+			s.conn.Emit("/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager.JobRemoved", id, job, unit, result)
+		}()
+	}
+	return job, nil
+}
+
+func (s *fakeSystemd) StartUnit(unit, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return s.job(unit)
+}
+
+func (s *fakeSystemd) RestartUnit(unit, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return s.job(unit)
+}
+
+func (s *fakeSystemd) StopUnit(unit, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return s.job(unit)
+}
+
+// startFakeSystemd runs a private bus with fakeSystemd on it and points the
+// system bus address at it
+func startFakeSystemd(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading bus address: %v", err)
+	}
+	address = strings.TrimSpace(address)
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := conn.RequestName("org.freedesktop.systemd1", dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Export(&fakeSystemd{conn: conn}, "/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", address)
+}
+
+func TestDBusRunUnitJobWaitsForResult(t *testing.T) {
+	startFakeSystemd(t)
+	t.Setenv("OSCTL_SYSTEMD_BACKEND", "dbus")
+	t.Setenv("OSCTL_SYSTEMD_TIMEOUT", "1s")
+	m, err := newUnitManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.Backend() != "dbus" {
+		t.Fatalf("backend is %s, want dbus", m.Backend())
+	}
+
+	if result, err := m.RunUnitJob("restart", "nginx.service"); err != nil || result != "done" {
+		t.Errorf("restart nginx.service = %q, %v; want done", result, err)
+	}
+	if result, err := m.RunUnitJob("start", "failing.service"); err == nil || result != "failed" {
+		t.Errorf("start failing.service = %q, %v; want a failed job", result, err)
+	}
+	if _, err := m.RunUnitJob("stop", "hanging.service"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("stop hanging.service returned %v, want a timeout", err)
+	}
+	if _, err := m.RunUnitJob("freeze", "nginx.service"); err == nil {
+		t.Error("an unsupported job was accepted")
+	}
+}
+
+// fakeSystemctl installs a systemctl script first in PATH that logs its
+// arguments and returns the given output, or fails for failing.service
+func fakeSystemctl(t *testing.T, output string) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %q
+case "$*" in
+*failing.service*) echo "Job for failing.service failed." >&2; exit 1;;
+esac
+cat <<'EOF'
+%sEOF
+`, calls, output)
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+func readCalls(t *testing.T, calls string) []string {
+	t.Helper()
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestUnitManagerFallsBackToSystemctl(t *testing.T) {
+	t.Setenv("OSCTL_SYSTEMD_BACKEND", "")
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+filepath.Join(t.TempDir(), "no-bus"))
+	calls := fakeSystemctl(t, "")
+	m, err := newUnitManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.Backend() != "systemctl" {
+		t.Fatalf("backend is %s, want systemctl", m.Backend())
+	}
+
+	if result, err := m.RunUnitJob("restart", "nginx.service"); err != nil || result != "done" {
+		t.Errorf("restart nginx.service = %q, %v; want done", result, err)
+	}
+	result, err := m.RunUnitJob("start", "failing.service")
+	if err == nil || result != "failed" || !strings.Contains(err.Error(), "Job for failing.service failed.") {
+		t.Errorf("start failing.service = %q, %v; want a failure with systemctl's message", result, err)
+	}
+	if _, err := m.RunUnitJob("freeze", "nginx.service"); err == nil {
+		t.Error("an unsupported job was accepted")
+	}
+	want := []string{"restart nginx.service", "start failing.service"}
+	if got := readCalls(t, calls); !slices.Equal(got, want) {
+		t.Errorf("systemctl calls = %q, want %q", got, want)
+	}
+}
+
+func TestForcedDBusBackendFailsWithoutBus(t *testing.T) {
+	t.Setenv("OSCTL_SYSTEMD_BACKEND", "dbus")
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+filepath.Join(t.TempDir(), "no-bus"))
+	if _, err := newUnitManager(); err == nil {
+		t.Error("forcing the dbus backend without a bus succeeded")
+	}
+	t.Setenv("OSCTL_SYSTEMD_BACKEND", "upstart")
+	if _, err := newUnitManager(); err == nil {
+		t.Error("an unknown backend was accepted")
+	}
+}
+
+func TestSystemctlListUnits(t *testing.T) {
+	fakeSystemctl(t, "● nginx.service loaded failed failed A high performance web server\ncron.service loaded active running Regular background program processing daemon\n")
+	m := &systemctlUnitManager{timeout: time.Second}
+	entries, err := m.ListUnits([]string{"failed"}, []string{"*.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UnitListEntry{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Description: "A high performance web server"},
+		{Name: "cron.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Description: "Regular background program processing daemon"},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+}
+
+func TestSystemctlUnitPropertiesBatch(t *testing.T) {
+	calls := fakeSystemctl(t, "Id=nginx.service\nLoadState=loaded\nDescription=web = server\n\nId=cron.service\nLoadState=loaded\n")
+	m := &systemctlUnitManager{timeout: time.Second}
+	props, err := m.UnitPropertiesBatch([]string{"nginx.service", "cron.service"}, "Unit", []string{"LoadState", "Description"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props["nginx.service"]["Description"] != "web = server" || props["cron.service"]["LoadState"] != "loaded" {
+		t.Errorf("got %v", props)
+	}
+	want := []string{"show --no-pager --property=Id,LoadState,Description nginx.service cron.service"}
+	if got := readCalls(t, calls); !slices.Equal(got, want) {
+		t.Errorf("systemctl calls = %q, want %q", got, want)
+	}
+
+	if _, err := m.UnitPropertiesBatch([]string{"a.service", "b.service", "c.service"}, "Unit", []string{"Id"}); err == nil {
+		t.Error("a short systemctl show output was accepted")
+	}
+	if _, err := m.UnitPropertiesBatch([]string{"failing.service"}, "Unit", []string{"Id"}); err == nil {
+		t.Error("a failed systemctl show was accepted")
+	}
+}
+
+func TestParseShowBlocks(t *testing.T) {
+	blocks := parseShowBlocks("Id=a.service\nAfter=b.service c.service\n\n\nId=b.service\nAfter=\n")
+	if len(blocks) != 2 || blocks[0]["After"] != "b.service c.service" || blocks[1]["Id"] != "b.service" {
+		t.Errorf("got %v", blocks)
+	}
+	if blocks := parseShowBlocks(""); len(blocks) != 0 {
+		t.Errorf("empty output gave %v", blocks)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// timerFileMarker identifies unit files written by osctl timer create
+	timerFileMarker     = "# Managed by osctl (osctl timer create)"
+	defaultTimerPreview = 5
+	maxTimerPreview     = 100
+)
+
+var (
+	timerProperties   = []string{"Unit", "NextElapseUSecRealtime", "LastTriggerUSec", "Result", "TimersCalendar", "TimersMonotonic", "Persistent"}
+	timerSpecPattern  = regexp.MustCompile(`\{ (\w+)=(.*?) ; next_elapse=`)
+	timerNamePattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@-]*$`)
+	calendarIteration = regexp.MustCompile(`^(Next elapse|Iter\. #\d+):\s*(.+)$`)
+)
+
+// TimerInfo is the structured state of a systemd timer
+type TimerInfo struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ActiveState string    `json:"active_state"`
+	Schedules   []string  `json:"schedules,omitempty"`
+	Persistent  bool      `json:"persistent"`
+	NextElapse  time.Time `json:"next_elapse,omitempty"`
+	LastTrigger time.Time `json:"last_trigger,omitempty"`
+	Result      string    `json:"result,omitempty"`
+	Triggers    string    `json:"triggers"`
+	// TriggersState and TriggersResult describe the unit started by the timer
+	TriggersState  string `json:"triggers_state,omitempty"`
+	TriggersResult string `json:"triggers_result,omitempty"`
+	ManagedByOsctl bool   `json:"managed_by_osctl"`
+}
+
+// CalendarPreview is a validated OnCalendar expression with its next firing times
+type CalendarPreview struct {
+	Original   string      `json:"original"`
+	Normalized string      `json:"normalized"`
+	Next       []time.Time `json:"next"`
+}
+
+// previewCalendar validates an OnCalendar expression and computes its next count elapses
+func previewCalendar(spec string, count int) (CalendarPreview, error) {
+	preview := CalendarPreview{Original: spec}
+	if strings.ContainsAny(spec, "\n\r") || strings.TrimSpace(spec) == "" {
+		return preview, errors.New("invalid calendar specification")
+	}
+	out, err := exec.Command("systemd-analyze", "calendar", "--iterations="+strconv.Itoa(count), "--", spec).CombinedOutput()
+	if err != nil {
+		return preview, fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	parseCalendarPreview(string(out), &preview)
+	return preview, nil
+}
+
+// parseCalendarPreview reads the normalized form and the elapses from
+// systemd-analyze calendar output
+func parseCalendarPreview(out string, preview *CalendarPreview) {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if v, ok := strings.CutPrefix(line, "Normalized form:"); ok {
+			preview.Normalized = strings.TrimSpace(v)
+		}
+		if m := calendarIteration.FindStringSubmatch(line); m != nil {
+			if t := parseUnitTimestamp(m[2]); !t.IsZero() {
+				preview.Next = append(preview.Next, t)
+			}
+		}
+	}
+}
+
+// unitFileManagedByOsctl reports whether a unit file was written by osctl timer create
+func unitFileManagedByOsctl(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.HasPrefix(string(data), timerFileMarker)
+}
+
+// listTimers returns all timers with their schedule and the state of the unit they trigger
+func listTimers(m unitManager) ([]TimerInfo, error) {
+	entries, err := m.ListUnits(nil, []string{"*.timer"})
+	if err != nil {
+		return nil, err
+	}
+	timers := make([]TimerInfo, 0, len(entries))
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name, ".timer") {
+			continue
+		}
+		t := TimerInfo{Name: e.Name, Description: e.Description, ActiveState: e.ActiveState}
+		props, err := m.UnitProperties(e.Name, "Timer", timerProperties)
+		if err == nil {
+			t.Triggers = props["Unit"]
+			t.NextElapse = parseUnitTimestamp(props["NextElapseUSecRealtime"])
+			t.LastTrigger = parseUnitTimestamp(props["LastTriggerUSec"])
+			t.Result = props["Result"]
+			t.Persistent = props["Persistent"] == "true" || props["Persistent"] == "yes"
+			for _, spec := range timerSpecPattern.FindAllStringSubmatch(props["TimersCalendar"]+" "+props["TimersMonotonic"], -1) {
+				t.Schedules = append(t.Schedules, spec[1]+"="+spec[2])
+			}
+		}
+		if t.Triggers != "" {
+			if info, err := getUnitInfo(m, t.Triggers); err == nil {
+				t.TriggersState = info.ActiveState
+				t.TriggersResult = info.Result
+			}
+		}
+		t.ManagedByOsctl = unitFileManagedByOsctl(filepath.Join(systemdUnitDir, e.Name))
+		timers = append(timers, t)
+	}
+	// Soonest first; timers without a next elapse go last
+	sort.SliceStable(timers, func(i, j int) bool {
+		a, b := timers[i].NextElapse, timers[j].NextElapse
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return timers, nil
+}
+
+// formatTimerTime renders a timer timestamp with a relative offset
+func formatTimerTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := time.Until(t).Truncate(time.Second)
+	if d >= 0 {
+		return fmt.Sprintf("%s (in %s)", t.Local().Format("2006-01-02 15:04:05"), d)
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Local().Format("2006-01-02 15:04:05"), -d)
+}
+
+// getTimers renders the timer listing
+func getTimers(asJSON bool) string {
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+
+	timers, err := listTimers(m)
+	if err != nil {
+		return fmt.Sprintf("Failed to list timers. Error: %v", err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(timers, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode timers. Error: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIMER\tNEXT\tLAST\tTRIGGERS\tRESULT\tSCHEDULE")
+	for _, t := range timers {
+		result := t.TriggersResult
+		if result == "" {
+			result = "-"
+		}
+		schedule := strings.Join(t.Schedules, ", ")
+		if schedule == "" {
+			schedule = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Name, formatTimerTime(t.NextElapse), formatTimerTime(t.LastTrigger), t.Triggers, result, schedule)
+	}
+	w.Flush()
+	output.WriteString(fmt.Sprintf("\n%d timers\n", len(timers)))
+	return output.String()
+}
+
+// validateTimerName checks a timer base name and strips a .timer suffix
+func validateTimerName(name string) (string, error) {
+	name = strings.TrimSuffix(name, ".timer")
+	if !timerNamePattern.MatchString(name) || len(name) > 200 {
+		return "", fmt.Errorf("invalid timer name %q", name)
+	}
+	return name, nil
+}
+
+// escapeSpecifiers doubles "%" so systemd does not expand specifiers such as %n or %h
+func escapeSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+// checkTimerUnitsFree refuses names whose service or timer is already known
+// to systemd from a unit file not written by osctl, for example a vendor unit
+// in /usr/lib/systemd/system that a file in systemdUnitDir would shadow
+func checkTimerUnitsFree(m unitManager, name string) error {
+	for _, unit := range []string{name + ".service", name + ".timer"} {
+		props, err := m.UnitProperties(unit, "Unit", []string{"LoadState", "FragmentPath"})
+		if err != nil {
+			return fmt.Errorf("cannot check %s: %v", unit, err)
+		}
+		if props["LoadState"] == "not-found" {
+			continue
+		}
+		if props["FragmentPath"] == "" || !unitFileManagedByOsctl(props["FragmentPath"]) {
+			return fmt.Errorf("%s already exists (%s, %s) and was not created by osctl", unit, props["LoadState"], props["FragmentPath"])
+		}
+	}
+	return nil
+}
+
+// createTimer writes a oneshot service and a timer for it, then enables and starts the timer
+func createTimer(name, calendar, command, description string, persistent bool) string {
+	name, err := validateTimerName(name)
+	if err != nil {
+		return err.Error()
+	}
+	command = strings.TrimSpace(command)
+	if strings.TrimSpace(calendar) == "" || command == "" {
+		return "Usage: osctl timer create <name> --on-calendar SPEC --command CMD [--description TEXT] [--persistent]"
+	}
+	if strings.ContainsAny(command+description, "\n\r") {
+		return "Invalid command or description: contains a newline"
+	}
+	if !strings.HasPrefix(strings.Fields(command)[0], "/") {
+		return "Invalid command: the executable must be an absolute path"
+	}
+	preview, err := previewCalendar(calendar, 3)
+	if err != nil {
+		return fmt.Sprintf("Invalid calendar specification %q. Error: %v", calendar, err)
+	}
+	if description == "" {
+		description = "osctl timer " + name
+	}
+
+	servicePath := filepath.Join(systemdUnitDir, name+".service")
+	timerPath := filepath.Join(systemdUnitDir, name+".timer")
+	for _, path := range []string{servicePath, timerPath} {
+		if _, err := os.Stat(path); err == nil && !unitFileManagedByOsctl(path) {
+			return fmt.Sprintf("Refusing to overwrite %s: not created by osctl", path)
+		}
+	}
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+	if err := checkTimerUnitsFree(m, name); err != nil {
+		return fmt.Sprintf("Refusing to create timer %s: %v", name, err)
+	}
+
+	service := fmt.Sprintf("%s\n[Unit]\nDescription=%s\n\n[Service]\nType=oneshot\nExecStart=%s\n",
+		timerFileMarker, escapeSpecifiers(description), escapeSpecifiers(command))
+	timer := fmt.Sprintf("%s\n[Unit]\nDescription=%s (timer)\n\n[Timer]\nOnCalendar=%s\nPersistent=%t\n\n[Install]\nWantedBy=timers.target\n",
+		timerFileMarker, escapeSpecifiers(description), preview.Normalized, persistent)
+	if err := os.WriteFile(servicePath, []byte(service), 0644); err != nil {
+		return fmt.Sprintf("Failed to write %s. Error: %v", servicePath, err)
+	}
+	if err := os.WriteFile(timerPath, []byte(timer), 0644); err != nil {
+		return fmt.Sprintf("Failed to write %s. Error: %v", timerPath, err)
+	}
+
+	if err := m.DaemonReload(); err != nil {
+		return fmt.Sprintf("Timer files written but daemon-reload failed. Error: %v", err)
+	}
+	if err := m.ChangeUnitFile("enable", name+".timer"); err != nil {
+		return fmt.Sprintf("Failed to enable %s.timer. Error: %v", name, err)
+	}
+	if _, err := m.RunUnitJob("start", name+".timer"); err != nil {
+		return fmt.Sprintf("Failed to start %s.timer. Error: %v", name, err)
+	}
+	return fmt.Sprintf("Timer %s.timer created (OnCalendar=%s).\n%s", name, preview.Normalized, strings.TrimRight(formatCalendarNext(preview.Next), "\n"))
+}
+
+// deleteTimer stops, disables and removes a timer created by osctl together with its service
+func deleteTimer(name string) string {
+	name, err := validateTimerName(name)
+	if err != nil {
+		return err.Error()
+	}
+	servicePath := filepath.Join(systemdUnitDir, name+".service")
+	timerPath := filepath.Join(systemdUnitDir, name+".timer")
+	if _, err := os.Stat(timerPath); err != nil {
+		return fmt.Sprintf("Timer %s.timer not found in %s", name, systemdUnitDir)
+	}
+	if !unitFileManagedByOsctl(timerPath) {
+		return fmt.Sprintf("Refusing to delete %s.timer: not created by osctl", name)
+	}
+
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+	if _, err := m.RunUnitJob("stop", name+".timer"); err != nil {
+		return fmt.Sprintf("Failed to stop %s.timer. Error: %v", name, err)
+	}
+	if err := m.ChangeUnitFile("disable", name+".timer"); err != nil {
+		return fmt.Sprintf("Failed to disable %s.timer. Error: %v", name, err)
+	}
+	for _, path := range []string{timerPath, servicePath} {
+		if unitFileManagedByOsctl(path) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Sprintf("Failed to remove %s. Error: %v", path, err)
+			}
+		}
+	}
+	if err := m.DaemonReload(); err != nil {
+		return fmt.Sprintf("Timer removed but daemon-reload failed. Error: %v", err)
+	}
+	return fmt.Sprintf("Timer %s.timer deleted.", name)
+}
+
+// runTimerNow starts the unit a timer triggers immediately
+func runTimerNow(name string) string {
+	name, err := validateTimerName(name)
+	if err != nil {
+		return err.Error()
+	}
+	m, err := newUnitManager()
+	if err != nil {
+		return fmt.Sprintf("Failed to connect to systemd. Error: %v", err)
+	}
+	defer m.Close()
+
+	target := name + ".service"
+	if props, err := m.UnitProperties(name+".timer", "Timer", []string{"Unit"}); err == nil && props["Unit"] != "" {
+		target = props["Unit"]
+	}
+	if _, err := m.RunUnitJob("start", target); err != nil {
+		return formatVerifyFailure(target, "run", err, target)
+	}
+	return fmt.Sprintf("Unit %s (triggered by %s.timer) ran successfully.", target, name)
+}
+
+// formatCalendarNext renders upcoming firing times
+func formatCalendarNext(next []time.Time) string {
+	var output strings.Builder
+	output.WriteString("Next runs:\n")
+	for _, t := range next {
+		output.WriteString(fmt.Sprintf("  %s\n", formatTimerTime(t)))
+	}
+	return output.String()
+}
+
+// validateCalendar renders an OnCalendar preview
+func validateCalendar(spec string, count int, asJSON bool) string {
+	preview, err := previewCalendar(spec, count)
+	if err != nil {
+		return fmt.Sprintf("Invalid calendar specification %q. Error: %v", spec, err)
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(preview, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Failed to encode calendar preview. Error: %v", err)
+		}
+		return string(data)
+	}
+	return fmt.Sprintf("Original form:   %s\nNormalized form: %s\n%s", preview.Original, preview.Normalized, strings.TrimRight(formatCalendarNext(preview.Next), "\n"))
+}
+
+// parseTimerPreviewCount validates the number of firing times to preview
+func parseTimerPreviewCount(value string) (int, error) {
+	if value == "" {
+		return defaultTimerPreview, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxTimerPreview {
+		return 0, fmt.Errorf("invalid count %q (must be 1-%d)", value, maxTimerPreview)
+	}
+	return n, nil
+}
+
+// runTimerCommand parses "osctl timer" arguments
+func runTimerCommand(args []string) string {
+	usage := `Usage: osctl timer [list|create|delete|run-now|validate]
+  list [--json]
+  create <name> --on-calendar SPEC --command CMD [--description TEXT] [--persistent]
+  delete <name>
+  run-now <name>
+  validate <calendar spec> [--count 5] [--json]`
+	if len(args) < 1 {
+		return usage
+	}
+	action := args[0]
+	args = args[1:]
+	// The name or calendar spec precedes the flags
+	var target string
+	if action != "list" {
+		if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+			return usage
+		}
+		target, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("timer "+action, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "output JSON")
+	calendar := fs.String("on-calendar", "", "OnCalendar expression")
+	command := fs.String("command", "", "command to run")
+	description := fs.String("description", "", "unit description")
+	persistent := fs.Bool("persistent", false, "catch up on runs missed while powered off")
+	count := fs.String("count", "", "number of firing times to preview")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+
+	switch action {
+	case "list":
+		return getTimers(*asJSON)
+	case "create":
+		return createTimer(target, *calendar, *command, *description, *persistent)
+	case "delete":
+		return deleteTimer(target)
+	case "run-now":
+		return runTimerNow(target)
+	case "validate":
+		n, err := parseTimerPreviewCount(*count)
+		if err != nil {
+			return fmt.Sprintf("%v\n%s", err, usage)
+		}
+		return validateCalendar(target, n, *asJSON)
+	default:
+		return usage
+	}
+}
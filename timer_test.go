@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTimerName(t *testing.T) {
+	for name, want := range map[string]string{
+		"backup":          "backup",
+		"backup.timer":    "backup",
+		"db-dump_2.daily": "db-dump_2.daily",
+		"report@weekly":   "report@weekly",
+	} {
+		if got, err := validateTimerName(name); err != nil || got != want {
+			t.Errorf("validateTimerName(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", ".timer", "-backup", "../etc/passwd", "a/b", "back up", "x;rm", strings.Repeat("a", 201)} {
+		if _, err := validateTimerName(name); err == nil {
+			t.Errorf("validateTimerName(%q) succeeded", name)
+		}
+	}
+}
+
+func TestParseCalendarPreview(t *testing.T) {
+	out := `  Original form: Mon..Fri 02:00
+Normalized form: Mon..Fri *-*-* 02:00:00
+    Next elapse: Mon 2024-05-06 02:00:00 UTC
+       (in UTC): Mon 2024-05-06 02:00:00 UTC
+       From now: 11h left
+       Iter. #2: Tue 2024-05-07 02:00:00 UTC
+       (in UTC): Tue 2024-05-07 02:00:00 UTC
+       From now: 1 day 11h left
+`
+	var preview CalendarPreview
+	parseCalendarPreview(out, &preview)
+	if preview.Normalized != "Mon..Fri *-*-* 02:00:00" {
+		t.Errorf("normalized form is %q", preview.Normalized)
+	}
+	want := []time.Time{time.Date(2024, 5, 6, 2, 0, 0, 0, time.UTC), time.Date(2024, 5, 7, 2, 0, 0, 0, time.UTC)}
+	if len(preview.Next) != len(want) {
+		t.Fatalf("got elapses %v, want %v", preview.Next, want)
+	}
+	for i := range want {
+		if !preview.Next[i].Equal(want[i]) {
+			t.Errorf("elapse %d is %v, want %v", i, preview.Next[i], want[i])
+		}
+	}
+}
+
+func TestPreviewCalendarRejectsBlankSpecs(t *testing.T) {
+	for _, spec := range []string{"", "   ", "daily\nExecStart=/bin/sh"} {
+		if _, err := previewCalendar(spec, 1); err == nil {
+			t.Errorf("previewCalendar(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestParseTimerPreviewCount(t *testing.T) {
+	if n, err := parseTimerPreviewCount(""); err != nil || n != defaultTimerPreview {
+		t.Errorf("default count = %d, %v", n, err)
+	}
+	for _, value := range []string{"0", "101", "x"} {
+		if _, err := parseTimerPreviewCount(value); err == nil {
+			t.Errorf("parseTimerPreviewCount(%q) succeeded", value)
+		}
+	}
+}
+
+func TestEscapeSpecifiers(t *testing.T) {
+	if got := escapeSpecifiers("/usr/bin/date +%Y-%m-%d %n"); got != "/usr/bin/date +%%Y-%%m-%%d %%n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestCheckTimerUnitsFree(t *testing.T) {
+	dir := t.TempDir()
+	managed := filepath.Join(dir, "backup.service")
+	if err := os.WriteFile(managed, []byte(timerFileMarker+"\n[Unit]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &fakeUnitManager{
+		props: map[string]map[string]string{
+			"backup.service/Unit": {"LoadState": "loaded", "FragmentPath": managed},
+			"backup.timer/Unit":   {"LoadState": "not-found"},
+			"sshd.service/Unit":   {"LoadState": "loaded", "FragmentPath": "/usr/lib/systemd/system/sshd.service"},
+			"sshd.timer/Unit":     {"LoadState": "not-found"},
+			"fresh.service/Unit":  {"LoadState": "not-found"},
+			"fresh.timer/Unit":    {"LoadState": "not-found"},
+			"hidden.service/Unit": {"LoadState": "masked"},
+			"hidden.timer/Unit":   {"LoadState": "not-found"},
+		},
+	}
+	for name, free := range map[string]bool{"fresh": true, "backup": true, "sshd": false, "hidden": false, "unknown": false} {
+		if err := checkTimerUnitsFree(m, name); (err == nil) != free {
+			t.Errorf("checkTimerUnitsFree(%q) = %v, want free=%v", name, err, free)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+const (
+	defaultTopInterval = time.Second
+	defaultTopLimit    = 10
+)
+
+// validTopSorts lists the supported --sort keys
+var validTopSorts = map[string]bool{
+	"cpu":     true,
+	"mem":     true,
+	"rss":     true,
+	"io":      true,
+	"threads": true,
+	"fds":     true,
+}
+
+// TopOptions controls sampling, filtering and ordering of the process list
+type TopOptions struct {
+	Interval time.Duration
+	Sort     string
+	Limit    int
+	User     string
+	Name     string
+	JSON     bool
+}
+
+// ProcessStat is a per-process measurement taken over a sampling interval
+type ProcessStat struct {
+	PID              int32   `json:"pid"`
+	PPID             int32   `json:"ppid"`
+	User             string  `json:"user"`
+	Name             string  `json:"name"`
+	Command          string  `json:"command,omitempty"`
+	CPUPercent       float64 `json:"cpu_percent"`
+	MemPercent       float32 `json:"mem_percent"`
+	RSS              uint64  `json:"rss_bytes"`
+	Threads          int32   `json:"threads"`
+	FDs              int32   `json:"fds"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+}
+
+// TopReport is the result of a top query
+type TopReport struct {
+	Interval  string        `json:"interval"`
+	Sort      string        `json:"sort"`
+	Total     int           `json:"total"`
+	Matched   int           `json:"matched"`
+	Exited    int           `json:"exited_during_sampling"`
+	Processes []ProcessStat `json:"processes"`
+}
+
+// processCounters is one sample of a process. at is when the counters were
+// read, so rates use each process's own interval.
+type processCounters struct {
+	at         time.Time
+	created    int64
+	cpu        float64
+	readBytes  uint64
+	writeBytes uint64
+}
+
+// sameProcess reports whether two samples belong to the same process, which
+// is not the case if the PID was reused in between
+func (c processCounters) sameProcess(prev processCounters) bool {
+	return c.created == prev.created
+}
+
+// cpuPercentSince returns the CPU usage between two samples of the same
+// process, clamped at 0
+func (c processCounters) cpuPercentSince(prev processCounters) float64 {
+	elapsed := c.at.Sub(prev.at).Seconds()
+	if elapsed <= 0 || c.cpu < prev.cpu {
+		return 0
+	}
+	return 100 * (c.cpu - prev.cpu) / elapsed
+}
+
+// newTopOptions validates string parameters as given on the CLI or API
+func newTopOptions(interval, sortBy, limit, user, name string, asJSON bool) (TopOptions, error) {
+	opts := TopOptions{Interval: defaultTopInterval, Sort: "cpu", Limit: defaultTopLimit, User: user, Name: name, JSON: asJSON}
+	if interval != "" {
+		d, err := parseDurationValue(interval)
+		if err != nil || d <= 0 || d > maxRateInterval {
+			return opts, fmt.Errorf("invalid interval %q (must be between 0 and %s)", interval, maxRateInterval)
+		}
+		opts.Interval = d
+	}
+	if sortBy != "" {
+		if !validTopSorts[sortBy] {
+			return opts, fmt.Errorf("invalid sort key %q. Valid: cpu, mem, rss, io, threads, fds", sortBy)
+		}
+		opts.Sort = sortBy
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid limit %q (0 shows all processes)", limit)
+		}
+		opts.Limit = n
+	}
+	return opts, nil
+}
+
+// matchProcessName matches a glob pattern if it contains wildcards, a substring otherwise
+func matchProcessName(pattern, name, cmdline string) bool {
+	if pattern == "" {
+		return true
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, _ := filepath.Match(pattern, name)
+		return ok
+	}
+	return strings.Contains(name, pattern) || strings.Contains(cmdline, pattern)
+}
+
+func readProcessCounters(p *process.Process) (processCounters, bool) {
+	var c processCounters
+	times, err := p.Times()
+	if err != nil {
+		return c, false
+	}
+	c.at = time.Now()
+	c.cpu = times.User + times.System
+	c.created, _ = p.CreateTime()
+	if ioc, err := p.IOCounters(); err == nil {
+		c.readBytes, c.writeBytes = ioc.ReadBytes, ioc.WriteBytes
+	}
+	return c, true
+}
+
+// collectProcessStats samples all processes twice, interval apart, and
+// returns per-process CPU and I/O rates. The counters are read in a tight
+// loop before the slower descriptive fields. Processes whose details cannot
+// be read are still listed with the fields that could be read.
+func collectProcessStats(interval time.Duration) ([]ProcessStat, int, error) {
+	before, err := process.Processes()
+	if err != nil {
+		return nil, 0, err
+	}
+	prev := make(map[int32]processCounters, len(before))
+	for _, p := range before {
+		if c, ok := readProcessCounters(p); ok {
+			prev[p.Pid] = c
+		}
+	}
+
+	time.Sleep(interval)
+
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, 0, err
+	}
+	cur := make(map[int32]processCounters, len(procs))
+	for _, p := range procs {
+		if c, ok := readProcessCounters(p); ok {
+			cur[p.Pid] = c
+		}
+	}
+	alive := make(map[int32]int64, len(procs))
+
+	stats := make([]ProcessStat, 0, len(procs))
+	for _, p := range procs {
+		alive[p.Pid] = 0
+		st := ProcessStat{PID: p.Pid}
+		st.Name, _ = p.Name()
+		st.User, _ = p.Username()
+		st.PPID, _ = p.Ppid()
+		st.Command, _ = p.Cmdline()
+		st.MemPercent, _ = p.MemoryPercent()
+		if mi, err := p.MemoryInfo(); err == nil && mi != nil {
+			st.RSS = mi.RSS
+		}
+		st.Threads, _ = p.NumThreads()
+		st.FDs, _ = p.NumFDs()
+
+		if c, ok := cur[p.Pid]; ok {
+			alive[p.Pid] = c.created
+			if pc, seen := prev[p.Pid]; seen && c.sameProcess(pc) {
+				elapsed := c.at.Sub(pc.at)
+				st.CPUPercent = c.cpuPercentSince(pc)
+				st.ReadBytesPerSec = counterRate(pc.readBytes, c.readBytes, elapsed)
+				st.WriteBytesPerSec = counterRate(pc.writeBytes, c.writeBytes, elapsed)
+			}
+		}
+		stats = append(stats, st)
+	}
+
+	// A process counts as exited if its PID is gone or now belongs to another process
+	exited := 0
+	for _, p := range before {
+		pc, sampled := prev[p.Pid]
+		created, ok := alive[p.Pid]
+		if !ok || (sampled && created != 0 && created != pc.created) {
+			exited++
+		}
+	}
+	return stats, exited, nil
+}
+
+func sortProcessStats(stats []ProcessStat, key string) {
+	less := map[string]func(a, b ProcessStat) bool{
+		"cpu": func(a, b ProcessStat) bool { return a.CPUPercent > b.CPUPercent },
+		"mem": func(a, b ProcessStat) bool { return a.MemPercent > b.MemPercent },
+		"rss": func(a, b ProcessStat) bool { return a.RSS > b.RSS },
+		"io": func(a, b ProcessStat) bool {
+			return a.ReadBytesPerSec+a.WriteBytesPerSec > b.ReadBytesPerSec+b.WriteBytesPerSec
+		},
+		"threads": func(a, b ProcessStat) bool { return a.Threads > b.Threads },
+		"fds":     func(a, b ProcessStat) bool { return a.FDs > b.FDs },
+	}[key]
+	sort.SliceStable(stats, func(i, j int) bool {
+		if less(stats[i], stats[j]) {
+			return true
+		}
+		if less(stats[j], stats[i]) {
+			return false
+		}
+		return stats[i].PID < stats[j].PID
+	})
+}
+
+// getTopReport samples, filters and sorts the process list
+func getTopReport(opts TopOptions) (TopReport, error) {
+	stats, exited, err := collectProcessStats(opts.Interval)
+	if err != nil {
+		return TopReport{}, err
+	}
+
+	report := TopReport{Interval: opts.Interval.String(), Sort: opts.Sort, Total: len(stats), Exited: exited}
+	matched := stats[:0]
+	for _, st := range stats {
+		if opts.User != "" && st.User != opts.User {
+			continue
+		}
+		if !matchProcessName(opts.Name, st.Name, st.Command) {
+			continue
+		}
+		matched = append(matched, st)
+	}
+	report.Matched = len(matched)
+
+	sortProcessStats(matched, opts.Sort)
+	if opts.Limit > 0 && len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+	report.Processes = matched
+	return report, nil
+}
+
+// getTopProcesses returns the top processes as a table or JSON
+func getTopProcesses(opts TopOptions) string {
+	report, err := getTopReport(opts)
+	if err != nil {
+		return fmt.Sprintf("Error getting processes: %v", err)
+	}
+
+	if opts.JSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("Error encoding processes: %v", err)
+		}
+		return string(data)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Top processes by %s (sampled over %s, %d of %d processes matched)\n\n",
+		report.Sort, report.Interval, report.Matched, report.Total))
+	w := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	io.WriteString(w, "PID\tUSER\tNAME\tCPU%\tMEM%\tRSS\tTHR\tFDS\tREAD/s\tWRITE/s\n")
+	for _, p := range report.Processes {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.2f\t%.2f\t%s\t%d\t%d\t%s\t%s\n", p.PID, p.User, p.Name,
+			p.CPUPercent, p.MemPercent, formatBytes(p.RSS), p.Threads, p.FDs,
+			formatBytes(uint64(p.ReadBytesPerSec)), formatBytes(uint64(p.WriteBytesPerSec)))
+	}
+	w.Flush()
+	return output.String()
+}
+
+// runTopCommand handles `osctl top [--interval 1s] [--sort cpu] [--limit 10] [--user u] [--name n] [--json]`
+func runTopCommand(args []string) string {
+	usage := "Usage: osctl top [--interval 1s] [--sort cpu|mem|rss|io|threads|fds] [--limit 10] [--user name] [--name pattern] [--json]"
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	interval := fs.String("interval", "", "sampling interval")
+	sortBy := fs.String("sort", "", "sort key")
+	limit := fs.String("limit", "", "number of processes to show (0 for all)")
+	user := fs.String("user", "", "only show processes of this user")
+	name := fs.String("name", "", "only show processes matching this name or glob")
+	asJSON := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	opts, err := newTopOptions(*interval, *sortBy, *limit, *user, *name, *asJSON)
+	if err != nil {
+		return fmt.Sprintf("%v\n%s", err, usage)
+	}
+	return getTopProcesses(opts)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCPUPercentSinceUsesOwnInterval(t *testing.T) {
+	start := time.Unix(1000, 0)
+	prev := processCounters{at: start, created: 1, cpu: 10}
+	// Read 4s after the previous sample, e.g. late in a slow collection pass
+	cur := processCounters{at: start.Add(4 * time.Second), created: 1, cpu: 12}
+	if got := cur.cpuPercentSince(prev); got != 50 {
+		t.Errorf("got %v%%, want 50%%", got)
+	}
+	// A reused PID starts with lower counters
+	reused := processCounters{at: start.Add(time.Second), created: 2, cpu: 1}
+	if reused.sameProcess(prev) {
+		t.Error("a process with another create time counts as the same")
+	}
+	if got := reused.cpuPercentSince(prev); got != 0 {
+		t.Errorf("lower counters gave %v%%, want 0", got)
+	}
+	if got := cur.cpuPercentSince(cur); got != 0 {
+		t.Errorf("zero elapsed gave %v%%, want 0", got)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchdogInterval    = 30 * time.Second
+	defaultWatchdogMaxRestarts = 3
+	defaultWatchdogWindow      = 10 * time.Minute
+	defaultWatchdogGrace       = time.Minute
+)
+
+// WatchdogConfig is the supervision policy, read from OSCTL_WATCHDOG_* variables
+type WatchdogConfig struct {
+	Units       []string
+	Interval    time.Duration
+	MaxRestarts int
+	Window      time.Duration
+	Grace       time.Duration
+	Escalate    string
+}
+
+// WatchdogUnitState is the supervision state of one unit
+type WatchdogUnitState struct {
+	Unit          string      `json:"unit"`
+	ActiveState   string      `json:"active_state"`
+	SubState      string      `json:"sub_state"`
+	Healthy       bool        `json:"healthy"`
+	InactiveSince time.Time   `json:"inactive_since,omitempty"`
+	Restarts      []time.Time `json:"restarts,omitempty"`
+	Escalated     bool        `json:"escalated"`
+	LastError     string      `json:"last_error,omitempty"`
+	CheckedAt     time.Time   `json:"checked_at"`
+}
+
+// WatchdogStatus is reported by the /watchdog endpoint
+type WatchdogStatus struct {
+	Enabled     bool                `json:"enabled"`
+	Interval    string              `json:"interval"`
+	MaxRestarts int                 `json:"max_restarts"`
+	Window      string              `json:"window"`
+	Grace       string              `json:"inactive_grace"`
+	Escalate    string              `json:"escalate"`
+	Suspended   bool                `json:"suspended_for_maintenance"`
+	Units       []WatchdogUnitState `json:"units"`
+}
+
+var (
+	watchdogMu     sync.Mutex
+	watchdogStatus WatchdogStatus
+)
+
+// getWatchdogConfig reads the watchdog policy. Supervision is disabled unless
+// OSCTL_WATCHDOG_UNITS lists at least one unit.
+func getWatchdogConfig() WatchdogConfig {
+	cfg := WatchdogConfig{
+		Interval:    defaultWatchdogInterval,
+		MaxRestarts: defaultWatchdogMaxRestarts,
+		Window:      defaultWatchdogWindow,
+		Grace:       defaultWatchdogGrace,
+		Escalate:    "alert",
+	}
+	for _, u := range splitPatterns(os.Getenv("OSCTL_WATCHDOG_UNITS")) {
+		if strings.ContainsAny(u, ";|&$`\n\r") {
+			log.Printf("Ignoring invalid watchdog unit %q", u)
+			continue
+		}
+		cfg.Units = append(cfg.Units, normalizeUnitName(u))
+	}
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"OSCTL_WATCHDOG_INTERVAL", &cfg.Interval},
+		{"OSCTL_WATCHDOG_WINDOW", &cfg.Window},
+		{"OSCTL_WATCHDOG_GRACE", &cfg.Grace},
+	}
+	for _, d := range durations {
+		if v := os.Getenv(d.env); v != "" {
+			if parsed, err := parseDurationValue(v); err == nil && parsed > 0 {
+				*d.dst = parsed
+			} else {
+				log.Printf("Ignoring invalid %s %q", d.env, v)
+			}
+		}
+	}
+	if v := os.Getenv("OSCTL_WATCHDOG_MAX_RESTARTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxRestarts = n
+		} else {
+			log.Printf("Ignoring invalid OSCTL_WATCHDOG_MAX_RESTARTS %q", v)
+		}
+	}
+	if v := os.Getenv("OSCTL_WATCHDOG_ESCALATE"); v != "" {
+		if v == "alert" || v == "maintenance" {
+			cfg.Escalate = v
+		} else {
+			log.Printf("Ignoring invalid OSCTL_WATCHDOG_ESCALATE %q (valid: alert, maintenance)", v)
+		}
+	}
+	return cfg
+}
+
+// startWatchdog supervises the configured units in the background
+func startWatchdog() {
+	cfg := getWatchdogConfig()
+	watchdogMu.Lock()
+	watchdogStatus = WatchdogStatus{
+		Enabled:     len(cfg.Units) > 0,
+		Interval:    cfg.Interval.String(),
+		MaxRestarts: cfg.MaxRestarts,
+		Window:      cfg.Window.String(),
+		Grace:       cfg.Grace.String(),
+		Escalate:    cfg.Escalate,
+	}
+	for _, u := range cfg.Units {
+		watchdogStatus.Units = append(watchdogStatus.Units, WatchdogUnitState{Unit: u, Healthy: true})
+	}
+	watchdogMu.Unlock()
+	if len(cfg.Units) == 0 {
+		return
+	}
+	log.Printf("Watchdog supervising %s every %s (max %d restarts per %s, then %s)",
+		strings.Join(cfg.Units, ", "), cfg.Interval, cfg.MaxRestarts, cfg.Window, cfg.Escalate)
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			runWatchdogCheck(cfg)
+			<-ticker.C
+		}
+	}()
+}
+
+// runWatchdogCheck inspects every supervised unit once and applies the policy
+func runWatchdogCheck(cfg WatchdogConfig) {
+	// Deliberate stops during maintenance must not be undone
+	suspended := readMaintenanceStatus().Enabled
+	watchdogMu.Lock()
+	watchdogStatus.Suspended = suspended
+	watchdogMu.Unlock()
+	if suspended {
+		return
+	}
+
+	m, err := newUnitManager()
+	if err != nil {
+		log.Printf("Watchdog: failed to connect to systemd: %v", err)
+		return
+	}
+	defer m.Close()
+
+	for i, unit := range cfg.Units {
+		watchdogMu.Lock()
+		state := watchdogStatus.Units[i]
+		watchdogMu.Unlock()
+
+		state = superviseUnit(m, cfg, state)
+
+		watchdogMu.Lock()
+		watchdogStatus.Units[i] = state
+		watchdogMu.Unlock()
+
+		healthy := 0.0
+		if state.Healthy {
+			healthy = 1
+		}
+		watchdogUnitHealthy.WithLabelValues(unit).Set(healthy)
+	}
+}
+
+// superviseUnit restarts a failed, or too long inactive, unit within the restart budget and escalates beyond it
+func superviseUnit(m unitManager, cfg WatchdogConfig, state WatchdogUnitState) WatchdogUnitState {
+	now := time.Now()
+	state.CheckedAt = now
+	info, err := getUnitInfo(m, state.Unit)
+	if err != nil {
+		state.LastError = err.Error()
+		return state
+	}
+	state.ActiveState, state.SubState = info.ActiveState, info.SubState
+
+	switch info.ActiveState {
+	case "active", "activating", "reloading":
+		if !state.Healthy || state.Escalated {
+			writeAuditEvent(AuditEvent{Source: "watchdog", Action: "recovered", Target: state.Unit, Result: "ok"})
+		}
+		state.Healthy, state.Escalated, state.InactiveSince, state.LastError = true, false, time.Time{}, ""
+		return state
+	case "failed":
+		state.Healthy = false
+	default:
+		// Inactive or deactivating: give deliberate or in-progress stops a grace period
+		if state.InactiveSince.IsZero() {
+			state.InactiveSince = now
+		}
+		if now.Sub(state.InactiveSince) < cfg.Grace {
+			return state
+		}
+		state.Healthy = false
+	}
+
+	// Only restarts inside the sliding window count against the budget
+	var recent []time.Time
+	for _, t := range state.Restarts {
+		if now.Sub(t) < cfg.Window {
+			recent = append(recent, t)
+		}
+	}
+	state.Restarts = recent
+
+	if len(state.Restarts) >= cfg.MaxRestarts {
+		if !state.Escalated {
+			state.Escalated = true
+			escalateWatchdog(cfg, state)
+		}
+		return state
+	}
+
+	state.Restarts = append(state.Restarts, now)
+	watchdogRestarts.WithLabelValues(state.Unit).Inc()
+	event := AuditEvent{Source: "watchdog", Action: "restart", Target: state.Unit,
+		Detail: fmt.Sprintf("unit was %s/%s; attempt %d of %d in %s", info.ActiveState, info.SubState, len(state.Restarts), cfg.MaxRestarts, cfg.Window)}
+	if _, err := m.RunUnitJob("restart", state.Unit); err != nil {
+		state.LastError = err.Error()
+		event.Result = "failed"
+		event.Detail += ": " + err.Error()
+	} else {
+		state.LastError = ""
+		state.InactiveSince = time.Time{}
+		event.Result = "ok"
+	}
+	writeAuditEvent(event)
+	log.Printf("Watchdog: restart %s: %s (%s)", state.Unit, event.Result, event.Detail)
+	return state
+}
+
+// escalateWatchdog raises an alert or enters maintenance mode once a unit exhausted its restart budget
+func escalateWatchdog(cfg WatchdogConfig, state WatchdogUnitState) {
+	watchdogEscalations.WithLabelValues(state.Unit, cfg.Escalate).Inc()
+	message := fmt.Sprintf("osctl watchdog: %s is %s after %d restarts within %s", state.Unit, state.ActiveState, len(state.Restarts), cfg.Window)
+	result := "ok"
+	if cfg.Escalate == "maintenance" {
+		if out := enableMaintenanceMode(message); !strings.Contains(out, "successfully") {
+			result = "failed"
+			message += ": " + out
+		}
+	} else if err := exec.Command("wall", message).Run(); err != nil {
+		// Alert delivery is best effort; the audit log and metric still record it
+		message += fmt.Sprintf(" (wall: %v)", err)
+	}
+	writeAuditEvent(AuditEvent{Source: "watchdog", Action: "escalate-" + cfg.Escalate, Target: state.Unit, Result: result, Detail: message})
+	log.Printf("Watchdog: %s", message)
+}
+
+// getWatchdogStatus reports the live supervision state of the API server
+func getWatchdogStatus() string {
+	watchdogMu.Lock()
+	data, err := json.MarshalIndent(watchdogStatus, "", "  ")
+	watchdogMu.Unlock()
+	if err != nil {
+		return fmt.Sprintf("Failed to encode watchdog status. Error: %v", err)
+	}
+	return string(data)
+}
+
+// getWatchdogSummary shows the configured policy and recent watchdog actions from the audit log
+func getWatchdogSummary() string {
+	cfg := getWatchdogConfig()
+	var output strings.Builder
+	if len(cfg.Units) == 0 {
+		output.WriteString("Watchdog disabled (set OSCTL_WATCHDOG_UNITS to supervise units in the API server)\n")
+	} else {
+		output.WriteString(fmt.Sprintf("Supervised units: %s\n", strings.Join(cfg.Units, ", ")))
+		output.WriteString(fmt.Sprintf("Policy: check every %s, restart after %s inactive, at most %d restarts per %s, then %s\n",
+			cfg.Interval, cfg.Grace, cfg.MaxRestarts, cfg.Window, cfg.Escalate))
+	}
+	output.WriteString("\nRecent watchdog actions (24h):\n")
+	output.WriteString(getAuditLog("24h", "watchdog", "", false))
+	return output.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// watchdogTestManager returns a fake unit manager with app.service in the given state
+func watchdogTestManager(active, sub string) *fakeUnitManager {
+	m := &fakeUnitManager{props: map[string]map[string]string{}}
+	setWatchdogTestState(m, active, sub)
+	return m
+}
+
+func setWatchdogTestState(m *fakeUnitManager, active, sub string) {
+	m.props["app.service/Unit"] = map[string]string{"LoadState": "loaded", "ActiveState": active, "SubState": sub}
+	m.props["app.service/Service"] = map[string]string{}
+}
+
+// watchdogTestEnv sends audit events to a temporary log and alerts to a fake wall
+func watchdogTestEnv(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("OSCTL_AUDIT_LOG", filepath.Join(dir, "audit.log"))
+	if err := os.WriteFile(filepath.Join(dir, "wall"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// watchdogAuditActions lists the actions recorded in the audit log
+func watchdogAuditActions(t *testing.T) []string {
+	t.Helper()
+	events, err := readAuditEvents(time.Time{}, "watchdog", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	return actions
+}
+
+func watchdogTestConfig() WatchdogConfig {
+	return WatchdogConfig{Units: []string{"app.service"}, MaxRestarts: 2, Window: time.Hour, Grace: time.Hour, Escalate: "alert"}
+}
+
+func TestSuperviseUnitRestartsFailedUnit(t *testing.T) {
+	watchdogTestEnv(t)
+	m := watchdogTestManager("failed", "failed")
+	state := superviseUnit(m, watchdogTestConfig(), WatchdogUnitState{Unit: "app.service", Healthy: true})
+	if !slices.Equal(m.jobs, []string{"restart app.service"}) {
+		t.Errorf("jobs = %v, want one restart", m.jobs)
+	}
+	if state.Healthy || len(state.Restarts) != 1 || state.Escalated || state.LastError != "" {
+		t.Errorf("state = %+v", state)
+	}
+	if actions := watchdogAuditActions(t); !slices.Equal(actions, []string{"restart"}) {
+		t.Errorf("audit actions = %v", actions)
+	}
+}
+
+func TestSuperviseUnitEscalatesOnce(t *testing.T) {
+	watchdogTestEnv(t)
+	cfg := watchdogTestConfig()
+	m := watchdogTestManager("failed", "failed")
+	state := WatchdogUnitState{Unit: "app.service", Healthy: true}
+	for range 5 {
+		state = superviseUnit(m, cfg, state)
+	}
+	if len(m.jobs) != cfg.MaxRestarts {
+		t.Errorf("jobs = %v, want %d restarts", m.jobs, cfg.MaxRestarts)
+	}
+	if !state.Escalated || len(state.Restarts) != cfg.MaxRestarts {
+		t.Errorf("state = %+v", state)
+	}
+	if actions := watchdogAuditActions(t); !slices.Equal(actions, []string{"restart", "restart", "escalate-alert"}) {
+		t.Errorf("audit actions = %v, want two restarts and one escalation", actions)
+	}
+
+	// Restarts outside the window no longer count against the budget
+	old := time.Now().Add(-2 * cfg.Window)
+	state = superviseUnit(m, cfg, WatchdogUnitState{Unit: "app.service", Restarts: []time.Time{old, old}})
+	if len(m.jobs) != cfg.MaxRestarts+1 || len(state.Restarts) != 1 {
+		t.Errorf("expired restarts still counted: jobs %v, state %+v", m.jobs, state)
+	}
+}
+
+func TestSuperviseUnitRecoveryResetsState(t *testing.T) {
+	watchdogTestEnv(t)
+	cfg := watchdogTestConfig()
+	m := watchdogTestManager("failed", "failed")
+	state := WatchdogUnitState{Unit: "app.service", Healthy: true}
+	for range 3 {
+		state = superviseUnit(m, cfg, state)
+	}
+	if !state.Escalated {
+		t.Fatalf("state = %+v, want escalated", state)
+	}
+
+	setWatchdogTestState(m, "active", "running")
+	state = superviseUnit(m, cfg, state)
+	if !state.Healthy || state.Escalated || !state.InactiveSince.IsZero() || state.ActiveState != "active" {
+		t.Errorf("state after recovery = %+v", state)
+	}
+	actions := watchdogAuditActions(t)
+	if len(actions) == 0 || actions[len(actions)-1] != "recovered" {
+		t.Errorf("audit actions = %v, want a recovery last", actions)
+	}
+
+	// A healthy unit stays quiet
+	state = superviseUnit(m, cfg, state)
+	if len(watchdogAuditActions(t)) != len(actions) || len(m.jobs) != cfg.MaxRestarts {
+		t.Errorf("a healthy unit caused actions: jobs %v", m.jobs)
+	}
+}
+
+func TestSuperviseUnitInactiveGrace(t *testing.T) {
+	watchdogTestEnv(t)
+	cfg := watchdogTestConfig()
+	m := watchdogTestManager("inactive", "dead")
+	state := superviseUnit(m, cfg, WatchdogUnitState{Unit: "app.service", Healthy: true})
+	if len(m.jobs) != 0 || !state.Healthy || state.InactiveSince.IsZero() {
+		t.Errorf("an inactive unit within the grace period was touched: jobs %v, state %+v", m.jobs, state)
+	}
+	if actions := watchdogAuditActions(t); len(actions) != 0 {
+		t.Errorf("audit actions = %v", actions)
+	}
+
+	// Once the grace period has passed the unit is restarted
+	state.InactiveSince = time.Now().Add(-2 * cfg.Grace)
+	state = superviseUnit(m, cfg, state)
+	if !slices.Equal(m.jobs, []string{"restart app.service"}) || state.Healthy {
+		t.Errorf("jobs %v, state %+v after the grace period", m.jobs, state)
+	}
+}